@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"havamal-api/config"
+	"havamal-api/internal/db"
+	"havamal-api/internal/media"
+	"havamal-api/internal/webhooks"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func main() {
+	days := flag.Int("days", 30, "remove media unreferenced for at least this many days")
+	dryRun := flag.Bool("dry-run", false, "only list orphan media, do not delete anything")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		slog.Error("Unable to load config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	database, err := db.NewPostgresConnection(cfg)
+	if err != nil {
+		slog.Error("Unable to connect to database", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	// Removals are queued for webhooks, which the API server delivers
+	mediaService := media.NewService(
+		media.NewRepository(database),
+		cfg.Media.Path,
+		media.NewSigner(cfg.Media.SigningSecret),
+		cfg.Media.SignedURLTTL,
+		webhooks.NewService(webhooks.NewRepository(database), webhooks.Config(cfg.Webhooks)),
+	)
+
+	slog.Info("Collecting orphan media...", slog.Int("days", *days), slog.Bool("dry_run", *dryRun))
+	orphans, err := mediaService.CollectGarbage(time.Duration(*days)*24*time.Hour, *dryRun)
+	if err != nil {
+		slog.Error("Failed to collect orphan media", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	for _, m := range orphans {
+		slog.Info("Orphan media",
+			slog.String("id", m.ID.String()),
+			slog.String("filename", m.Filename),
+			slog.Int64("size", m.Size),
+		)
+	}
+
+	if *dryRun {
+		slog.Info("Dry run complete", slog.Int("orphans", len(orphans)))
+	} else {
+		slog.Info("Garbage collection complete", slog.Int("removed", len(orphans)))
+	}
+}
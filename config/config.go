@@ -1,9 +1,12 @@
 package config
 
 import (
+	"crypto/hkdf"
+	"crypto/sha256"
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -11,7 +14,8 @@ import (
 
 type Config struct {
 	App struct {
-		Port string
+		Port    string
+		SiteURL string
 	}
 	Database struct {
 		Host     string
@@ -34,6 +38,42 @@ type Config struct {
 	Migration struct {
 		Path string
 	}
+	API struct {
+		// The unversioned routes are aliases of v1, deprecated since
+		// AliasDeprecated and removed at AliasSunset
+		AliasDeprecated time.Time
+		AliasSunset     time.Time
+	}
+	GraphQL struct {
+		Introspection bool
+		MaxDepth      int
+		MaxComplexity int
+		// MaxQuerySize is the longest query text, in bytes, that is run or
+		// persisted
+		MaxQuerySize int
+		// PersistedOnly refuses queries sent as text unless they were
+		// registered beforehand
+		PersistedOnly bool
+	}
+	Webhooks struct {
+		// MaxAttempts is how many times a delivery is tried before it fails
+		MaxAttempts int
+		// Backoff is the wait before the first retry, doubled after each
+		// failure up to MaxBackoff
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+		// PollInterval is how often the outbox is checked for due deliveries
+		PollInterval time.Duration
+		Timeout      time.Duration
+		// AllowPrivate lets webhooks reach loopback, link-local and private
+		// addresses, for receivers on the same host or network
+		AllowPrivate bool
+	}
+	Media struct {
+		Path          string
+		SigningSecret string
+		SignedURLTTL  time.Duration
+	}
 	Observability struct {
 		// Loki (logs)
 		LokiURL      string
@@ -57,6 +97,7 @@ func LoadConfig() (Config, error) {
 	
 	var cfg Config
 	cfg.App.Port = getenvDefault("APP_PORT", "8080")
+	cfg.App.SiteURL = strings.TrimSuffix(getenvDefault("SITE_URL", "https://havamal.cat"), "/")
 	
 	// Database config...
 	cfg.Database.Host = getenvDefault("DATABASE_HOST", "localhost")
@@ -101,6 +142,75 @@ func LoadConfig() (Config, error) {
 	
 	// Migration config...
 	cfg.Migration.Path = getenvDefault("MIGRATION_PATH", "./migrations")
+
+	// API versioning config...
+	cfg.API.AliasDeprecated, err = time.Parse(time.DateOnly, getenvDefault("API_ALIAS_DEPRECATED", "2026-10-19"))
+	if err != nil {
+		return Config{}, errors.New("API_ALIAS_DEPRECATED must be a date (YYYY-MM-DD)")
+	}
+	cfg.API.AliasSunset, err = time.Parse(time.DateOnly, getenvDefault("API_ALIAS_SUNSET", "2027-04-19"))
+	if err != nil {
+		return Config{}, errors.New("API_ALIAS_SUNSET must be a date (YYYY-MM-DD)")
+	}
+
+	// GraphQL config...
+	cfg.GraphQL.Introspection = getenvDefault("GRAPHQL_INTROSPECTION", "false") == "true"
+	cfg.GraphQL.PersistedOnly = getenvDefault("GRAPHQL_PERSISTED_ONLY", "false") == "true"
+	cfg.GraphQL.MaxDepth, err = strconv.Atoi(getenvDefault("GRAPHQL_MAX_DEPTH", "8"))
+	if err != nil {
+		return Config{}, errors.New("GRAPHQL_MAX_DEPTH must be an integer")
+	}
+	cfg.GraphQL.MaxComplexity, err = strconv.Atoi(getenvDefault("GRAPHQL_MAX_COMPLEXITY", "5000"))
+	if err != nil {
+		return Config{}, errors.New("GRAPHQL_MAX_COMPLEXITY must be an integer")
+	}
+	cfg.GraphQL.MaxQuerySize, err = strconv.Atoi(getenvDefault("GRAPHQL_MAX_QUERY_SIZE", "8192"))
+	if err != nil {
+		return Config{}, errors.New("GRAPHQL_MAX_QUERY_SIZE must be an integer")
+	}
+
+	// Webhooks config...
+	cfg.Webhooks.MaxAttempts, err = strconv.Atoi(getenvDefault("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil {
+		return Config{}, errors.New("WEBHOOK_MAX_ATTEMPTS must be an integer")
+	}
+	durations := []struct {
+		key          string
+		defaultValue string
+		target       *time.Duration
+	}{
+		{"WEBHOOK_BACKOFF", "30", &cfg.Webhooks.Backoff},
+		{"WEBHOOK_MAX_BACKOFF", "21600", &cfg.Webhooks.MaxBackoff},
+		{"WEBHOOK_POLL_INTERVAL", "5", &cfg.Webhooks.PollInterval},
+		{"WEBHOOK_TIMEOUT", "10", &cfg.Webhooks.Timeout},
+	}
+	for _, d := range durations {
+		seconds, err := strconv.Atoi(getenvDefault(d.key, d.defaultValue))
+		if err != nil || seconds <= 0 {
+			return Config{}, errors.New(d.key + " must be a positive integer representing seconds")
+		}
+		*d.target = time.Duration(seconds) * time.Second
+	}
+	cfg.Webhooks.AllowPrivate = getenvDefault("WEBHOOK_ALLOW_PRIVATE", "false") == "true"
+
+	// Media config...
+	cfg.Media.Path = getenvDefault("MEDIA_PATH", "../images")
+	// Media signatures never share a key with tokens: without its own secret
+	// one is derived from AUTH_SECRET
+	cfg.Media.SigningSecret = os.Getenv("MEDIA_SIGNING_SECRET")
+	if cfg.Media.SigningSecret == "" {
+		key, err := hkdf.Key(sha256.New, []byte(cfg.Auth.Secret), nil, "havamal media signing", sha256.Size)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Media.SigningSecret = string(key)
+	}
+	signedTTLString := getenvDefault("MEDIA_SIGNED_URL_TTL", "3600")
+	signedTTLSeconds, err := strconv.Atoi(signedTTLString)
+	if err != nil {
+		return Config{}, errors.New("MEDIA_SIGNED_URL_TTL must be an integer representing seconds")
+	}
+	cfg.Media.SignedURLTTL = time.Duration(signedTTLSeconds) * time.Second
 	
 	// Observability configuration
 	cfg.Observability.LokiURL = getenvDefault("LOKI_URL", "")
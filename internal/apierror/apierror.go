@@ -0,0 +1,126 @@
+// Package apierror answers API errors as RFC 7807 problem details from a
+// catalogue of stable machine codes, each with its HTTP status and a title in
+// every supported locale.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"havamal-api/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Code string
+
+// Error is a domain error identified by its catalogue code. Its text is the
+// English title, for logs; responses use the reader's locale.
+type Error struct {
+	Code Code
+}
+
+func New(code Code) *Error {
+	return &Error{Code: code}
+}
+
+func (e *Error) Error() string {
+	return Message(e.Code, "en")
+}
+
+// Kinds of domain error. Services and repositories may return them directly
+// or a catalogued error of the same status, which errors.Is also matches:
+// errors.Is(tags.ErrTagExists, ErrConflict) holds.
+var (
+	ErrNotFound   = New(NotFound)
+	ErrConflict   = New(Conflict)
+	ErrValidation = New(Validation)
+	ErrForbidden  = New(AuthForbidden)
+)
+
+func (e *Error) Is(target error) bool {
+	return target == kind(e.Code)
+}
+
+// kind returns the kind of error a code belongs to, nil when none
+func kind(code Code) error {
+	switch Status(code) {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	case http.StatusForbidden:
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Status returns the HTTP status of a code
+func Status(code Code) int {
+	if entry, ok := catalogue[code]; ok {
+		return entry.status
+	}
+	return catalogue[Internal].status
+}
+
+// Message returns the title of a code in locale, falling back to the default
+// locale
+func Message(code Code, locale string) string {
+	entry, ok := catalogue[code]
+	if !ok {
+		entry = catalogue[Internal]
+	}
+	if message, ok := entry.messages[locale]; ok {
+		return message
+	}
+	return entry.messages[i18n.Default]
+}
+
+// Write answers with code. Fields are added to the problem, e.g. the current
+// state of a resource on a conflict.
+func Write(c *gin.Context, code Code, fields ...gin.H) {
+	Respond(c, New(code), fields...)
+}
+
+// Respond attaches err to the request and stops the handler chain; the
+// problem middleware answers it once the handler returns.
+func Respond(c *gin.Context, err error, fields ...gin.H) {
+	extra := gin.H{}
+	for _, field := range fields {
+		for key, value := range field {
+			extra[key] = value
+		}
+	}
+	c.Error(err).SetMeta(extra)
+	c.Abort()
+}
+
+// Lookup returns the code an error maps to, Internal when none
+func Lookup(err error) Code {
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Code
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	switch {
+	case errors.Is(err, i18n.ErrUnsupportedLocale):
+		return UnsupportedLocale
+	case errors.Is(err, i18n.ErrDefaultLocale):
+		return DefaultLocale
+	case isInvalidUUID(err):
+		return InvalidId
+	}
+	return Internal
+}
+
+// isInvalidUUID recognises the errors of uuid.Parse, which services return
+// as is for malformed ids
+func isInvalidUUID(err error) bool {
+	return uuid.IsInvalidLengthError(err) || err.Error() == "invalid UUID format"
+}
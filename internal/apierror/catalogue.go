@@ -0,0 +1,487 @@
+package apierror
+
+import "net/http"
+
+const (
+	Internal          Code = "internal"
+	InvalidRequest    Code = "request.invalid"
+	Validation        Code = "request.validation"
+	InvalidId         Code = "request.invalid_id"
+	InvalidRender     Code = "request.invalid_render"
+	UnknownField      Code = "request.unknown_field"
+	UnknownInclude    Code = "request.unknown_include"
+	NotFound          Code = "not_found"
+	Conflict          Code = "resource.conflict"
+	InvalidReference  Code = "reference.invalid"
+	UnsupportedLocale Code = "locale.unsupported"
+	DefaultLocale     Code = "locale.default"
+
+	TranslationNotFound Code = "translation.not_found"
+	SlugEmpty           Code = "slug.empty"
+	SlugConflict        Code = "slug.conflict"
+
+	PreconditionRequired Code = "precondition.required"
+	InvalidETag          Code = "precondition.invalid_etag"
+	RevisionConflict     Code = "revision.conflict"
+
+	AuthInvalidCredentials Code = "auth.invalid_credentials"
+	AuthLocked             Code = "auth.locked"
+	AuthUnauthorized       Code = "auth.unauthorized"
+	AuthForbidden          Code = "auth.forbidden"
+	UserNotFound           Code = "user.not_found"
+
+	PostNotFound          Code = "post.not_found"
+	PostLocked            Code = "post.locked"
+	PostTranslationExists Code = "post.translation_exists"
+	PostInvalidFormat     Code = "post.invalid_format"
+	PostInvalidBlocks     Code = "post.invalid_blocks"
+	AutosaveNotFound      Code = "autosave.not_found"
+
+	CategoryNotFound      Code = "category.not_found"
+	CategoryCycle         Code = "category.cycle"
+	CategoryInUse         Code = "category.in_use"
+	CategoryMergeIntoSelf Code = "category.merge_into_self"
+	CategoryUnknown       Code = "category.unknown"
+	CategoryDuplicate     Code = "category.duplicate"
+
+	NavigationNotFound            Code = "navigation.not_found"
+	NavigationCycle               Code = "navigation.cycle"
+	NavigationInvalidType         Code = "navigation.invalid_type"
+	NavigationInvalidLinkSource   Code = "navigation.invalid_link_source"
+	NavigationExternalLinkSource  Code = "navigation.external_link_source"
+	NavigationMissingReference    Code = "navigation.missing_reference"
+	NavigationUnexpectedReference Code = "navigation.unexpected_reference"
+	NavigationUnknownReference    Code = "navigation.unknown_reference"
+
+	MenuNotFound        Code = "menu.not_found"
+	MenuInvalidLocation Code = "menu.invalid_location"
+	MenuInvalidSlug     Code = "menu.invalid_slug"
+	MenuUnknownItem     Code = "menu.unknown_item"
+	MenuDuplicateItem   Code = "menu.duplicate_item"
+	MenuIncompleteItems Code = "menu.incomplete_items"
+
+	TagNotFound      Code = "tag.not_found"
+	TagMergeIntoSelf Code = "tag.merge_into_self"
+	TagInvalidName   Code = "tag.invalid_name"
+
+	MediaNotFound         Code = "media.not_found"
+	MediaMissingFile      Code = "media.missing_file"
+	MediaInUse            Code = "media.in_use"
+	MediaUnsupportedType  Code = "media.unsupported_type"
+	MediaTooLarge         Code = "media.too_large"
+	MediaInvalidSignature Code = "media.invalid_signature"
+	MediaSignatureExpired Code = "media.signature_expired"
+
+	PreviewNotFound        Code = "preview.not_found"
+	PreviewExpired         Code = "preview.expired"
+	PreviewRevoked         Code = "preview.revoked"
+	PreviewVersionMismatch Code = "preview.version_mismatch"
+
+	VersionNotFound Code = "version.not_found"
+
+	PatchUnsupportedMediaType Code = "patch.unsupported_media_type"
+	PatchInvalid              Code = "patch.invalid"
+	PatchTestFailed           Code = "patch.test_failed"
+
+	PathRequired Code = "path.required"
+	PathNotFound Code = "path.not_found"
+
+	GraphQLQueryRequired        Code = "graphql.query_required"
+	GraphQLInvalidQuery         Code = "graphql.invalid_query"
+	GraphQLQueryTooLarge        Code = "graphql.query_too_large"
+	GraphQLTooDeep              Code = "graphql.too_deep"
+	GraphQLTooComplex           Code = "graphql.too_complex"
+	GraphQLIntrospectionOff     Code = "graphql.introspection_disabled"
+	GraphQLPersistedOnly        Code = "graphql.persisted_only"
+	GraphQLPersistedNotFound    Code = "graphql.persisted_query_not_found"
+	GraphQLPersistedHashInvalid Code = "graphql.persisted_query_hash_mismatch"
+
+	WebhookNotFound         Code = "webhook.not_found"
+	WebhookUnknownEvent     Code = "webhook.unknown_event"
+	WebhookDeliveryNotFound Code = "webhook.delivery_not_found"
+	WebhookPrivateURL       Code = "webhook.private_url"
+)
+
+type entry struct {
+	status   int
+	messages map[string]string
+}
+
+func messages(status int, ca string, es string, en string) entry {
+	return entry{status: status, messages: map[string]string{"ca": ca, "es": es, "en": en}}
+}
+
+var catalogue = map[Code]entry{
+	Internal: messages(http.StatusInternalServerError,
+		"S'ha produït un error intern",
+		"Se ha producido un error interno",
+		"An internal error occurred"),
+	InvalidRequest: messages(http.StatusBadRequest,
+		"La petició no és vàlida",
+		"La petición no es válida",
+		"The request is not valid"),
+	Validation: messages(http.StatusUnprocessableEntity,
+		"Alguns camps no són vàlids",
+		"Algunos campos no son válidos",
+		"Some fields are not valid"),
+	InvalidId: messages(http.StatusBadRequest,
+		"L'identificador no és vàlid",
+		"El identificador no es válido",
+		"The id is not valid"),
+	InvalidRender: messages(http.StatusBadRequest,
+		"render ha de ser html o raw",
+		"render debe ser html o raw",
+		"render must be html or raw"),
+	UnknownField: messages(http.StatusBadRequest,
+		"fields inclou un camp que no existeix",
+		"fields incluye un campo que no existe",
+		"fields names a member that does not exist"),
+	UnknownInclude: messages(http.StatusBadRequest,
+		"include inclou una relació que no existeix",
+		"include incluye una relación que no existe",
+		"include names a relation that does not exist"),
+	NotFound: messages(http.StatusNotFound,
+		"No s'ha trobat el recurs",
+		"No se ha encontrado el recurso",
+		"Resource not found"),
+	Conflict: messages(http.StatusConflict,
+		"Ja existeix un recurs amb aquestes dades",
+		"Ya existe un recurso con estos datos",
+		"A resource with these values already exists"),
+	InvalidReference: messages(http.StatusBadRequest,
+		"La petició fa referència a un recurs que no existeix",
+		"La petición hace referencia a un recurso que no existe",
+		"The request refers to a resource that does not exist"),
+	UnsupportedLocale: messages(http.StatusBadRequest,
+		"Idioma no admès",
+		"Idioma no admitido",
+		"Unsupported locale"),
+	DefaultLocale: messages(http.StatusBadRequest,
+		"L'idioma per defecte s'edita al mateix recurs, no com a traducció",
+		"El idioma por defecto se edita en el propio recurso, no como traducción",
+		"The default locale is edited on the resource itself, not as a translation"),
+
+	TranslationNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat la traducció",
+		"No se ha encontrado la traducción",
+		"Translation not found"),
+	SlugEmpty: messages(http.StatusBadRequest,
+		"No es pot generar un slug a partir d'un títol buit",
+		"No se puede generar un slug a partir de un título vacío",
+		"A slug cannot be generated from an empty title"),
+	SlugConflict: messages(http.StatusConflict,
+		"Aquest slug ja està en ús",
+		"Este slug ya está en uso",
+		"This slug is already in use"),
+
+	PreconditionRequired: messages(http.StatusPreconditionRequired,
+		"Cal la capçalera If-Match",
+		"Se requiere la cabecera If-Match",
+		"The If-Match header is required"),
+	InvalidETag: messages(http.StatusBadRequest,
+		"If-Match ha de ser un únic ETag retornat per l'API",
+		"If-Match debe ser un único ETag devuelto por la API",
+		"If-Match must be a single ETag returned by the API"),
+	RevisionConflict: messages(http.StatusPreconditionFailed,
+		"El recurs s'ha modificat des que es va llegir",
+		"El recurso se ha modificado desde que se leyó",
+		"The resource has been modified since it was read"),
+
+	AuthInvalidCredentials: messages(http.StatusUnauthorized,
+		"Credencials incorrectes",
+		"Credenciales incorrectas",
+		"Invalid credentials"),
+	AuthLocked: messages(http.StatusForbidden,
+		"El compte està desactivat",
+		"La cuenta está desactivada",
+		"The account is disabled"),
+	AuthUnauthorized: messages(http.StatusUnauthorized,
+		"Cal iniciar sessió",
+		"Es necesario iniciar sesión",
+		"Authentication required"),
+	AuthForbidden: messages(http.StatusForbidden,
+		"No tens permís per fer aquesta acció",
+		"No tienes permiso para realizar esta acción",
+		"You are not allowed to do this"),
+	UserNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat l'usuari",
+		"No se ha encontrado el usuario",
+		"User not found"),
+
+	PostNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat l'entrada",
+		"No se ha encontrado la entrada",
+		"Post not found"),
+	PostLocked: messages(http.StatusLocked,
+		"Una altra persona està editant l'entrada",
+		"Otra persona está editando la entrada",
+		"The post is being edited by someone else"),
+	PostTranslationExists: messages(http.StatusConflict,
+		"L'entrada ja té una traducció en aquest idioma",
+		"La entrada ya tiene una traducción en este idioma",
+		"The post already has a translation in this locale"),
+	PostInvalidFormat: messages(http.StatusBadRequest,
+		"Format de contingut no vàlid: ha de ser markdown, html o plaintext",
+		"Formato de contenido no válido: debe ser markdown, html o plaintext",
+		"Invalid content format: expected markdown, html or plaintext"),
+	PostInvalidBlocks: messages(http.StatusBadRequest,
+		"Els blocs no són vàlids",
+		"Los bloques no son válidos",
+		"Invalid blocks"),
+	AutosaveNotFound: messages(http.StatusNotFound,
+		"No hi ha cap còpia desada automàticament",
+		"No hay ninguna copia guardada automáticamente",
+		"No autosaved copy"),
+
+	CategoryNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat la categoria",
+		"No se ha encontrado la categoría",
+		"Category not found"),
+	CategoryCycle: messages(http.StatusBadRequest,
+		"Una categoria no pot ser el seu propi avantpassat",
+		"Una categoría no puede ser su propio antecesor",
+		"A category cannot be its own ancestor"),
+	CategoryInUse: messages(http.StatusConflict,
+		"La categoria encara té entrades; fes servir force=true per esborrar-la igualment",
+		"La categoría todavía tiene entradas; usa force=true para borrarla igualmente",
+		"The category still has posts; use force=true to delete it anyway"),
+	CategoryMergeIntoSelf: messages(http.StatusBadRequest,
+		"No es pot fusionar una categoria amb ella mateixa ni amb un descendent",
+		"No se puede fusionar una categoría consigo misma ni con un descendiente",
+		"Cannot merge a category into itself or one of its nested descendants"),
+	CategoryUnknown: messages(http.StatusBadRequest,
+		"La llista d'ordre conté una categoria desconeguda",
+		"La lista de orden contiene una categoría desconocida",
+		"Unknown category in order list"),
+	CategoryDuplicate: messages(http.StatusBadRequest,
+		"La llista d'ordre conté una categoria més d'una vegada",
+		"La lista de orden contiene una categoría más de una vez",
+		"Category listed more than once in order list"),
+
+	NavigationNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat l'element de navegació",
+		"No se ha encontrado el elemento de navegación",
+		"Navigation item not found"),
+	NavigationCycle: messages(http.StatusBadRequest,
+		"Un element de navegació no pot ser el seu propi avantpassat",
+		"Un elemento de navegación no puede ser su propio antecesor",
+		"A navigation item cannot be its own ancestor"),
+	NavigationInvalidType: messages(http.StatusBadRequest,
+		"type ha de ser internal o external",
+		"type debe ser internal o external",
+		"type must be internal or external"),
+	NavigationInvalidLinkSource: messages(http.StatusBadRequest,
+		"link_source ha de ser custom, category o post",
+		"link_source debe ser custom, category o post",
+		"link_source must be custom, category or post"),
+	NavigationExternalLinkSource: messages(http.StatusBadRequest,
+		"Els elements externs han d'usar link_source custom",
+		"Los elementos externos deben usar link_source custom",
+		"External items must use the custom link_source"),
+	NavigationMissingReference: messages(http.StatusBadRequest,
+		"link_source necessita el category_id o post_id corresponent",
+		"link_source necesita el category_id o post_id correspondiente",
+		"link_source requires the matching category_id or post_id"),
+	NavigationUnexpectedReference: messages(http.StatusBadRequest,
+		"category_id i post_id només s'admeten amb el link_source corresponent",
+		"category_id y post_id solo se admiten con el link_source correspondiente",
+		"category_id and post_id are only allowed with the matching link_source"),
+	NavigationUnknownReference: messages(http.StatusBadRequest,
+		"El pare, menú, categoria o entrada referenciat no existeix",
+		"El padre, menú, categoría o entrada referenciado no existe",
+		"Referenced parent, menu, category or post does not exist"),
+
+	MenuNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat el menú",
+		"No se ha encontrado el menú",
+		"Menu not found"),
+	MenuInvalidLocation: messages(http.StatusBadRequest,
+		"location ha de ser header, footer o sidebar",
+		"location debe ser header, footer o sidebar",
+		"location must be header, footer or sidebar"),
+	MenuInvalidSlug: messages(http.StatusBadRequest,
+		"El slug del menú no pot ser buit",
+		"El slug del menú no puede estar vacío",
+		"Menu slug cannot be empty"),
+	MenuUnknownItem: messages(http.StatusBadRequest,
+		"L'element no pertany al menú",
+		"El elemento no pertenece al menú",
+		"Item does not belong to the menu"),
+	MenuDuplicateItem: messages(http.StatusBadRequest,
+		"Un element apareix més d'una vegada",
+		"Un elemento aparece más de una vez",
+		"Item listed more than once"),
+	MenuIncompleteItems: messages(http.StatusBadRequest,
+		"Cal incloure tots els elements del menú",
+		"Hay que incluir todos los elementos del menú",
+		"Every item of the menu must be listed"),
+
+	TagNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat l'etiqueta",
+		"No se ha encontrado la etiqueta",
+		"Tag not found"),
+	TagMergeIntoSelf: messages(http.StatusBadRequest,
+		"No es pot fusionar una etiqueta amb ella mateixa",
+		"No se puede fusionar una etiqueta consigo misma",
+		"Cannot merge a tag into itself"),
+	TagInvalidName: messages(http.StatusBadRequest,
+		"El nom de l'etiqueta ha de contenir lletres o xifres",
+		"El nombre de la etiqueta debe contener letras o dígitos",
+		"Tag name must contain letters or digits"),
+
+	MediaNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat el fitxer",
+		"No se ha encontrado el archivo",
+		"Media not found"),
+	MediaMissingFile: messages(http.StatusBadRequest,
+		"No s'ha enviat cap fitxer",
+		"No se ha enviado ningún archivo",
+		"No file provided"),
+	MediaInUse: messages(http.StatusConflict,
+		"Hi ha entrades que encara fan servir el fitxer",
+		"Hay entradas que todavía usan el archivo",
+		"Media is still referenced by posts"),
+	MediaUnsupportedType: messages(http.StatusBadRequest,
+		"Tipus de fitxer no admès",
+		"Tipo de archivo no admitido",
+		"Unsupported file type"),
+	MediaTooLarge: messages(http.StatusBadRequest,
+		"El fitxer és massa gran",
+		"El archivo es demasiado grande",
+		"File too large"),
+	MediaInvalidSignature: messages(http.StatusForbidden,
+		"La signatura del fitxer no és vàlida",
+		"La firma del archivo no es válida",
+		"Invalid media signature"),
+	MediaSignatureExpired: messages(http.StatusForbidden,
+		"La signatura del fitxer ha caducat",
+		"La firma del archivo ha caducado",
+		"Media signature expired"),
+
+	PreviewNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat l'enllaç de previsualització",
+		"No se ha encontrado el enlace de vista previa",
+		"Preview link not found"),
+	PreviewExpired: messages(http.StatusGone,
+		"L'enllaç de previsualització ha caducat",
+		"El enlace de vista previa ha caducado",
+		"Preview link has expired"),
+	PreviewRevoked: messages(http.StatusGone,
+		"L'enllaç de previsualització s'ha revocat",
+		"El enlace de vista previa se ha revocado",
+		"Preview link has been revoked"),
+	PreviewVersionMismatch: messages(http.StatusBadRequest,
+		"La versió no pertany a aquesta entrada",
+		"La versión no pertenece a esta entrada",
+		"Version does not belong to this post"),
+
+	VersionNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat la versió",
+		"No se ha encontrado la versión",
+		"Version not found"),
+
+	PatchUnsupportedMediaType: messages(http.StatusUnsupportedMediaType,
+		"El pedaç ha de ser application/merge-patch+json o application/json-patch+json",
+		"El parche debe ser application/merge-patch+json o application/json-patch+json",
+		"The patch must be application/merge-patch+json or application/json-patch+json"),
+	PatchInvalid: messages(http.StatusBadRequest,
+		"No s'ha pogut aplicar el pedaç",
+		"No se ha podido aplicar el parche",
+		"The patch could not be applied"),
+	PatchTestFailed: messages(http.StatusConflict,
+		"Una operació test del pedaç no s'ha complert",
+		"Una operación test del parche no se ha cumplido",
+		"A test operation of the patch failed"),
+
+	PathRequired: messages(http.StatusBadRequest,
+		"Cal el paràmetre path",
+		"Se requiere el parámetro path",
+		"path is required"),
+	PathNotFound: messages(http.StatusNotFound,
+		"Cap entrada ni categoria correspon a aquest camí",
+		"Ninguna entrada ni categoría corresponde a esta ruta",
+		"No post or category matches this path"),
+
+	GraphQLQueryRequired: messages(http.StatusBadRequest,
+		"Cal una consulta o el hash d'una consulta desada",
+		"Se requiere una consulta o el hash de una consulta guardada",
+		"A query or the hash of a persisted query is required"),
+	GraphQLInvalidQuery: messages(http.StatusBadRequest,
+		"La consulta no és vàlida",
+		"La consulta no es válida",
+		"The query is not valid"),
+	GraphQLQueryTooLarge: messages(http.StatusRequestEntityTooLarge,
+		"La consulta és massa llarga",
+		"La consulta es demasiado larga",
+		"The query is too large"),
+	GraphQLTooDeep: messages(http.StatusBadRequest,
+		"La consulta és massa profunda",
+		"La consulta es demasiado profunda",
+		"The query is too deep"),
+	GraphQLTooComplex: messages(http.StatusBadRequest,
+		"La consulta és massa complexa",
+		"La consulta es demasiado compleja",
+		"The query is too complex"),
+	GraphQLIntrospectionOff: messages(http.StatusBadRequest,
+		"La introspecció està desactivada",
+		"La introspección está desactivada",
+		"Introspection is disabled"),
+	GraphQLPersistedOnly: messages(http.StatusBadRequest,
+		"Només s'accepten consultes desades",
+		"Solo se aceptan consultas guardadas",
+		"Only persisted queries are accepted"),
+	GraphQLPersistedNotFound: messages(http.StatusNotFound,
+		"No es coneix cap consulta desada amb aquest hash",
+		"No se conoce ninguna consulta guardada con este hash",
+		"No persisted query has this hash"),
+	GraphQLPersistedHashInvalid: messages(http.StatusBadRequest,
+		"El hash no correspon a la consulta",
+		"El hash no corresponde a la consulta",
+		"The hash does not match the query"),
+
+	WebhookNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat el webhook",
+		"No se ha encontrado el webhook",
+		"Webhook not found"),
+	WebhookUnknownEvent: messages(http.StatusBadRequest,
+		"events inclou un esdeveniment que no existeix",
+		"events incluye un evento que no existe",
+		"events names an event that does not exist"),
+	WebhookDeliveryNotFound: messages(http.StatusNotFound,
+		"No s'ha trobat l'enviament",
+		"No se ha encontrado el envío",
+		"Delivery not found"),
+	WebhookPrivateURL: messages(http.StatusBadRequest,
+		"url ha d'apuntar a una adreça pública",
+		"url debe apuntar a una dirección pública",
+		"url must point to a public address"),
+}
+
+// rules are the messages of field validation failures by validator tag; %s
+// is the rule's parameter
+var rules = map[string]map[string]string{
+	"required": {"ca": "és obligatori", "es": "es obligatorio", "en": "is required"},
+	"email":    {"ca": "ha de ser una adreça electrònica", "es": "debe ser una dirección de correo electrónico", "en": "must be an email address"},
+	"uuid":     {"ca": "ha de ser un identificador", "es": "debe ser un identificador", "en": "must be an id"},
+	"url":      {"ca": "ha de ser un URL http(s)", "es": "debe ser una URL http(s)", "en": "must be an http(s) URL"},
+	"min":      {"ca": "ha de tenir com a mínim %s", "es": "debe tener como mínimo %s", "en": "must be at least %s"},
+	"max":      {"ca": "ha de tenir com a màxim %s", "es": "debe tener como máximo %s", "en": "must be at most %s"},
+	"gte":      {"ca": "ha de ser com a mínim %s", "es": "debe ser como mínimo %s", "en": "must be at least %s"},
+	"lte":      {"ca": "ha de ser com a màxim %s", "es": "debe ser como máximo %s", "en": "must be at most %s"},
+	"oneof":    {"ca": "ha de ser un de: %s", "es": "debe ser uno de: %s", "en": "must be one of: %s"},
+	"type":     {"ca": "ha de ser de tipus %s", "es": "debe ser de tipo %s", "en": "must be of type %s"},
+	"slug":     {"ca": "només pot tenir lletres minúscules, xifres i guions", "es": "solo puede tener letras minúsculas, cifras y guiones", "en": "may only contain lowercase letters, digits and dashes"},
+	"locale":   {"ca": "ha de ser un idioma disponible (ca, es, en)", "es": "debe ser un idioma disponible (ca, es, en)", "en": "must be a supported locale (ca, es, en)"},
+	"eq":       {"ca": "ha de ser %s", "es": "debe ser %s", "en": "must be %s"},
+	"excluded": {"ca": "no s'admet aquí", "es": "no se admite aquí", "en": "is not allowed here"},
+	"invalid":  {"ca": "no és vàlid", "es": "no es válido", "en": "is not valid"},
+}
+
+// ruleAliases share the message of another rule
+var ruleAliases = map[string]string{
+	"required_if":      "required",
+	"required_without": "required",
+	"excluded_unless":  "excluded",
+	"excluded_if":      "excluded",
+	"http_url":         "url",
+}
@@ -0,0 +1,82 @@
+package apierror
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"havamal-api/internal/i18n"
+)
+
+// declaredCodes parses this package for the constants of type Code, so a code
+// added without a catalogue entry is caught
+func declaredCodes(t *testing.T) []Code {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "catalogue.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parsing the catalogue: %v", err)
+	}
+	codes := make([]Code, 0)
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		if ident, ok := spec.Type.(*ast.Ident); !ok || ident.Name != "Code" {
+			return true
+		}
+		for _, value := range spec.Values {
+			if literal, ok := value.(*ast.BasicLit); ok {
+				codes = append(codes, Code(literal.Value[1:len(literal.Value)-1]))
+			}
+		}
+		return true
+	})
+	return codes
+}
+
+func TestCatalogue(t *testing.T) {
+	codes := declaredCodes(t)
+	if len(codes) != len(catalogue) {
+		t.Errorf("%d codes declared, %d catalogued", len(codes), len(catalogue))
+	}
+	for _, code := range codes {
+		entry, ok := catalogue[code]
+		if !ok {
+			t.Errorf("%s is not catalogued", code)
+			continue
+		}
+		if entry.status < 400 || entry.status > 599 {
+			t.Errorf("%s answers status %d", code, entry.status)
+		}
+		for _, locale := range i18n.Supported {
+			if entry.messages[locale] == "" {
+				t.Errorf("%s has no %s message", code, locale)
+			}
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   Code
+		locale string
+		want   string
+	}{
+		{"in locale", PostNotFound, "es", catalogue[PostNotFound].messages["es"]},
+		{"unsupported locale", PostNotFound, "de", catalogue[PostNotFound].messages[i18n.Default]},
+		{"unknown code", "post.gone", "en", catalogue[Internal].messages["en"]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Message(tt.code, tt.locale); got != tt.want {
+				t.Errorf("Message() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if Status("post.gone") != Status(Internal) {
+		t.Error("Status() of an unknown code is not the internal one")
+	}
+}
@@ -0,0 +1,73 @@
+package apierror
+
+import (
+	"errors"
+
+	"havamal-api/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// TypeURI identifies a problem type. It is relative to the API and answers
+// with the description of the code.
+func TypeURI(code Code) string {
+	return "/problems/" + string(code)
+}
+
+// Render answers err as problem details: type, title, status, detail and
+// instance, plus the code, field errors on validation failures and any
+// fields given. Internal errors never expose their text.
+func Render(c *gin.Context, err error, fields gin.H) {
+	code := Lookup(err)
+	status := Status(code)
+	locale := i18n.FromRequest(c)
+	body := gin.H{
+		"type":     TypeURI(code),
+		"title":    Message(code, locale),
+		"status":   status,
+		"instance": c.Request.URL.RequestURI(),
+		"code":     code,
+	}
+	if detail := detail(err, code); detail != "" {
+		body["detail"] = detail
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		body["errors"] = invalid.localize(locale)
+	}
+	for key, value := range fields {
+		body[key] = value
+	}
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, body)
+}
+
+// detail is the context a domain error was wrapped with, e.g. how many posts
+// keep a category in use
+func detail(err error, code Code) string {
+	if code == Internal {
+		return ""
+	}
+	var coded *Error
+	if errors.As(err, &coded) && err.Error() != coded.Error() {
+		return err.Error()
+	}
+	return ""
+}
+
+// Describe answers the problem type named by the code parameter
+func Describe(c *gin.Context) {
+	code := Code(c.Param("code"))
+	if _, ok := catalogue[code]; !ok {
+		Write(c, NotFound)
+		return
+	}
+	c.JSON(200, gin.H{
+		"type":   TypeURI(code),
+		"code":   code,
+		"status": Status(code),
+		"title":  Message(code, i18n.FromRequest(c)),
+	})
+}
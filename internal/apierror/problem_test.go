@@ -0,0 +1,132 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"havamal-api/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestLookup(t *testing.T) {
+	_, invalidId := uuid.Parse("not-an-id")
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"catalogued", New(PostNotFound), PostNotFound},
+		{"wrapped", fmt.Errorf("%w: 3 posts", New(CategoryInUse)), CategoryInUse},
+		{"validation", InvalidField("title", "required", ""), Validation},
+		{"unsupported locale", i18n.ErrUnsupportedLocale, UnsupportedLocale},
+		{"default locale", i18n.ErrDefaultLocale, DefaultLocale},
+		{"malformed id", invalidId, InvalidId},
+		{"anything else", errors.New("pq: connection refused"), Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lookup(tt.err); got != tt.want {
+				t.Errorf("Lookup() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKinds(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{New(PostNotFound), ErrNotFound},
+		{New(SlugConflict), ErrConflict},
+		{New(InvalidId), ErrValidation},
+		{InvalidField("title", "required", ""), ErrValidation},
+		{New(AuthForbidden), ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if !errors.Is(tt.err, tt.kind) {
+				t.Errorf("errors.Is(%v, %v) = false", tt.err, tt.kind)
+			}
+		})
+	}
+	if errors.Is(New(PostNotFound), ErrConflict) {
+		t.Error("a not found error is a conflict")
+	}
+}
+
+func render(err error, fields gin.H, language string) (*httptest.ResponseRecorder, map[string]any) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/posts/1?fields=id", nil)
+	c.Request.Header.Set("Accept-Language", language)
+	Render(c, err, fields)
+	var body map[string]any
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	return recorder, body
+}
+
+func TestRender(t *testing.T) {
+	recorder, body := render(fmt.Errorf("%w: 3 posts", New(CategoryInUse)), gin.H{"current": 2}, "es")
+	if recorder.Code != http.StatusConflict || recorder.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("Render() answered %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	want := map[string]any{
+		"type":     "/problems/category.in_use",
+		"title":    Message(CategoryInUse, "es"),
+		"status":   float64(http.StatusConflict),
+		"detail":   Message(CategoryInUse, "en") + ": 3 posts",
+		"instance": "/api/posts/1?fields=id",
+		"code":     "category.in_use",
+		"current":  float64(2),
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("Render() %s = %v, want %v", key, body[key], value)
+		}
+	}
+}
+
+func TestRenderDetail(t *testing.T) {
+	// The detail is the context an error was wrapped with, never the text of
+	// an internal one
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail any
+	}{
+		{"plain", New(PostNotFound), http.StatusNotFound, nil},
+		{"internal", errors.New("pq: password authentication failed"), http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, body := render(tt.err, nil, "en")
+			if recorder.Code != tt.status || body["detail"] != tt.detail {
+				t.Errorf("Render() = %d %v, want %d %v", recorder.Code, body["detail"], tt.status, tt.detail)
+			}
+		})
+	}
+}
+
+func TestRenderValidation(t *testing.T) {
+	recorder, body := render(InvalidField("columns", "max", "4"), nil, "en")
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Render() answered %d, want 422", recorder.Code)
+	}
+	errs, _ := body["errors"].([]any)
+	if len(errs) != 1 {
+		t.Fatalf("Render() errors = %v, want one", body["errors"])
+	}
+	field := errs[0].(map[string]any)
+	if field["field"] != "columns" || field["rule"] != "max" || field["param"] != "4" || field["message"] != ruleMessage("max", "4", "en") {
+		t.Errorf("Render() field error = %v", field)
+	}
+}
@@ -0,0 +1,152 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"havamal-api/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a validation failure of one request field, named by its JSON
+// path
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the fields of a request that failed validation
+type ValidationError struct {
+	Code   Code
+	Fields []FieldError
+}
+
+// InvalidField returns a validation failure of a single field
+func InvalidField(field string, rule string, param string) *ValidationError {
+	return &ValidationError{Code: Validation, Fields: []FieldError{{Field: field, Rule: rule, Param: param}}}
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field.Field + " " + ruleMessage(field.Rule, field.Param, "en")
+	}
+	return Message(e.Code, "en") + ": " + strings.Join(fields, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) localize(locale string) []FieldError {
+	fields := make([]FieldError, len(e.Fields))
+	for i, field := range e.Fields {
+		field.Message = ruleMessage(field.Rule, field.Param, locale)
+		fields[i] = field
+	}
+	return fields
+}
+
+func ruleMessage(rule string, param string, locale string) string {
+	if alias, ok := ruleAliases[rule]; ok {
+		rule = alias
+	}
+	messages, ok := rules[rule]
+	if !ok {
+		messages = rules["invalid"]
+	}
+	message := messages[locale]
+	if message == "" {
+		message = messages[i18n.Default]
+	}
+	if strings.Contains(message, "%s") {
+		return fmt.Sprintf(message, param)
+	}
+	return message
+}
+
+// Invalid answers a request body that could not be bound: field errors when
+// it failed validation, request.invalid when it could not be decoded and the
+// error's own code when it has one
+func Invalid(c *gin.Context, err error) {
+	var failures validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var coded *Error
+	switch {
+	case errors.As(err, &failures):
+		invalid := &ValidationError{Code: Validation}
+		for _, failure := range failures {
+			field := FieldError{Field: fieldPath(failure.Namespace()), Rule: failure.Tag(), Param: failure.Param()}
+			if crossFieldRules[field.Rule] {
+				field.Param = ""
+			}
+			invalid.Fields = append(invalid.Fields, field)
+		}
+		Respond(c, invalid)
+	case errors.As(err, &typeErr):
+		Respond(c, InvalidField(typeErr.Field, "type", typeErr.Type.String()))
+	case errors.As(err, &coded):
+		Respond(c, err)
+	default:
+		Respond(c, fmt.Errorf("%w: %v", New(InvalidRequest), err))
+	}
+}
+
+// crossFieldRules name other Go fields in their parameter, which means
+// nothing to clients
+var crossFieldRules = map[string]bool{
+	"required_if":      true,
+	"required_without": true,
+	"excluded_unless":  true,
+	"excluded_if":      true,
+}
+
+// fieldPath drops the struct name validator puts in front of the JSON path
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validate is the engine behind binding tags. Failures name fields by their
+// JSON name rather than the Go one, and two rules are added: slug, for
+// lowercase words joined by single dashes, and locale, for supported locales.
+var validate = binding.Validator.Engine().(*validator.Validate)
+
+func init() {
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	validate.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+	validate.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		return i18n.IsSupported(fl.Field().String())
+	})
+}
+
+// RegisterStructRule adds a rule checking several fields of the given request
+// types together. It reports failures with StructLevel.ReportError, which
+// end up next to those of the field tags.
+func RegisterStructRule(rule validator.StructLevelFunc, types ...any) {
+	validate.RegisterStructValidation(rule, types...)
+}
@@ -0,0 +1,95 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type item struct {
+	Label string `json:"label" binding:"required"`
+}
+
+type request struct {
+	Title   string `json:"title" binding:"required,max=5"`
+	Status  string `json:"status" binding:"omitempty,oneof=draft published"`
+	Slug    string `json:"slug" binding:"omitempty,slug"`
+	Locale  string `json:"locale" binding:"omitempty,locale"`
+	Content string `json:"content" binding:"required_without=Blocks"`
+	Blocks  []item `json:"blocks" binding:"omitempty,dive"`
+}
+
+// bind binds body to a request and answers its error with Invalid
+func bind(body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	var r request
+	if err := c.ShouldBindJSON(&r); err != nil {
+		Invalid(c, err)
+	}
+	return c
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{"valid", `{"title": "Deyr", "slug": "deyr-fe", "locale": "en", "content": "Deyr fé"}`, nil},
+		{"every failing field", `{"title": "Hávamál", "status": "foo", "slug": "Deyr--Fé", "locale": "de"}`, []FieldError{
+			{Field: "title", Rule: "max", Param: "5"},
+			{Field: "status", Rule: "oneof", Param: "draft published"},
+			{Field: "slug", Rule: "slug"},
+			{Field: "locale", Rule: "locale"},
+			{Field: "content", Rule: "required_without"},
+		}},
+		{"nested field", `{"title": "Deyr", "blocks": [{"label": "a"}, {}]}`, []FieldError{
+			{Field: "blocks[1].label", Rule: "required"},
+		}},
+		{"wrong type", `{"title": 5}`, []FieldError{
+			{Field: "title", Rule: "type", Param: "string"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := bind(tt.body)
+			if tt.want == nil {
+				if len(c.Errors) != 0 {
+					t.Fatalf("Invalid() errors = %v, want none", c.Errors)
+				}
+				return
+			}
+			var invalid *ValidationError
+			if len(c.Errors) != 1 || !errors.As(c.Errors[0], &invalid) {
+				t.Fatalf("Invalid() errors = %v, want a validation error", c.Errors)
+			}
+			if !reflect.DeepEqual(invalid.Fields, tt.want) {
+				t.Errorf("Invalid() fields = %+v, want %+v", invalid.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidBody(t *testing.T) {
+	c := bind(`{"title": `)
+	if len(c.Errors) != 1 || Lookup(c.Errors[0]) != InvalidRequest {
+		t.Errorf("Invalid() errors = %v, want %s", c.Errors, InvalidRequest)
+	}
+}
+
+func TestRuleMessage(t *testing.T) {
+	if got := ruleMessage("max", "5", "en"); !strings.Contains(got, "5") {
+		t.Errorf("ruleMessage() = %q, want the parameter in it", got)
+	}
+	if got := ruleMessage("no_such_rule", "", "es"); got != rules["invalid"]["es"] {
+		t.Errorf("ruleMessage() of an unknown rule = %q", got)
+	}
+}
@@ -1,9 +1,9 @@
 package auth
 
-import "errors"
+import "havamal-api/internal/apierror"
 
 var (
-    ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserNotFound      = errors.New("user not found")
-	ErrInactiveUser      = errors.New("inactive user")
+    ErrInvalidCredentials = apierror.New(apierror.AuthInvalidCredentials)
+	ErrUserNotFound      = apierror.New(apierror.UserNotFound)
+	ErrInactiveUser      = apierror.New(apierror.AuthLocked)
 )
\ No newline at end of file
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"errors"
+	"havamal-api/internal/apierror"
 	"net/http"
 	"time"
 
@@ -24,23 +26,18 @@ func NewAuthHandler(authService AuthService, jwtMiddleware *jwt.GinJWTMiddleware
 func (h *AuthHandler) Login(c *gin.Context) {
     var loginRequest LoginRequest
     if err := c.ShouldBindJSON(&loginRequest); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login request"})
+        apierror.Invalid(c, err)
         return
     }
     
     token,user, expire, err := h.authService.Login(c.Request.Context(), loginRequest)
     if err != nil {
-        var statusCode int
-        switch err {
-        case ErrInvalidCredentials:
-            statusCode = http.StatusUnauthorized
-        case ErrInactiveUser:
-            statusCode = http.StatusForbidden
-        default:
-            statusCode = http.StatusInternalServerError
+        // Unknown users answer like wrong passwords so accounts cannot be probed
+        if errors.Is(err, ErrUserNotFound) {
+            apierror.Write(c, apierror.AuthInvalidCredentials)
+            return
         }
-        
-        c.JSON(statusCode, gin.H{"error": err.Error()})
+        apierror.Respond(c, err)
         return
     }
     
@@ -59,13 +56,13 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 
 	user, err := h.authService.Register(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"havamal-api/internal/openapi"
+	"net/http"
+
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 )
@@ -9,4 +12,18 @@ func RegisterRoutes(router *gin.RouterGroup, handler *AuthHandler, jwtMiddleware
 	router.POST("/login", handler.Login)
 	router.POST("/register", handler.Register)
 	router.GET("/refresh_token", jwtMiddleware.RefreshHandler)
-}
\ No newline at end of file
+}
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/login", Tag: "auth", Summary: "Log in", Body: LoginRequest{}, Response: LoginResponse{}},
+		{Method: "POST", Path: "/register", Tag: "auth", Summary: "Register a user", Body: RegisterRequest{}, Response: RegisterResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/refresh_token", Tag: "auth", Summary: "Refresh a token that has not expired yet", Authenticated: true,
+			Response: struct {
+				Code   int    `json:"code"`
+				Token  string `json:"token"`
+				Expire string `json:"expire"`
+			}{}},
+	}
+}
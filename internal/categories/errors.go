@@ -0,0 +1,13 @@
+package categories
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound            = apierror.New(apierror.CategoryNotFound)
+	ErrTranslationNotFound = apierror.New(apierror.TranslationNotFound)
+	ErrCategoryCycle       = apierror.New(apierror.CategoryCycle)
+	ErrCategoryInUse       = apierror.New(apierror.CategoryInUse)
+	ErrMergeIntoSelf       = apierror.New(apierror.CategoryMergeIntoSelf)
+	ErrUnknownCategory     = apierror.New(apierror.CategoryUnknown)
+	ErrDuplicateCategory   = apierror.New(apierror.CategoryDuplicate)
+)
@@ -1,7 +1,14 @@
 package categories
 
 import (
+	"errors"
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/patch"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,64 +24,218 @@ func NewHandler(service Service) Handler {
 func (h *Handler) Create(c *gin.Context) {
 	var category Request
 	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.Create(&category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, category)
 }
 
 func (h *Handler) GetAll(c *gin.Context) {
-	categories, err := h.service.GetAll()
+	locale := i18n.FromRequest(c)
+	relations := h.service.Relations(locale)
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	categories, err := h.service.GetAll(locale, selection)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	fields.JSON(c, selection, relations, categories)
+}
+
+func (h *Handler) GetTree(c *gin.Context) {
+	tree, err := h.service.GetTree(i18n.FromRequest(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, categories)
+	c.JSON(http.StatusOK, tree)
 }
 
 func (h *Handler) GetById(c *gin.Context) {
 	id := c.Param("id")
 	category, err := h.service.GetById(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, category)
+	etag.JSON(c, category.Revision, category)
 }
 
 func (h *Handler) GetBySlug(c *gin.Context) {
 	slug := c.Param("slug")
-	category, err := h.service.GetBySlug(slug)
+	category, err := h.service.GetBySlug(slug, i18n.FromRequest(c))
+	if errors.Is(err, ErrNotFound) {
+		h.redirectSlug(c, slug)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, category)
+	etag.JSON(c, category.Revision, category)
+}
+
+// conflict answers a stale write with the category as it is now
+func (h *Handler) conflict(c *gin.Context, id string) {
+	category, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Conflict(c, category.Revision, category)
+}
+
+// redirectSlug points an old slug at the category's current one
+func (h *Handler) redirectSlug(c *gin.Context, oldSlug string) {
+	slug, err := h.service.GetRedirect(oldSlug)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, oldSlug)+slug)
+	c.JSON(http.StatusMovedPermanently, gin.H{"slug": slug})
 }
 
 func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
 	var category Request
 	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}	
-	if err := h.service.Update(id, &category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Update(id, &category, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, category)
+	h.updated(c, id)
+}
+
+// Patch applies a merge patch or JSON Patch to the category and answers with
+// the updated category. Members the patch leaves out keep their current value.
+func (h *Handler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	current, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	request := current.request()
+	if err := patch.Apply(c, &request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.Update(id, &request, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
+		return
+	}
+	h.updated(c, id)
+}
+
+// updated answers a write with the category as stored, with its new ETag
+func (h *Handler) updated(c *gin.Context, id string) {
+	category, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Written(c, category.Revision, category)
+}
+
+func (h *Handler) Merge(c *gin.Context) {
+	id := c.Param("id")
+	var request MergeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.Merge(id, request.TargetId); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Category merged successfully"})
+}
+
+func (h *Handler) Reorder(c *gin.Context) {
+	var request OrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.Reorder(request.Ids); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Categories reordered successfully"})
 }
 
 func (h *Handler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.service.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	force := c.Query("force") == "true"
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(id, force, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
 }
+
+func (h *Handler) GetTranslations(c *gin.Context) {
+	translations, err := h.service.GetTranslations(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, translations)
+}
+
+func (h *Handler) SetTranslation(c *gin.Context) {
+	var request TranslationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	translation, err := h.service.SetTranslation(c.Param("id"), c.Param("locale"), &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, translation)
+}
+
+func (h *Handler) DeleteTranslation(c *gin.Context) {
+	if err := h.service.DeleteTranslation(c.Param("id"), c.Param("locale")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Translation deleted successfully"})
+}
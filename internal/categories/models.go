@@ -7,20 +7,74 @@ import (
 )
 
 type Request struct {
-	Name        string    `json:"name"`
-	Slug        string    `json:"slug"`
-	Description string    `json:"description"`
-	Order       int       `json:"order"`
+	Name        string    `json:"name" binding:"required,max=100"`
+	Slug        string    `json:"slug" binding:"omitempty,slug,max=200"`
+	Description string    `json:"description" binding:"max=1000"`
+	Order       int       `json:"order" binding:"min=0"`
+	ParentId    string    `json:"parent_id" binding:"omitempty,uuid"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type Category struct {
-	ID          uuid.UUID `json:"id"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	Order       int        `json:"order"`
+	ParentId    *uuid.UUID `json:"parent_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Revision    int        `json:"revision"`
+}
+
+// change is what category events tell about a category. The slug is left
+// out where the change does not read it, and the locale is set when only a
+// translation changed.
+type change struct {
+	ID           uuid.UUID `json:"id"`
+	Slug         string    `json:"slug,omitempty"`
+	PreviousSlug string    `json:"previous_slug,omitempty"`
+	Locale       string    `json:"locale,omitempty"`
+}
+
+// request returns the category as the body of a full update, for patches to
+// apply to
+func (c *Category) request() Request {
+	request := Request{Name: c.Name, Slug: c.Slug, Description: c.Description, Order: c.Order}
+	if c.ParentId != nil {
+		request.ParentId = c.ParentId.String()
+	}
+	return request
+}
+
+// TreeNode is a category with its children and published post counts, both
+// direct and including every descendant.
+type TreeNode struct {
+	Category
+	PostCount      int        `json:"post_count"`
+	TotalPostCount int        `json:"total_post_count"`
+	Children       []TreeNode `json:"children"`
+}
+
+// Translation is the name and description of a category in a locale other
+// than the default one
+type Translation struct {
+	CategoryId  uuid.UUID `json:"category_id"`
+	Locale      string    `json:"locale"`
 	Name        string    `json:"name"`
-	Slug        string    `json:"slug"`
 	Description string    `json:"description"`
-	Order       int       `json:"order"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TranslationRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=1000"`
+}
+
+type MergeRequest struct {
+	TargetId string `json:"target_id" binding:"required,uuid"`
+}
+
+type OrderRequest struct {
+	Ids []string `json:"ids" binding:"required,min=1,dive,uuid"`
 }
@@ -2,17 +2,33 @@ package categories
 
 import (
 	"database/sql"
+	"havamal-api/internal/db"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/slugs"
 
 	"github.com/google/uuid"
 )
 
 type Repository interface {
-	Create(category *Category) error
-	GetAll() ([]Category, error)
+	Create(category *Category, steps ...db.Step) error
+	GetAll(selection fields.Selection) ([]Category, error)
 	GetById(id uuid.UUID) (*Category, error)
 	GetBySlug(slug string) (*Category, error)
-	Update(category *Category) error
-	Delete(id uuid.UUID) error
+	SlugTaken(slug string, excludeId uuid.UUID) (bool, error)
+	GetRedirect(oldSlug string) (string, error)
+	GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error)
+	GetPublishedPostCounts() (map[uuid.UUID]int, error)
+	GetPublishedSubtreeCounts() (map[uuid.UUID]int, error)
+	CountPosts(id uuid.UUID) (int, error)
+	Update(category *Category, steps ...db.Step) error
+	Merge(sourceId uuid.UUID, targetId uuid.UUID, steps ...db.Step) error
+	Reorder(ids []uuid.UUID, steps ...db.Step) error
+	Delete(id uuid.UUID, revision int, steps ...db.Step) error
+	GetTranslations(locale string) (map[uuid.UUID]Translation, error)
+	GetCategoryTranslations(id uuid.UUID) ([]Translation, error)
+	SetTranslation(translation *Translation, steps ...db.Step) error
+	DeleteTranslation(id uuid.UUID, locale string, steps ...db.Step) error
 }
 
 type repository struct {
@@ -23,18 +39,45 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) Create(category *Category) error {
-	query := `INSERT INTO categories (id, name, slug, description, "order", created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := r.db.Exec(query, category.ID, category.Name, category.Slug, category.Description, category.Order, category.CreatedAt, category.UpdatedAt)
+// translate returns the domain error of a failed category query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, slugs.ErrTaken)
+}
+
+// Create inserts a category; steps run in the same transaction
+func (r *repository) Create(category *Category, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `INSERT INTO categories (id, name, slug, description, "order", parent_id, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := tx.Exec(query, category.ID, category.Name, category.Slug, category.Description, category.Order, nullableId(category.ParentId), category.CreatedAt, category.UpdatedAt); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *repository) GetAll() ([]Category, error) {
-	query := `SELECT id, name, slug, description, "order", created_at, updated_at
+// Fields are the members of a category, in the order GetAll scans them
+var Fields = fields.NewSet(
+	fields.Column{Name: "id", SQL: "id"},
+	fields.Column{Name: "name", SQL: "name", Empty: "''"},
+	fields.Column{Name: "slug", SQL: "slug", Empty: "''"},
+	fields.Column{Name: "description", SQL: "description", Empty: "''"},
+	fields.Column{Name: "order", SQL: `"order"`},
+	fields.Column{Name: "parent_id", SQL: "parent_id"},
+	fields.Column{Name: "created_at", SQL: "created_at"},
+	fields.Column{Name: "updated_at", SQL: "updated_at"},
+	fields.Column{Name: "revision", SQL: "revision"},
+)
+
+func (r *repository) GetAll(selection fields.Selection) ([]Category, error) {
+	query := `SELECT ` + selection.SQL() + `
 	FROM categories`
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -44,54 +87,371 @@ func (r *repository) GetAll() ([]Category, error) {
 	categories := make([]Category, 0)
 	for rows.Next() {
 		var category Category
-		if err := rows.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Order, &category.CreatedAt, &category.UpdatedAt); err != nil {
+		var parentID uuid.NullUUID
+		if err := rows.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Order, &parentID, &category.CreatedAt, &category.UpdatedAt, &category.Revision); err != nil {
 			return nil, err
 		}
+		if parentID.Valid {
+			category.ParentId = &parentID.UUID
+		}
 		categories = append(categories, category)
 	}
 	return categories, nil
 }
 
 func (r *repository) GetById(id uuid.UUID) (*Category, error) {
-	query := `SELECT id, name, slug, description, "order", created_at, updated_at
+	query := `SELECT id, name, slug, description, "order", parent_id, created_at, updated_at, revision
 	FROM categories
 	WHERE id = $1`
 	row := r.db.QueryRow(query, id)
 	var category Category
-	if err := row.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Order, &category.CreatedAt, &category.UpdatedAt); err != nil {
-		return nil, err
+	var parentID uuid.NullUUID
+	if err := row.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Order, &parentID, &category.CreatedAt, &category.UpdatedAt, &category.Revision); err != nil {
+		return nil, translate(err)
+	}
+	if parentID.Valid {
+		category.ParentId = &parentID.UUID
 	}
 	return &category, nil
 }
 
 func (r *repository) GetBySlug(slug string) (*Category, error) {
-	query := `SELECT id, name, slug, description, "order", created_at, updated_at
+	query := `SELECT id, name, slug, description, "order", parent_id, created_at, updated_at, revision
 	FROM categories
 	WHERE slug = $1`
 	row := r.db.QueryRow(query, slug)
 	var category Category
-	if err := row.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Order, &category.CreatedAt, &category.UpdatedAt); err != nil {
-		return nil, err
+	var parentID uuid.NullUUID
+	if err := row.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Order, &parentID, &category.CreatedAt, &category.UpdatedAt, &category.Revision); err != nil {
+		return nil, translate(err)
+	}
+	if parentID.Valid {
+		category.ParentId = &parentID.UUID
 	}
 	return &category, nil
 }
 
-func (r *repository) Update(category *Category) error {
+// GetAncestorIds returns id followed by the ids of all its ancestors
+func (r *repository) GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error) {
+	query := `WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM categories WHERE id = $1
+		UNION
+		SELECT c.id, c.parent_id FROM categories c
+			INNER JOIN ancestors a ON c.id = a.parent_id
+	)
+	SELECT id FROM ancestors`
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var ancestorId uuid.UUID
+		if err := rows.Scan(&ancestorId); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestorId)
+	}
+	return ids, nil
+}
+
+// GetPublishedPostCounts returns the number of published posts linked directly
+// to each category
+func (r *repository) GetPublishedPostCounts() (map[uuid.UUID]int, error) {
+	query := `SELECT pc.category_id, COUNT(*)
+	FROM post_categories pc
+		INNER JOIN posts p ON pc.post_id = p.id
+	WHERE p.status = 'published'
+	GROUP BY pc.category_id`
+	return r.countPosts(query)
+}
+
+// GetPublishedSubtreeCounts returns the number of distinct published posts
+// linked to each category or any of its descendants, so a post filed under
+// both a category and one of its children is counted once
+func (r *repository) GetPublishedSubtreeCounts() (map[uuid.UUID]int, error) {
+	query := `WITH RECURSIVE subtree (root_id, category_id) AS (
+		SELECT id, id FROM categories
+		UNION
+		SELECT s.root_id, c.id
+		FROM subtree s
+			INNER JOIN categories c ON c.parent_id = s.category_id
+	)
+	SELECT s.root_id, COUNT(DISTINCT pc.post_id)
+	FROM subtree s
+		INNER JOIN post_categories pc ON pc.category_id = s.category_id
+		INNER JOIN posts p ON pc.post_id = p.id
+	WHERE p.status = 'published'
+	GROUP BY s.root_id`
+	return r.countPosts(query)
+}
+
+func (r *repository) countPosts(query string) (map[uuid.UUID]int, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var categoryId uuid.UUID
+		var count int
+		if err := rows.Scan(&categoryId, &count); err != nil {
+			return nil, err
+		}
+		counts[categoryId] = count
+	}
+	return counts, nil
+}
+
+// Update only applies when category.Revision is still the current revision or
+// etag.Any, and keeps the previous slug as a redirect when it changes
+func (r *repository) Update(category *Category, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	redirect := `INSERT INTO slug_redirects (entity_type, old_slug, entity_id)
+	SELECT 'category', slug, id FROM categories WHERE id = $1 AND slug <> $2
+	ON CONFLICT (entity_type, old_slug) DO UPDATE SET entity_id = EXCLUDED.entity_id, created_at = NOW()`
+	if _, err := tx.Exec(redirect, category.ID, category.Slug); err != nil {
+		return translate(err)
+	}
+	if _, err := tx.Exec(`DELETE FROM slug_redirects WHERE entity_type = 'category' AND old_slug = $1`, category.Slug); err != nil {
+		return err
+	}
+
 	query := `UPDATE categories
-	SET name = $2, slug = $3, description = $4, "order" = $5, updated_at = $6
-	WHERE id = $1`
-	_, err := r.db.Exec(query, category.ID, category.Name, category.Slug, category.Description, category.Order, category.UpdatedAt)
+	SET name = $2, slug = $3, description = $4, "order" = $5, parent_id = $6, updated_at = $7, revision = revision + 1
+	WHERE id = $1 AND ($8 = 0 OR revision = $8)`
+	result, err := tx.Exec(query, category.ID, category.Name, category.Slug, category.Description, category.Order, nullableId(category.ParentId), category.UpdatedAt, category.Revision)
 	if err != nil {
+		return translate(err)
+	}
+	if err := etag.Check(result, tx, "categories", category.ID); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SlugTaken reports whether another category uses slug, either as its current
+// slug or as a previous one that still redirects to it
+func (r *repository) SlugTaken(slug string, excludeId uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM categories WHERE slug = $1 AND id <> $2)
+		OR EXISTS (SELECT 1 FROM slug_redirects WHERE entity_type = 'category' AND old_slug = $1 AND entity_id <> $2)`
+	var taken bool
+	err := r.db.QueryRow(query, slug, excludeId).Scan(&taken)
+	return taken, err
+}
+
+// GetRedirect returns the current slug of the category that used to be oldSlug
+func (r *repository) GetRedirect(oldSlug string) (string, error) {
+	query := `SELECT c.slug FROM slug_redirects sr
+		INNER JOIN categories c ON sr.entity_id = c.id
+	WHERE sr.entity_type = 'category' AND sr.old_slug = $1`
+	var slug string
+	err := r.db.QueryRow(query, oldSlug).Scan(&slug)
+	return slug, translate(err)
+}
+
+func (r *repository) CountPosts(id uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM post_categories WHERE category_id = $1`
+	var count int
+	if err := r.db.QueryRow(query, id).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Merge moves every post link, navigation reference and child category of the
+// source to the target and then deletes the source, in one transaction.
+func (r *repository) Merge(sourceId uuid.UUID, targetId uuid.UUID, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`INSERT INTO post_categories (post_id, category_id)
+		SELECT post_id, $2 FROM post_categories WHERE category_id = $1
+		ON CONFLICT DO NOTHING`,
+		`DELETE FROM post_categories WHERE category_id = $1`,
+		`UPDATE navigation SET category_id = $2 WHERE category_id = $1`,
+		`UPDATE categories SET parent_id = $2, updated_at = NOW(), revision = revision + 1 WHERE parent_id = $1 AND id <> $2`,
+		`UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = $1), updated_at = NOW(), revision = revision + 1
+		WHERE id = $2 AND parent_id = $1`,
+		// Links to the source, by its current or previous slugs, now lead to the target
+		`UPDATE slug_redirects SET entity_id = $2 WHERE entity_type = 'category' AND entity_id = $1`,
+		`INSERT INTO slug_redirects (entity_type, old_slug, entity_id)
+		SELECT 'category', slug, $2 FROM categories WHERE id = $1
+		ON CONFLICT (entity_type, old_slug) DO UPDATE SET entity_id = EXCLUDED.entity_id, created_at = NOW()`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, sourceId, targetId); err != nil {
+			return translate(err)
+		}
+	}
+	result, err := tx.Exec(`DELETE FROM categories WHERE id = $1`, sourceId)
+	if err != nil {
+		return translate(err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	if err := db.Run(tx, steps...); err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
-func (r *repository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM categories WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+// Reorder sets "order" to each category's position in ids, in one transaction
+func (r *repository) Reorder(ids []uuid.UUID, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `UPDATE categories SET "order" = $2, updated_at = NOW(), revision = revision + 1 WHERE id = $1`
+	for position, id := range ids {
+		result, err := tx.Exec(query, id, position)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return ErrUnknownCategory
+		}
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a category at its revision together with the redirects from
+// its old slugs, which would otherwise keep those slugs taken
+func (r *repository) Delete(id uuid.UUID, revision int, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM categories WHERE id = $1 AND ($2 = 0 OR revision = $2)`
+	result, err := tx.Exec(query, id, revision)
+	if err != nil {
+		return translate(err)
+	}
+	if err := etag.Check(result, tx, "categories", id); err != nil {
+		return translate(err)
+	}
+	if _, err := tx.Exec(`DELETE FROM slug_redirects WHERE entity_type = 'category' AND entity_id = $1`, id); err != nil {
+		return err
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTranslations returns the translations of every category into locale
+func (r *repository) GetTranslations(locale string) (map[uuid.UUID]Translation, error) {
+	query := `SELECT category_id, locale, name, description FROM category_translations WHERE locale = $1`
+	rows, err := r.db.Query(query, locale)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	translations := make(map[uuid.UUID]Translation)
+	for rows.Next() {
+		var translation Translation
+		if err := rows.Scan(&translation.CategoryId, &translation.Locale, &translation.Name, &translation.Description); err != nil {
+			return nil, err
+		}
+		translations[translation.CategoryId] = translation
+	}
+	return translations, nil
+}
+
+func (r *repository) GetCategoryTranslations(id uuid.UUID) ([]Translation, error) {
+	query := `SELECT category_id, locale, name, description FROM category_translations WHERE category_id = $1 ORDER BY locale`
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	translations := make([]Translation, 0)
+	for rows.Next() {
+		var translation Translation
+		if err := rows.Scan(&translation.CategoryId, &translation.Locale, &translation.Name, &translation.Description); err != nil {
+			return nil, err
+		}
+		translations = append(translations, translation)
+	}
+	return translations, nil
+}
+
+// SetTranslation creates or replaces a translation and bumps the category's
+// revision, since what readers see of it changes
+func (r *repository) SetTranslation(translation *Translation, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE categories SET updated_at = NOW(), revision = revision + 1 WHERE id = $1`, translation.CategoryId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	query := `INSERT INTO category_translations (category_id, locale, name, description)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (category_id, locale) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description`
+	if _, err := tx.Exec(query, translation.CategoryId, translation.Locale, translation.Name, translation.Description); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) DeleteTranslation(id uuid.UUID, locale string, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM category_translations WHERE category_id = $1 AND locale = $2`, id, locale)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrTranslationNotFound
+	}
+	if _, err := tx.Exec(`UPDATE categories SET updated_at = NOW(), revision = revision + 1 WHERE id = $1`, id); err != nil {
+		return err
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullableId(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
 }
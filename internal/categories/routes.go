@@ -1,16 +1,56 @@
 package categories
 
-import "github.com/gin-gonic/gin"
+import (
+	"havamal-api/internal/fields"
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/categories", handler.Create)
+	router.PUT("/categories/order", handler.Reorder)
 	router.PUT("/categories/:id", handler.Update)
+	router.PATCH("/categories/:id", handler.Patch)
+	router.POST("/categories/:id/merge", handler.Merge)
 	router.DELETE("/categories/:id", handler.Delete)
+	router.GET("/categories/:id/translations", handler.GetTranslations)
+	router.PUT("/categories/:id/translations/:locale", handler.SetTranslation)
+	router.DELETE("/categories/:id/translations/:locale", handler.DeleteTranslation)
 }
 
 func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.GET("/categories", handler.GetAll)
+	router.GET("/categories/tree", handler.GetTree)
 	router.GET("/categories/:id", handler.GetById)
 	router.GET("/categories/slug/:slug", handler.GetBySlug)
 }
 
+
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/categories", Tag: "categories", Summary: "Create a category", Body: Request{}, Response: Request{}},
+		{Method: "PUT", Path: "/categories/order", Tag: "categories", Summary: "Reorder categories", Body: OrderRequest{}},
+		{Method: "PUT", Path: "/categories/:id", Tag: "categories", Summary: "Replace a category", Body: Request{}, Response: Category{}, IfMatch: true},
+		{Method: "PATCH", Path: "/categories/:id", Tag: "categories", Summary: "Patch a category", Body: Request{}, Response: Category{}, IfMatch: true},
+		{Method: "POST", Path: "/categories/:id/merge", Tag: "categories", Summary: "Merge a category into another", Body: MergeRequest{}},
+		{Method: "DELETE", Path: "/categories/:id", Tag: "categories", Summary: "Delete a category",
+			Query: []openapi.Param{openapi.Flag("force", "Delete even when posts still use it")}, IfMatch: true},
+		{Method: "GET", Path: "/categories/:id/translations", Tag: "categories", Summary: "List a category's translations", Response: []Translation{}},
+		{Method: "PUT", Path: "/categories/:id/translations/:locale", Tag: "categories", Summary: "Set a category's translation", Body: TranslationRequest{}, Response: Translation{}},
+		{Method: "DELETE", Path: "/categories/:id/translations/:locale", Tag: "categories", Summary: "Delete a category's translation"},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/categories", Tag: "categories", Summary: "List categories", Query: append([]openapi.Param{openapi.Lang}, fields.Params(Fields, "parent", "children")...), Response: []Category{}},
+		{Method: "GET", Path: "/categories/tree", Tag: "categories", Summary: "Get the category tree with post counts", Query: []openapi.Param{openapi.Lang}, Response: []TreeNode{}},
+		{Method: "GET", Path: "/categories/:id", Tag: "categories", Summary: "Get a category", Response: Category{}, Cached: true},
+		{Method: "GET", Path: "/categories/slug/:slug", Tag: "categories", Summary: "Get a category by slug",
+			Description: "An old slug answers 301 with the current one in Location and the body.", Response: Category{}, Cached: true},
+	}
+}
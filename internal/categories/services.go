@@ -1,38 +1,191 @@
 package categories
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"havamal-api/internal/db"
+	"havamal-api/internal/events"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/slugs"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Service interface {
 	Create(category *Request) error
-	GetAll() ([]Category, error)
+	GetAll(locale string, selection fields.Selection) ([]Category, error)
+	// Relations are what lists of categories in locale can embed with ?include=
+	Relations(locale string) fields.Relations
+	GetTree(locale string) ([]TreeNode, error)
 	GetById(id string) (*Category, error)
-	GetBySlug(slug string) (*Category, error)
-	Update(id string, category *Request) error
-	Delete(id string) error
+	GetBySlug(slug string, locale string) (*Category, error)
+	GetRedirect(oldSlug string) (string, error)
+	Update(id string, category *Request, revision int) error
+	Merge(id string, targetId string) error
+	Reorder(ids []string) error
+	Delete(id string, force bool, revision int) error
+	GetTranslations(id string) ([]Translation, error)
+	SetTranslation(id string, locale string, request *TranslationRequest) (*Translation, error)
+	DeleteTranslation(id string, locale string) error
 }
 
 type service struct {
-	repo Repository
+	repo      Repository
+	publisher events.Publisher
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, publisher events.Publisher) Service {
+	return &service{repo: repo, publisher: publisher}
 }
 
 func (service *service) Create(category *Request) error {
+	parentId, err := parseParentId(category.ParentId)
+	if err != nil {
+		return err
+	}
+	id := uuid.New()
+	slug, err := service.uniqueSlug(category.Slug, category.Name, id)
+	if err != nil {
+		return err
+	}
+	category.Slug = slug
 	return service.repo.Create(&Category{
-		ID:          uuid.New(),
+		ID:          id,
 		Name:        category.Name,
-		Slug:        category.Slug,
+		Slug:        slug,
 		Description: category.Description,
 		Order:       category.Order,
-		CreatedAt:   category.CreatedAt,
-		UpdatedAt:   category.UpdatedAt,
-	})
+		ParentId:    parentId,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}, service.publisher.Publish(events.CategoryChanged, change{ID: id, Slug: slug}))
+}
+
+func (service *service) GetAll(locale string, selection fields.Selection) ([]Category, error) {
+	categories, err := service.repo.GetAll(selection)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.translate(locale, categories...); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// Relations embeds the parent and the children of categories. Both are read
+// from the whole list, which is short and read in one query anyway.
+func (service *service) Relations(locale string) fields.Relations {
+	return fields.Relations{
+		"parent": {Key: "parent_id", Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			all, err := service.GetAll(locale, Fields.All())
+			if err != nil {
+				return nil, err
+			}
+			parents := make(map[uuid.UUID]any, len(ids))
+			for _, category := range all {
+				parents[category.ID] = category
+			}
+			return parents, nil
+		}},
+		"children": {Key: "id", Many: true, Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			all, err := service.GetAll(locale, Fields.All())
+			if err != nil {
+				return nil, err
+			}
+			sortCategories(all)
+			children := make(map[uuid.UUID][]Category)
+			for _, category := range all {
+				if category.ParentId != nil {
+					children[*category.ParentId] = append(children[*category.ParentId], category)
+				}
+			}
+			loaded := make(map[uuid.UUID]any, len(children))
+			for id, list := range children {
+				loaded[id] = list
+			}
+			return loaded, nil
+		}},
+	}
 }
 
-func (service *service) GetAll() ([]Category, error) {
-	return service.repo.GetAll()
+// translate replaces names and descriptions with their translation into
+// locale where there is one
+func (service *service) translate(locale string, categories ...Category) error {
+	if locale == "" || locale == i18n.Default {
+		return nil
+	}
+	translations, err := service.repo.GetTranslations(locale)
+	if err != nil {
+		return err
+	}
+	for i := range categories {
+		if translation, ok := translations[categories[i].ID]; ok {
+			categories[i].Name = translation.Name
+			if translation.Description != "" {
+				categories[i].Description = translation.Description
+			}
+		}
+	}
+	return nil
+}
+
+// GetTree returns the root categories with their descendants nested and
+// ordered by order, then name.
+func (service *service) GetTree(locale string) ([]TreeNode, error) {
+	categories, err := service.GetAll(locale, Fields.All())
+	if err != nil {
+		return nil, err
+	}
+	counts, err := service.repo.GetPublishedPostCounts()
+	if err != nil {
+		return nil, err
+	}
+	totals, err := service.repo.GetPublishedSubtreeCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[uuid.UUID]bool, len(categories))
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+	children := make(map[uuid.UUID][]Category)
+	roots := make([]Category, 0)
+	for _, category := range categories {
+		if category.ParentId != nil && known[*category.ParentId] {
+			children[*category.ParentId] = append(children[*category.ParentId], category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+
+	var build func(items []Category) []TreeNode
+	build = func(items []Category) []TreeNode {
+		sortCategories(items)
+		nodes := make([]TreeNode, 0, len(items))
+		for _, category := range items {
+			node := TreeNode{
+				Category:       category,
+				PostCount:      counts[category.ID],
+				TotalPostCount: totals[category.ID],
+				Children:       build(children[category.ID]),
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
+func sortCategories(items []Category) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Order != items[j].Order {
+			return items[i].Order < items[j].Order
+		}
+		return items[i].Name < items[j].Name
+	})
 }
 
 func (service *service) GetById(id string) (*Category, error) {
@@ -43,29 +196,236 @@ func (service *service) GetById(id string) (*Category, error) {
 	return service.repo.GetById(parsedId)
 }
 
-func (service *service) GetBySlug(slug string) (*Category, error) {
-	return service.repo.GetBySlug(slug)
+func (service *service) GetBySlug(slug string, locale string) (*Category, error) {
+	category, err := service.repo.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	translated := []Category{*category}
+	if err := service.translate(locale, translated...); err != nil {
+		return nil, err
+	}
+	return &translated[0], nil
 }
 
-func (service *service) Update(id string, category *Request) error {
+func (service *service) Update(id string, category *Request, revision int) error {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return err
 	}
+	parentId, err := parseParentId(category.ParentId)
+	if err != nil {
+		return err
+	}
+	if err := service.checkParent(parsedId, parentId); err != nil {
+		return err
+	}
+	existing, err := service.repo.GetById(parsedId)
+	if err != nil {
+		return err
+	}
+	// An omitted slug keeps the current one rather than regenerating it
+	slug := category.Slug
+	if slug == "" {
+		slug = existing.Slug
+	} else if slug, err = service.uniqueSlug(slug, category.Name, parsedId); err != nil {
+		return err
+	}
+	category.Slug = slug
+	updated := change{ID: parsedId, Slug: slug}
+	if slug != existing.Slug {
+		updated.PreviousSlug = existing.Slug
+	}
 	return service.repo.Update(&Category{
 		ID:          parsedId,
 		Name:        category.Name,
-		Slug:        category.Slug,
+		Slug:        slug,
 		Description: category.Description,
 		Order:       category.Order,
-		UpdatedAt:   category.UpdatedAt,
+		ParentId:    parentId,
+		UpdatedAt:   time.Now(),
+		Revision:    revision,
+	}, service.publisher.Publish(events.CategoryChanged, updated))
+}
+
+// GetRedirect returns the current slug of a category from one of its old slugs
+func (service *service) GetRedirect(oldSlug string) (string, error) {
+	return service.repo.GetRedirect(oldSlug)
+}
+
+// uniqueSlug normalises the requested slug, or the name when none is given,
+// and adds a numeric suffix if another category already uses it
+func (service *service) uniqueSlug(requested string, name string, id uuid.UUID) (string, error) {
+	base := requested
+	if base == "" {
+		base = name
+	}
+	return slugs.Unique(slugs.Make(base), func(slug string) (bool, error) {
+		return service.repo.SlugTaken(slug, id)
 	})
 }
 
-func (service *service) Delete(id string) error {
+// checkParent rejects moving a category under itself or one of its descendants
+func (service *service) checkParent(id uuid.UUID, parentId *uuid.UUID) error {
+	if parentId == nil {
+		return nil
+	}
+	ancestors, err := service.repo.GetAncestorIds(*parentId)
+	if err != nil {
+		return err
+	}
+	for _, ancestorId := range ancestors {
+		if ancestorId == id {
+			return ErrCategoryCycle
+		}
+	}
+	return nil
+}
+
+// Merge folds a category into the target. The target may be a direct child of
+// the source, which then takes its place, but not a deeper descendant.
+func (service *service) Merge(id string, targetId string) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	parsedTargetId, err := uuid.Parse(targetId)
+	if err != nil {
+		return err
+	}
+	if parsedId == parsedTargetId {
+		return ErrMergeIntoSelf
+	}
+	source, err := service.repo.GetById(parsedId)
+	if err != nil {
+		return err
+	}
+	target, err := service.repo.GetById(parsedTargetId)
+	if err != nil {
+		return err
+	}
+	if target.ParentId == nil || *target.ParentId != parsedId {
+		ancestors, err := service.repo.GetAncestorIds(parsedTargetId)
+		if err != nil {
+			return err
+		}
+		for _, ancestorId := range ancestors {
+			if ancestorId == parsedId {
+				return ErrMergeIntoSelf
+			}
+		}
+	}
+	return service.repo.Merge(parsedId, parsedTargetId,
+		service.publisher.Publish(events.CategoryDeleted, change{ID: source.ID, Slug: source.Slug}),
+		service.publisher.Publish(events.CategoryChanged, change{ID: target.ID, Slug: target.Slug}),
+	)
+}
+
+func (service *service) Reorder(ids []string) error {
+	parsedIds := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		parsedId, err := uuid.Parse(id)
+		if err != nil {
+			return err
+		}
+		if seen[parsedId] {
+			return ErrDuplicateCategory
+		}
+		seen[parsedId] = true
+		parsedIds = append(parsedIds, parsedId)
+	}
+	published := make([]db.Step, 0, len(parsedIds))
+	for _, id := range parsedIds {
+		published = append(published, service.publisher.Publish(events.CategoryChanged, change{ID: id}))
+	}
+	return service.repo.Reorder(parsedIds, published...)
+}
+
+// Delete refuses to remove a category that posts still reference unless force
+// is set, since their links would silently disappear.
+func (service *service) Delete(id string, force bool, revision int) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	if !force {
+		count, err := service.repo.CountPosts(parsedId)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w (%d posts)", ErrCategoryInUse, count)
+		}
+	}
+	existing, err := service.repo.GetById(parsedId)
+	if err != nil {
+		return err
+	}
+	return service.repo.Delete(parsedId, revision, service.publisher.Publish(events.CategoryDeleted, change{ID: existing.ID, Slug: existing.Slug}))
+}
+
+func (service *service) GetTranslations(id string) ([]Translation, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := service.repo.GetById(parsedId); err != nil {
+		return nil, err
+	}
+	return service.repo.GetCategoryTranslations(parsedId)
+}
+
+// SetTranslation stores the name and description of a category in locale.
+// The default locale is edited on the category itself.
+func (service *service) SetTranslation(id string, locale string, request *TranslationRequest) (*Translation, error) {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
+		return nil, err
+	}
+	if err := checkTranslationLocale(locale); err != nil {
+		return nil, err
+	}
+	translation := &Translation{
+		CategoryId:  parsedId,
+		Locale:      locale,
+		Name:        request.Name,
+		Description: request.Description,
+	}
+	if err := service.repo.SetTranslation(translation, service.publisher.Publish(events.CategoryChanged, change{ID: parsedId, Locale: locale})); err != nil {
+		return nil, err
+	}
+	return translation, nil
+}
+
+func (service *service) DeleteTranslation(id string, locale string) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	if err := checkTranslationLocale(locale); err != nil {
 		return err
 	}
-	return service.repo.Delete(parsedId)
-}
\ No newline at end of file
+	return service.repo.DeleteTranslation(parsedId, locale, service.publisher.Publish(events.CategoryChanged, change{ID: parsedId, Locale: locale}))
+}
+
+func checkTranslationLocale(locale string) error {
+	if !i18n.IsSupported(locale) {
+		return i18n.ErrUnsupportedLocale
+	}
+	if locale == i18n.Default {
+		return i18n.ErrDefaultLocale
+	}
+	return nil
+}
+
+func parseParentId(parentId string) (*uuid.UUID, error) {
+	if parentId == "" {
+		return nil, nil
+	}
+	parsed, err := uuid.Parse(parentId)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
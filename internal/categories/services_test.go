@@ -0,0 +1,300 @@
+package categories
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"havamal-api/internal/db"
+	"havamal-api/internal/events"
+	"havamal-api/internal/fields"
+
+	"github.com/google/uuid"
+)
+
+// errStored stands for the repository write a test expects to be reached
+var errStored = errors.New("stored")
+
+// errMissing is what the fake answers for a category it does not have
+var errMissing = errors.New("missing")
+
+type fakeRepository struct {
+	Repository
+	categories   []Category
+	counts       map[uuid.UUID]int
+	totals       map[uuid.UUID]int
+	posts        int
+	translations map[uuid.UUID]Translation
+	reordered    []uuid.UUID
+}
+
+// fakePublisher records the events whose steps a repository write ran
+type fakePublisher struct {
+	published []events.Type
+}
+
+func (f *fakePublisher) Publish(eventType events.Type, data any) db.Step {
+	return func(tx *sql.Tx) error {
+		f.published = append(f.published, eventType)
+		return nil
+	}
+}
+
+func (f *fakeRepository) GetAll(selection fields.Selection) ([]Category, error) {
+	return append([]Category(nil), f.categories...), nil
+}
+
+func (f *fakeRepository) GetById(id uuid.UUID) (*Category, error) {
+	for _, category := range f.categories {
+		if category.ID == id {
+			return &category, nil
+		}
+	}
+	return nil, errMissing
+}
+
+func (f *fakeRepository) GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	for current, err := f.GetById(id); err == nil; current, err = f.GetById(*current.ParentId) {
+		ids = append(ids, current.ID)
+		if current.ParentId == nil {
+			break
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeRepository) GetPublishedPostCounts() (map[uuid.UUID]int, error) {
+	return f.counts, nil
+}
+
+func (f *fakeRepository) GetPublishedSubtreeCounts() (map[uuid.UUID]int, error) {
+	return f.totals, nil
+}
+
+func (f *fakeRepository) CountPosts(id uuid.UUID) (int, error) {
+	return f.posts, nil
+}
+
+func (f *fakeRepository) Merge(sourceId uuid.UUID, targetId uuid.UUID, steps ...db.Step) error {
+	db.Run(nil, steps...)
+	return errStored
+}
+
+func (f *fakeRepository) Reorder(ids []uuid.UUID, steps ...db.Step) error {
+	f.reordered = ids
+	db.Run(nil, steps...)
+	return errStored
+}
+
+func (f *fakeRepository) Delete(id uuid.UUID, revision int, steps ...db.Step) error {
+	db.Run(nil, steps...)
+	return errStored
+}
+
+func (f *fakeRepository) GetTranslations(locale string) (map[uuid.UUID]Translation, error) {
+	return f.translations, nil
+}
+
+// family is a root with a child and a grandchild, plus an unrelated root
+func family() (root, child, grandchild, other Category) {
+	root = Category{ID: uuid.New(), Name: "Eddas", Slug: "eddas"}
+	child = Category{ID: uuid.New(), Name: "Poetic", Slug: "poetic", ParentId: &root.ID}
+	grandchild = Category{ID: uuid.New(), Name: "Hávamál", Slug: "havamal", ParentId: &child.ID}
+	other = Category{ID: uuid.New(), Name: "Sagas", Slug: "sagas"}
+	return
+}
+
+func TestGetTree(t *testing.T) {
+	root, child, grandchild, other := family()
+	other.Order = -1
+	sibling := Category{ID: uuid.New(), Name: "Prose", Slug: "prose", ParentId: &root.ID}
+	missing := uuid.New()
+	orphan := Category{ID: uuid.New(), Name: "Orphan", Slug: "orphan", Order: 5, ParentId: &missing}
+	repository := &fakeRepository{
+		categories: []Category{grandchild, sibling, orphan, child, root, other},
+		counts:     map[uuid.UUID]int{root.ID: 1, grandchild.ID: 2},
+		totals:     map[uuid.UUID]int{root.ID: 3, child.ID: 2, grandchild.ID: 2},
+	}
+	tree, err := (&service{repo: repository}).GetTree("")
+	if err != nil {
+		t.Fatalf("GetTree() error = %v", err)
+	}
+
+	var names func(nodes []TreeNode) string
+	names = func(nodes []TreeNode) string {
+		parts := make([]string, len(nodes))
+		for i, node := range nodes {
+			parts[i] = node.Slug
+			if len(node.Children) > 0 {
+				parts[i] += "(" + names(node.Children) + ")"
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	// Ordered by order, then name; a category whose parent is gone is a root
+	if got, want := names(tree), "sagas eddas(poetic(havamal) prose) orphan"; got != want {
+		t.Errorf("GetTree() = %s, want %s", got, want)
+	}
+	eddas := tree[1]
+	if eddas.PostCount != 1 || eddas.TotalPostCount != 3 {
+		t.Errorf("eddas counts %d/%d, want 1/3", eddas.PostCount, eddas.TotalPostCount)
+	}
+	if poetic := eddas.Children[0]; poetic.PostCount != 0 || poetic.TotalPostCount != 2 {
+		t.Errorf("poetic counts %d/%d, want 0/2", poetic.PostCount, poetic.TotalPostCount)
+	}
+}
+
+func TestCheckParent(t *testing.T) {
+	root, child, grandchild, other := family()
+	s := &service{repo: &fakeRepository{categories: []Category{root, child, grandchild, other}}}
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		parentId *uuid.UUID
+		wantErr  error
+	}{
+		{"no parent", child.ID, nil, nil},
+		{"another branch", child.ID, &other.ID, nil},
+		{"own parent", grandchild.ID, &child.ID, nil},
+		{"itself", child.ID, &child.ID, ErrCategoryCycle},
+		{"its child", root.ID, &child.ID, ErrCategoryCycle},
+		{"a deeper descendant", root.ID, &grandchild.ID, ErrCategoryCycle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.checkParent(tt.id, tt.parentId); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkParent() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	root, child, grandchild, other := family()
+	publisher := &fakePublisher{}
+	s := &service{repo: &fakeRepository{categories: []Category{root, child, grandchild, other}}, publisher: publisher}
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		targetId uuid.UUID
+		wantErr  error
+	}{
+		{"into another branch", child.ID, other.ID, errStored},
+		{"into a direct child, which takes its place", root.ID, child.ID, errStored},
+		{"into its parent", grandchild.ID, child.ID, errStored},
+		{"into itself", child.ID, child.ID, ErrMergeIntoSelf},
+		{"into a deeper descendant", root.ID, grandchild.ID, ErrMergeIntoSelf},
+		{"from a missing category", uuid.New(), other.ID, errMissing},
+		{"into a missing category", child.ID, uuid.New(), errMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher.published = nil
+			if err := s.Merge(tt.id.String(), tt.targetId.String()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			// The events are written with the merge or not at all
+			var want []events.Type
+			if tt.wantErr == errStored {
+				want = []events.Type{events.CategoryDeleted, events.CategoryChanged}
+			}
+			if !reflect.DeepEqual(publisher.published, want) {
+				t.Errorf("Merge() published %v, want %v", publisher.published, want)
+			}
+		})
+	}
+}
+
+func TestReorder(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr error
+	}{
+		{"every id once", []string{second.String(), first.String()}, errStored},
+		{"listed twice", []string{first.String(), second.String(), first.String()}, ErrDuplicateCategory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, publisher := &fakeRepository{}, &fakePublisher{}
+			if err := (&service{repo: repository, publisher: publisher}).Reorder(tt.ids); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reorder() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errStored && (len(repository.reordered) != 2 || repository.reordered[0] != second) {
+				t.Errorf("Reorder() stored %v, want the order given", repository.reordered)
+			}
+			if tt.wantErr == errStored && len(publisher.published) != 2 {
+				t.Errorf("Reorder() published %v, want a change per category", publisher.published)
+			}
+		})
+	}
+	if err := (&service{repo: &fakeRepository{}, publisher: &fakePublisher{}}).Reorder([]string{"first"}); err == nil {
+		t.Error("Reorder() accepted a malformed id")
+	}
+}
+
+func TestDeleteInUse(t *testing.T) {
+	root, _, _, _ := family()
+	tests := []struct {
+		name    string
+		posts   int
+		force   bool
+		wantErr error
+	}{
+		{"unused", 0, false, errStored},
+		{"in use", 3, false, ErrCategoryInUse},
+		{"in use, forced", 3, true, errStored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{repo: &fakeRepository{categories: []Category{root}, posts: tt.posts}, publisher: &fakePublisher{}}
+			err := s.Delete(root.ID.String(), tt.force, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrCategoryInUse && !strings.Contains(err.Error(), "3 posts") {
+				t.Errorf("Delete() error = %q, want it to tell how many posts", err)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	root, child, _, _ := family()
+	root.Description = "Poems"
+	child.Description = "Eddic poems"
+	repository := &fakeRepository{
+		categories: []Category{root, child},
+		translations: map[uuid.UUID]Translation{
+			root.ID:  {CategoryId: root.ID, Locale: "en", Name: "Eddas"},
+			child.ID: {CategoryId: child.ID, Locale: "en", Name: "Poetic Edda", Description: "The poems"},
+		},
+	}
+	s := &service{repo: repository}
+	got, err := s.GetAll("en", Fields.All())
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	// An empty translated description keeps the original one
+	if got[0].Name != "Eddas" || got[0].Description != "Poems" {
+		t.Errorf("GetAll() = %+v, want the name translated", got[0])
+	}
+	if got[1].Name != "Poetic Edda" || got[1].Description != "The poems" {
+		t.Errorf("GetAll() = %+v, want name and description translated", got[1])
+	}
+
+	repository.translations = nil
+	if got, err := s.GetAll("ca", Fields.All()); err != nil || got[1].Name != "Poetic" {
+		t.Errorf("GetAll() in the default locale = %+v, %v", got, err)
+	}
+	if _, err := s.SetTranslation(root.ID.String(), "ca", &TranslationRequest{Name: "x"}); err == nil {
+		t.Error("SetTranslation() accepted the default locale")
+	}
+	if _, err := s.SetTranslation(root.ID.String(), "de", &TranslationRequest{Name: "x"}); err == nil {
+		t.Error("SetTranslation() accepted an unsupported locale")
+	}
+}
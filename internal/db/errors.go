@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"havamal-api/internal/apierror"
+
+	"github.com/lib/pq"
+)
+
+var (
+	errInvalidReference = apierror.New(apierror.InvalidReference)
+	errInvalidValue     = apierror.New(apierror.InvalidRequest)
+)
+
+// Translate turns a driver error into the domain error repositories return:
+// notFound for a missing row and conflict for a unique violation, which
+// defaults to a generic conflict when nil. Foreign key violations and values
+// the column rejects are invalid requests. Anything else is returned as is.
+func Translate(err error, notFound error, conflict error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	var driverErr *pq.Error
+	if !errors.As(err, &driverErr) {
+		return err
+	}
+	switch driverErr.Code {
+	case "23505":
+		if conflict == nil {
+			return apierror.ErrConflict
+		}
+		return conflict
+	case "23503":
+		return errInvalidReference
+	case "22P02":
+		return errInvalidValue
+	}
+	return err
+}
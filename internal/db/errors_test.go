@@ -0,0 +1,42 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"havamal-api/internal/apierror"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslate(t *testing.T) {
+	notFound := apierror.New(apierror.PostNotFound)
+	conflict := apierror.New(apierror.SlugConflict)
+	canceled := &pq.Error{Code: "57014"}
+	unexpected := errors.New("connection reset")
+	tests := []struct {
+		name     string
+		err      error
+		conflict error
+		want     error
+	}{
+		{"no error", nil, conflict, nil},
+		{"missing row", sql.ErrNoRows, conflict, notFound},
+		{"wrapped missing row", fmt.Errorf("scanning: %w", sql.ErrNoRows), conflict, notFound},
+		{"unique violation", &pq.Error{Code: "23505"}, conflict, conflict},
+		{"unique violation, no conflict given", &pq.Error{Code: "23505"}, nil, apierror.ErrConflict},
+		{"foreign key violation", &pq.Error{Code: "23503"}, conflict, errInvalidReference},
+		{"rejected value", &pq.Error{Code: "22P02"}, conflict, errInvalidValue},
+		{"other driver error", canceled, conflict, canceled},
+		{"anything else", unexpected, conflict, unexpected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Translate(tt.err, notFound, tt.conflict); got != tt.want {
+				t.Errorf("Translate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package db
+
+import "database/sql"
+
+// Step is a write another package adds to a repository's transaction, so it
+// commits or rolls back together with the change it belongs to, e.g. the tags
+// of a post being saved
+type Step func(tx *sql.Tx) error
+
+// Run runs steps in order on tx and stops at the first that fails
+func Run(tx *sql.Tx, steps ...Step) error {
+	for _, step := range steps {
+		if err := step(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package etag implements optimistic concurrency for resources carrying a
+// revision counter: ETag headers on reads, If-None-Match for 304 responses and
+// mandatory If-Match on writes.
+package etag
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"havamal-api/internal/apierror"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPreconditionRequired = apierror.New(apierror.PreconditionRequired)
+	ErrInvalidETag          = apierror.New(apierror.InvalidETag)
+	ErrPreconditionFailed   = apierror.New(apierror.RevisionConflict)
+)
+
+// Any is the revision of If-Match: *, which every current revision matches.
+// Revisions start at 1, so guarded writes take it as a wildcard.
+const Any = 0
+
+// Format returns the strong ETag of a revision
+func Format(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// Tag returns the strong ETag of a representation of revision: the revision,
+// which If-Match reads back, and a digest of the body, so that two bodies of
+// one revision never share a tag. They differ with the render mode and with
+// related resources, such as a renamed category, that do not bump the
+// revision.
+func Tag(revision int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.Itoa(revision) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// JSON answers value with its ETag, or 304 when the request's If-None-Match
+// already names it
+func JSON(c *gin.Context, revision int, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	tag := Tag(revision, body)
+	c.Header("ETag", tag)
+	if matches(c.GetHeader("If-None-Match"), tag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// matches reports whether an If-None-Match header names tag, weakly compared
+func matches(header string, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == tag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch returns the revision the client last read, from If-Match, which may
+// be a tag of Format or of Tag, or Any for *
+func IfMatch(c *gin.Context) (int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, ErrPreconditionRequired
+	}
+	if header == "*" {
+		return Any, nil
+	}
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return 0, ErrInvalidETag
+	}
+	// Tags of reads carry a digest after the revision
+	value, _, _ := strings.Cut(header[1:len(header)-1], "-")
+	revision, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, ErrInvalidETag
+	}
+	return revision, nil
+}
+
+// RequireIfMatch reads If-Match, answering 428 or 400 and returning false when
+// it is missing or malformed
+func RequireIfMatch(c *gin.Context) (int, bool) {
+	revision, err := IfMatch(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return 0, false
+	}
+	return revision, true
+}
+
+// Written answers the resource a write left behind with the ETag a read of it
+// gets, so the client can write again without reading first
+func Written(c *gin.Context, revision int, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.Header("ETag", Tag(revision, body))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// Conflict answers 412 with the current state of the resource and its ETag
+func Conflict(c *gin.Context, revision int, current any) {
+	c.Header("ETag", Format(revision))
+	apierror.Write(c, apierror.RevisionConflict, gin.H{"current": current})
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Check explains a write guarded by a revision: nil when it affected a row,
+// sql.ErrNoRows when the row is gone and ErrPreconditionFailed when the row
+// has moved on to another revision
+func Check(result sql.Result, db queryRower, table string, id uuid.UUID) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrPreconditionFailed
+	}
+	return sql.ErrNoRows
+}
@@ -0,0 +1,119 @@
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func request(header string, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		c.Request.Header.Set(header, value)
+	}
+	return c, recorder
+}
+
+func TestTagVaries(t *testing.T) {
+	base := Tag(3, []byte(`{"content":"# Title"}`))
+	tests := []struct {
+		name     string
+		revision int
+		body     string
+		same     bool
+	}{
+		{"same body", 3, `{"content":"# Title"}`, true},
+		{"render mode", 3, `{"content":"<h1>Title</h1>"}`, false},
+		{"renamed category", 3, `{"content":"# Title","category":"News"}`, false},
+		{"next revision", 4, `{"content":"# Title"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tag(tt.revision, []byte(tt.body)) == base; got != tt.same {
+				t.Errorf("Tag() equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	body := map[string]string{"content": "# Title"}
+	c, recorder := request("", "")
+	JSON(c, 3, body)
+	tag := recorder.Header().Get("ETag")
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		value       any
+		want        int
+	}{
+		{"no header", "", body, http.StatusOK},
+		{"same tag", tag, body, http.StatusNotModified},
+		{"weak and listed", `"1-00", W/` + tag, body, http.StatusNotModified},
+		{"wildcard", "*", body, http.StatusNotModified},
+		{"revision only", `"3"`, body, http.StatusOK},
+		{"other body", tag, map[string]string{"content": "<h1>Title</h1>"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := request("If-None-Match", tt.ifNoneMatch)
+			JSON(c, 3, tt.value)
+			c.Writer.WriteHeaderNow()
+			if recorder.Code != tt.want {
+				t.Errorf("JSON() status = %d, want %d", recorder.Code, tt.want)
+			}
+			if recorder.Header().Get("ETag") == "" {
+				t.Error("JSON() sent no ETag")
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    int
+		wantErr error
+	}{
+		{"revision", `"3"`, 3, nil},
+		{"tag of a read", Tag(7, []byte("{}")), 7, nil},
+		{"any revision", "*", Any, nil},
+		{"missing", "", 0, ErrPreconditionRequired},
+		{"unquoted", "3", 0, ErrInvalidETag},
+		{"not a revision", `"abc"`, 0, ErrInvalidETag},
+		{"lone quote", `"`, 0, ErrInvalidETag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := request("If-Match", tt.header)
+			got, err := IfMatch(c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IfMatch() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IfMatch() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritten(t *testing.T) {
+	body := map[string]string{"content": "# Title"}
+	c, recorder := request("", "")
+	JSON(c, 4, body)
+	read := recorder.Header().Get("ETag")
+
+	c, recorder = request("", "")
+	Written(c, 4, body)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") != read {
+		t.Errorf("Written() = %d %s, want 200 %s", recorder.Code, recorder.Header().Get("ETag"), read)
+	}
+}
@@ -0,0 +1,73 @@
+// Package events names what happens to content, so that services can tell
+// whoever listens, such as the webhooks that rebuild and purge the static
+// site, without knowing about them.
+package events
+
+import (
+	"time"
+
+	"havamal-api/internal/db"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	PostCreated     Type = "post.created"
+	PostUpdated     Type = "post.updated"
+	PostPublished   Type = "post.published"
+	PostUnpublished Type = "post.unpublished"
+	PostDeleted     Type = "post.deleted"
+
+	CategoryChanged Type = "category.changed"
+	CategoryDeleted Type = "category.deleted"
+
+	NavigationChanged Type = "navigation.changed"
+	NavigationDeleted Type = "navigation.deleted"
+	MenuChanged       Type = "menu.changed"
+	MenuDeleted       Type = "menu.deleted"
+
+	MediaUploaded Type = "media.uploaded"
+	MediaChanged  Type = "media.changed"
+	MediaDeleted  Type = "media.deleted"
+)
+
+// Types lists every event, in the order they are documented
+var Types = []Type{
+	PostCreated, PostUpdated, PostPublished, PostUnpublished, PostDeleted,
+	CategoryChanged, CategoryDeleted,
+	NavigationChanged, NavigationDeleted, MenuChanged, MenuDeleted,
+	MediaUploaded, MediaChanged, MediaDeleted,
+}
+
+// Known reports whether name is the name of an event
+func Known(name string) bool {
+	for _, t := range Types {
+		if string(t) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened to a resource. Data describes the
+// resource as it was left, or as it was before being deleted.
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	Type       Type      `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+func New(eventType Type, data any) Event {
+	return Event{ID: uuid.New(), Type: eventType, OccurredAt: time.Now().UTC(), Data: data}
+}
+
+// Publisher receives the events of the services. Publish returns the write
+// that records an event, which the repository runs in the transaction of the
+// change that caused it: the event is kept exactly when the change is, and an
+// event that cannot be recorded rolls the change back.
+type Publisher interface {
+	Publish(eventType Type, data any) db.Step
+}
@@ -0,0 +1,295 @@
+// Package fields lets list endpoints answer with only the members a client
+// asks for, with ?fields=, and embed related resources, with ?include=.
+// Members left out are not read from the database either: repositories build
+// their select list from the selection.
+package fields
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownField   = apierror.New(apierror.UnknownField)
+	ErrUnknownInclude = apierror.New(apierror.UnknownInclude)
+)
+
+// Column is a JSON member of a resource and the SQL that reads it
+type Column struct {
+	Name string
+	// SQL selects the member; empty for members computed after the query
+	SQL string
+	// Empty is selected instead of SQL when the member is left out. Columns
+	// without one are always read, because they are cheap or the scan needs a
+	// real value.
+	Empty string
+	// Needs are the members the value is computed from
+	Needs []string
+}
+
+// Set is every member of a resource, in the order its repository scans them
+type Set struct {
+	columns []Column
+	index   map[string]int
+}
+
+func NewSet(columns ...Column) *Set {
+	set := &Set{columns: columns, index: make(map[string]int, len(columns))}
+	for i, column := range columns {
+		set.index[column.Name] = i
+	}
+	return set
+}
+
+// All selects every member, which is what endpoints answer without ?fields=
+func (s *Set) All() Selection {
+	return Selection{set: s}
+}
+
+// Select returns the selection of the members named, with the members they
+// need and those always needed
+func (s *Set) Select(names []string, always ...string) (Selection, error) {
+	selection := Selection{set: s, requested: make(map[string]bool), read: make(map[string]bool)}
+	var need func(name string)
+	need = func(name string) {
+		if selection.read[name] {
+			return
+		}
+		selection.read[name] = true
+		for _, needed := range s.columns[s.index[name]].Needs {
+			need(needed)
+		}
+	}
+	for _, name := range names {
+		if _, ok := s.index[name]; !ok {
+			return Selection{}, fmt.Errorf("%w: %s", ErrUnknownField, name)
+		}
+		selection.requested[name] = true
+		need(name)
+	}
+	for _, name := range always {
+		if _, ok := s.index[name]; ok {
+			need(name)
+		}
+	}
+	return selection, nil
+}
+
+// Names lists the members of the set, for documentation
+func (s *Set) Names() []string {
+	names := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Selection is the members a request asks for and the relations it embeds.
+// The zero value of a set's selection, from All, selects everything.
+type Selection struct {
+	set *Set
+	// requested are the members answered, read those read from the
+	// database; both nil when everything is selected
+	requested map[string]bool
+	read      map[string]bool
+	Includes  []string
+}
+
+// Has reports whether a member is read, because it was asked for or another
+// one needs it
+func (s Selection) Has(name string) bool {
+	return s.read == nil || s.read[name]
+}
+
+// SQL returns the select list of the set, reading the members left out as
+// their empty value
+func (s Selection) SQL() string {
+	expressions := make([]string, 0, len(s.set.columns))
+	for _, column := range s.set.columns {
+		if column.SQL == "" {
+			continue
+		}
+		if column.Empty != "" && !s.Has(column.Name) {
+			expressions = append(expressions, column.Empty)
+			continue
+		}
+		expressions = append(expressions, column.SQL)
+	}
+	return strings.Join(expressions, ", ")
+}
+
+// FromRequest reads ?fields= and ?include= for a set and its relations,
+// answering 400 when they name members or relations that do not exist
+func FromRequest(c *gin.Context, set *Set, relations Relations) (Selection, bool) {
+	includes := split(c.Query("include"))
+	for _, include := range includes {
+		if _, ok := relations[include]; !ok {
+			apierror.Respond(c, fmt.Errorf("%w: %s", ErrUnknownInclude, include))
+			return Selection{}, false
+		}
+	}
+	requested := split(c.Query("fields"))
+	if len(requested) == 0 {
+		selection := set.All()
+		selection.Includes = includes
+		return selection, true
+	}
+	// The keys of the included relations are read even when not answered
+	keys := make([]string, len(includes))
+	for i, include := range includes {
+		keys[i] = relations[include].Key
+	}
+	selection, err := set.Select(requested, keys...)
+	if err != nil {
+		apierror.Respond(c, err)
+		return Selection{}, false
+	}
+	selection.Includes = includes
+	return selection, true
+}
+
+func split(value string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Relation loads a related resource for many items at once
+type Relation struct {
+	// Key is the member of an item holding the id to load: the id of the
+	// related resource, or the item's own for resources pointing back at it
+	Key string
+	// Many embeds a list, empty when nothing was loaded, instead of an
+	// object or null
+	Many bool
+	Load func(ids []uuid.UUID) (map[uuid.UUID]any, error)
+}
+
+// Relations are the relations a resource can embed, by the name of the
+// member they are embedded as
+type Relations map[string]Relation
+
+// JSON answers value, an item or a list of them, with the selected members
+// and the included relations
+func JSON(c *gin.Context, selection Selection, relations Relations, value any) {
+	if selection.requested == nil && len(selection.Includes) == 0 {
+		c.JSON(http.StatusOK, value)
+		return
+	}
+	projected, err := selection.project(value, relations)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, projected)
+}
+
+// project turns value into JSON objects keeping the requested members, then
+// embeds each relation with one load for every item
+func (s Selection) project(value any, relations Relations) (any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	var items []map[string]any
+	switch decoded := decoded.(type) {
+	case []any:
+		for _, item := range decoded {
+			if object, ok := item.(map[string]any); ok {
+				items = append(items, object)
+			}
+		}
+	case map[string]any:
+		items = []map[string]any{decoded}
+	}
+	for _, include := range s.Includes {
+		if err := embed(items, include, relations[include]); err != nil {
+			return nil, err
+		}
+	}
+	if s.requested != nil {
+		for _, item := range items {
+			for name := range item {
+				if !s.requested[name] && !s.included(name) {
+					delete(item, name)
+				}
+			}
+		}
+	}
+	return decoded, nil
+}
+
+func (s Selection) included(name string) bool {
+	for _, include := range s.Includes {
+		if include == name {
+			return true
+		}
+	}
+	return false
+}
+
+func embed(items []map[string]any, name string, relation Relation) error {
+	keys := make([]uuid.UUID, len(items))
+	ids := make([]uuid.UUID, 0, len(items))
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range items {
+		value, _ := item[relation.Key].(string)
+		id, err := uuid.Parse(value)
+		if err != nil {
+			continue
+		}
+		keys[i] = id
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	loaded := map[uuid.UUID]any{}
+	if len(ids) > 0 {
+		var err error
+		if loaded, err = relation.Load(ids); err != nil {
+			return err
+		}
+	}
+	for i, item := range items {
+		value, ok := loaded[keys[i]]
+		switch {
+		case ok:
+			item[name] = value
+		case relation.Many:
+			item[name] = []any{}
+		default:
+			item[name] = nil
+		}
+	}
+	return nil
+}
+
+// Params describes ?fields= and ?include= for the OpenAPI document
+func Params(set *Set, relations ...string) []openapi.Param {
+	params := []openapi.Param{
+		openapi.Query("fields", "Comma-separated members to answer with: "+strings.Join(set.Names(), ", ")),
+	}
+	if len(relations) > 0 {
+		params = append(params, openapi.Query("include", "Comma-separated relations to embed: "+strings.Join(relations, ", ")))
+	}
+	return params
+}
@@ -0,0 +1,172 @@
+package fields
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var set = NewSet(
+	Column{Name: "id", SQL: "p.id"},
+	Column{Name: "title", SQL: "p.title", Empty: "''"},
+	Column{Name: "content", SQL: "p.content", Empty: "''"},
+	Column{Name: "content_html", SQL: "p.content_html", Empty: "''", Needs: []string{"content"}},
+	Column{Name: "author_id", SQL: "p.author_id", Empty: "NULL"},
+	Column{Name: "toc", Needs: []string{"content_html"}},
+)
+
+type item struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	AuthorId string `json:"author_id"`
+}
+
+func request(query string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return c, recorder
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		always  []string
+		read    []string
+		sql     string
+		wantErr error
+	}{
+		{"one member", []string{"title"}, nil, []string{"title"}, "p.id, p.title, '', '', NULL", nil},
+		{"needs are read", []string{"toc"}, nil, []string{"content", "content_html", "toc"}, "p.id, '', p.content, p.content_html, NULL", nil},
+		{"always read", []string{"title"}, []string{"author_id"}, []string{"author_id", "title"}, "p.id, p.title, '', '', p.author_id", nil},
+		{"unknown member", []string{"title", "secret"}, nil, nil, "", ErrUnknownField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := set.Select(tt.names, tt.always...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Select() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			var read []string
+			for name := range selection.read {
+				read = append(read, name)
+			}
+			sort.Strings(read)
+			if !reflect.DeepEqual(read, tt.read) {
+				t.Errorf("Select() reads %v, want %v", read, tt.read)
+			}
+			if got := selection.SQL(); got != tt.sql {
+				t.Errorf("SQL() = %q, want %q", got, tt.sql)
+			}
+		})
+	}
+}
+
+func TestAllReadsEverything(t *testing.T) {
+	selection := set.All()
+	if !selection.Has("content") || !selection.Has("toc") {
+		t.Error("All() leaves members out")
+	}
+	if got, want := selection.SQL(), "p.id, p.title, p.content, p.content_html, p.author_id"; got != want {
+		t.Errorf("SQL() = %q, want %q", got, want)
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	relations := Relations{"author": {Key: "author_id"}}
+	tests := []struct {
+		name     string
+		query    string
+		ok       bool
+		wantErr  error
+		read     []string
+		includes []string
+	}{
+		{"nothing asked", "", true, nil, nil, []string{}},
+		{"fields", "fields=title,+content", true, nil, []string{"title", "content"}, []string{}},
+		{"include reads its key", "fields=title&include=author", true, nil, []string{"title", "author_id"}, []string{"author"}},
+		{"unknown field", "fields=secret", false, ErrUnknownField, nil, nil},
+		{"unknown include", "include=comments", false, ErrUnknownInclude, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := request(tt.query)
+			selection, ok := FromRequest(c, set, relations)
+			if ok != tt.ok {
+				t.Fatalf("FromRequest() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				if err := c.Errors.Last(); err == nil || !errors.Is(err.Err, tt.wantErr) || !c.IsAborted() {
+					t.Errorf("FromRequest() answered %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			for _, name := range tt.read {
+				if !selection.Has(name) {
+					t.Errorf("FromRequest() does not read %s", name)
+				}
+			}
+			if !reflect.DeepEqual(selection.Includes, tt.includes) {
+				t.Errorf("FromRequest() includes %v, want %v", selection.Includes, tt.includes)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	odin, loki := uuid.New(), uuid.New()
+	items := []item{
+		{ID: "1", Title: "Hávamál", Content: "…", AuthorId: odin.String()},
+		{ID: "2", Title: "Lokasenna", Content: "…", AuthorId: loki.String()},
+		{ID: "3", Title: "Völuspá", Content: "…", AuthorId: odin.String()},
+	}
+	loads := 0
+	relations := Relations{
+		"author": {Key: "author_id", Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			loads++
+			if len(ids) != 2 {
+				t.Errorf("Load() got %d ids, want the 2 distinct ones", len(ids))
+			}
+			return map[uuid.UUID]any{odin: map[string]string{"name": "Odin"}}, nil
+		}},
+		"tags": {Key: "id", Many: true, Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			return map[uuid.UUID]any{}, nil
+		}},
+	}
+
+	c, recorder := request("fields=title&include=author,tags")
+	selection, ok := FromRequest(c, set, relations)
+	if !ok {
+		t.Fatalf("FromRequest() answered %v", c.Errors.Last())
+	}
+	JSON(c, selection, relations, items)
+
+	var got []map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatalf("JSON() answered %s: %v", recorder.Body, err)
+	}
+	want := []map[string]any{
+		{"title": "Hávamál", "author": map[string]any{"name": "Odin"}, "tags": []any{}},
+		{"title": "Lokasenna", "author": nil, "tags": []any{}},
+		{"title": "Völuspá", "author": map[string]any{"name": "Odin"}, "tags": []any{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON() = %v, want %v", got, want)
+	}
+	if loads != 1 {
+		t.Errorf("the relation was loaded %d times, want once", loads)
+	}
+}
@@ -0,0 +1,39 @@
+package graph
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrQueryRequired     = apierror.New(apierror.GraphQLQueryRequired)
+	ErrQueryTooLarge     = apierror.New(apierror.GraphQLQueryTooLarge)
+	ErrTooDeep           = apierror.New(apierror.GraphQLTooDeep)
+	ErrTooComplex        = apierror.New(apierror.GraphQLTooComplex)
+	ErrIntrospectionOff  = apierror.New(apierror.GraphQLIntrospectionOff)
+	ErrPersistedOnly     = apierror.New(apierror.GraphQLPersistedOnly)
+	ErrPersistedNotFound = apierror.New(apierror.GraphQLPersistedNotFound)
+	ErrHashMismatch      = apierror.New(apierror.GraphQLPersistedHashInvalid)
+)
+
+// fieldError is a failure as the GraphQL response shows it: the catalogue
+// title in the reader's locale, with the code in the extensions. Internal
+// errors keep their cause out of the response.
+type fieldError struct {
+	code   apierror.Code
+	locale string
+	cause  error
+}
+
+func newFieldError(err error, locale string) *fieldError {
+	return &fieldError{code: apierror.Lookup(err), locale: locale, cause: err}
+}
+
+func (e *fieldError) Error() string {
+	return apierror.Message(e.code, e.locale)
+}
+
+func (e *fieldError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// Query runs a GraphQL request. POST takes it as a JSON body; GET takes it
+// in the query string, with the variables and extensions as JSON, so that
+// persisted queries can be cached like any other GET. Results are always
+// 200, with the errors in the body as GraphQL clients expect.
+func (h *Handler) Query(c *gin.Context) {
+	var request Request
+	if c.Request.Method == http.MethodGet {
+		if err := c.ShouldBindQuery(&request); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		variables, err := rawVariables(c.Query("variables"))
+		if err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		request.Variables = variables
+		if extensions := c.Query("extensions"); extensions != "" {
+			if err := json.Unmarshal([]byte(extensions), &request.Extensions); err != nil {
+				apierror.Invalid(c, err)
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	} else {
+		request.register = true
+	}
+	result, internal := h.service.Execute(c.Request.Context(), &request, i18n.FromRequest(c))
+	for _, err := range internal {
+		_ = c.Error(err)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Register persists a query ahead of time and answers with its hash
+func (h *Handler) Register(c *gin.Context) {
+	var request QueryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	hash, err := h.service.Persist(request.Query)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, QueryResponse{Hash: hash})
+}
@@ -0,0 +1,193 @@
+package graph
+
+import (
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listEstimate is how many items a list without a first argument is assumed
+// to return when weighing a query
+const listEstimate = 10
+
+// limits weighs a validated query before it runs: how deep its selections
+// nest and how many fields it may resolve, multiplying the fields under a
+// list by the items the list may return. Fragments count where they are
+// spread.
+type limits struct {
+	schema        *graphql.Schema
+	fragments     map[string]*ast.FragmentDefinition
+	variables     map[string]any
+	introspection bool
+	selected      selected
+}
+
+// selected is the fields a query asks for, by object type
+type selected map[string]map[string]bool
+
+// check returns the first limit the operations of document break, if any, and
+// otherwise the fields they select
+func (s *service) check(schema *graphql.Schema, document *ast.Document, variables map[string]any) (selected, error) {
+	l := &limits{
+		schema:        schema,
+		fragments:     make(map[string]*ast.FragmentDefinition),
+		variables:     variables,
+		introspection: s.config.Introspection,
+		selected:      make(selected),
+	}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			l.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, complexity, err := l.weigh(operation.SelectionSet, schema.QueryType(), 1, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		if depth > s.config.MaxDepth {
+			return nil, ErrTooDeep
+		}
+		if complexity > s.config.MaxComplexity {
+			return nil, ErrTooComplex
+		}
+	}
+	return l.selected, nil
+}
+
+// weigh returns the depth and complexity of a selection set on parent, at
+// depth level. Spread lists the fragments being expanded, which validation
+// already keeps from forming cycles.
+func (l *limits) weigh(set *ast.SelectionSet, parent *graphql.Object, level int, spread map[string]bool) (int, int, error) {
+	if set == nil {
+		return level - 1, 0, nil
+	}
+	depth, complexity := level-1, 0
+	add := func(d int, c int) {
+		depth = max(depth, d)
+		complexity += c
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d, c, err := l.field(selection, parent, level, spread)
+			if err != nil {
+				return 0, 0, err
+			}
+			add(d, c)
+		case *ast.InlineFragment:
+			d, c, err := l.weigh(selection.SelectionSet, l.condition(selection.TypeCondition, parent), level, spread)
+			if err != nil {
+				return 0, 0, err
+			}
+			add(d, c)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := l.fragments[name]
+			if !ok || spread[name] {
+				continue
+			}
+			spread[name] = true
+			d, c, err := l.weigh(fragment.SelectionSet, l.condition(fragment.TypeCondition, parent), level, spread)
+			delete(spread, name)
+			if err != nil {
+				return 0, 0, err
+			}
+			add(d, c)
+		}
+	}
+	return depth, complexity, nil
+}
+
+func (l *limits) field(selection *ast.Field, parent *graphql.Object, level int, spread map[string]bool) (int, int, error) {
+	name := selection.Name.Value
+	switch name {
+	case "__typename":
+		return level, 0, nil
+	case "__schema", "__type":
+		if !l.introspection {
+			return 0, 0, ErrIntrospectionOff
+		}
+		// The introspection types are finite and not weighed
+		return level, 1, nil
+	}
+	if parent == nil {
+		return level, 1, nil
+	}
+	definition, ok := parent.Fields()[name]
+	if !ok {
+		return level, 1, nil
+	}
+	if l.selected[parent.Name()] == nil {
+		l.selected[parent.Name()] = make(map[string]bool)
+	}
+	l.selected[parent.Name()][name] = true
+	multiplier := 1
+	fieldType := definition.Type
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	if list, ok := fieldType.(*graphql.List); ok {
+		multiplier = l.listSize(selection, definition)
+		fieldType = list.OfType
+		if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+			fieldType = nonNull.OfType
+		}
+	}
+	object, _ := fieldType.(*graphql.Object)
+	depth, complexity, err := l.weigh(selection.SelectionSet, object, level+1, spread)
+	if err != nil {
+		return 0, 0, err
+	}
+	return max(depth, level), 1 + multiplier*complexity, nil
+}
+
+// listSize is the first argument of a list, its default or the estimate
+func (l *limits) listSize(selection *ast.Field, definition *graphql.FieldDefinition) int {
+	for _, argument := range selection.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return clamp(n)
+			}
+		case *ast.Variable:
+			switch n := l.variables[value.Name.Value].(type) {
+			case float64:
+				return clamp(int(n))
+			case int:
+				return clamp(n)
+			}
+		}
+	}
+	for _, argument := range definition.Args {
+		if argument.Name() == "first" {
+			if n, ok := argument.DefaultValue.(int); ok {
+				return n
+			}
+		}
+	}
+	return listEstimate
+}
+
+// condition returns the object a fragment applies to, the parent by default
+func (l *limits) condition(named *ast.Named, parent *graphql.Object) *graphql.Object {
+	if named == nil {
+		return parent
+	}
+	if object, ok := l.schema.Type(named.Name.Value).(*graphql.Object); ok {
+		return object
+	}
+	return parent
+}
+
+func clamp(n int) int {
+	return min(max(n, 0), maxPageSize)
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newTestService(t *testing.T, config Config) *service {
+	t.Helper()
+	s := &service{config: config}
+	schema, err := s.newSchema()
+	if err != nil {
+		t.Fatalf("newSchema() error = %v", err)
+	}
+	s.schema = schema
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	s := newTestService(t, Config{MaxDepth: 3, MaxComplexity: 100})
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		wantErr   error
+	}{
+		{"within limits", `{ posts(first: 10) { title slug } }`, nil, nil},
+		{"default page size", `{ posts { title slug } }`, nil, nil},
+		{"too complex", `{ posts(first: 100) { title slug } }`, nil, ErrTooComplex},
+		{"first from a variable", `query($n: Int) { posts(first: $n) { title slug } }`, map[string]any{"n": float64(100)}, ErrTooComplex},
+		{"small first from a variable", `query($n: Int) { posts(first: $n) { title slug } }`, map[string]any{"n": float64(5)}, nil},
+		{"first is capped", `{ tags { posts(first: 100000) { id } } }`, nil, ErrTooComplex},
+		{"too deep", `{ posts(first: 1) { category { parent { name } } } }`, nil, ErrTooDeep},
+		{"fragments count where spread", `{ ...heavy } fragment heavy on Query { posts(first: 100) { title slug } }`, nil, ErrTooComplex},
+		{"inline fragments", `{ posts(first: 1) { ... on Post { category { parent { name } } } } }`, nil, ErrTooDeep},
+		{"typename is free", `{ __typename }`, nil, nil},
+		{"introspection is off", `{ __schema { types { name } } }`, nil, ErrIntrospectionOff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, errs := s.parse(tt.query)
+			if errs != nil {
+				t.Fatalf("parse() errors = %v", errs)
+			}
+			if _, err := s.check(&s.schema, document, tt.variables); !errors.Is(err, tt.wantErr) {
+				t.Errorf("check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckIntrospection(t *testing.T) {
+	s := newTestService(t, Config{MaxDepth: 10, MaxComplexity: 1000, Introspection: true})
+	document, errs := s.parse(`{ __schema { types { name } } }`)
+	if errs != nil {
+		t.Fatalf("parse() errors = %v", errs)
+	}
+	if _, err := s.check(&s.schema, document, nil); err != nil {
+		t.Errorf("check() error = %v", err)
+	}
+}
+
+func TestCheckSelected(t *testing.T) {
+	s := newTestService(t, Config{MaxDepth: 5, MaxComplexity: 1000})
+	query := `{
+		posts(first: 5) { title ...body author { username } }
+		tags { slug posts(first: 1) { summary } }
+	}
+	fragment body on Post { contentHtml }`
+	document, errs := s.parse(query)
+	if errs != nil {
+		t.Fatalf("parse() errors = %v", errs)
+	}
+	got, err := s.check(&s.schema, document, nil)
+	if err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	want := selected{
+		"Query":  {"posts": true, "tags": true},
+		"Post":   {"title": true, "contentHtml": true, "author": true, "summary": true},
+		"Author": {"username": true},
+		"Tag":    {"slug": true, "posts": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("check() selected %v, want %v", got, want)
+	}
+
+	selection, err := postSelection(got)
+	if err != nil {
+		t.Fatalf("postSelection() error = %v", err)
+	}
+	for name, read := range map[string]bool{
+		"title": true, "summary": true, "content_html": true, "content": true, "format": true,
+		"author_name": true, "translation_group": true, "columns": false, "category_name": false, "blocks": false,
+	} {
+		if selection.Has(name) != read {
+			t.Errorf("postSelection() reads %s = %v, want %v", name, selection.Has(name), read)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package graph
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"havamal-api/internal/categories"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/posts"
+	"havamal-api/internal/tags"
+
+	"github.com/google/uuid"
+)
+
+// loader batches the keys asked for while one level of a query resolves and
+// fetches them with a single call once the first of them is needed.
+// Resolvers return its thunks, which the executor only calls after every
+// field of the level has asked for its keys.
+type loader[K comparable, V any] struct {
+	mu      sync.Mutex
+	fetch   func(keys []K) (map[K]V, error)
+	pending []K
+	queued  map[K]bool
+	values  map[K]V
+	errs    map[K]error
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, queued: make(map[K]bool), values: make(map[K]V), errs: make(map[K]error)}
+}
+
+func (l *loader[K, V]) load(key K) func() (V, error) {
+	l.mu.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			values, err := l.fetch(keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+				} else {
+					l.values[k] = values[k]
+				}
+			}
+		}
+		return l.values[key], l.errs[key]
+	}
+}
+
+// lazy fetches something every resolver of a request may need, once
+type lazy[T any] struct {
+	once  sync.Once
+	fetch func() (T, error)
+	value T
+	err   error
+}
+
+func (l *lazy[T]) get() (T, error) {
+	l.once.Do(func() { l.value, l.err = l.fetch() })
+	return l.value, l.err
+}
+
+// catalog is every published post in every locale. The posts are listed once
+// per category, so a post's categories come from all its rows.
+type catalog struct {
+	posts      []posts.Response
+	byId       map[uuid.UUID]posts.Response
+	categories map[uuid.UUID][]uuid.UUID
+	groups     map[uuid.UUID][]posts.Response
+}
+
+func newCatalog(rows []posts.Response) *catalog {
+	c := &catalog{
+		byId:       make(map[uuid.UUID]posts.Response),
+		categories: make(map[uuid.UUID][]uuid.UUID),
+		groups:     make(map[uuid.UUID][]posts.Response),
+	}
+	for _, row := range rows {
+		if row.CategoryId != uuid.Nil {
+			c.categories[row.ID] = append(c.categories[row.ID], row.CategoryId)
+		}
+		if _, ok := c.byId[row.ID]; ok {
+			continue
+		}
+		c.byId[row.ID] = row
+		c.posts = append(c.posts, row)
+		c.groups[row.TranslationGroup] = append(c.groups[row.TranslationGroup], row)
+	}
+	return c
+}
+
+// filter returns the posts keep accepts in locale, newest first
+func (c *catalog) filter(locale string, keep func(post posts.Response) bool) []posts.Response {
+	kept := make([]posts.Response, 0)
+	for _, post := range c.posts {
+		if keep(post) {
+			kept = append(kept, post)
+		}
+	}
+	kept = posts.InLocale(kept, locale)
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].PublishedAt.After(kept[j].PublishedAt)
+	})
+	return kept
+}
+
+// inCategory reports whether a post is filed under any of ids
+func (c *catalog) inCategory(post posts.Response, ids map[uuid.UUID]bool) bool {
+	for _, id := range c.categories[post.ID] {
+		if ids[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// tree is every category in the request's locale
+type tree struct {
+	byId     map[uuid.UUID]categories.Category
+	bySlug   map[string]categories.Category
+	roots    []categories.Category
+	children map[uuid.UUID][]categories.Category
+}
+
+func newTree(all []categories.Category) *tree {
+	t := &tree{
+		byId:     make(map[uuid.UUID]categories.Category),
+		bySlug:   make(map[string]categories.Category),
+		children: make(map[uuid.UUID][]categories.Category),
+	}
+	for _, category := range all {
+		t.byId[category.ID] = category
+		t.bySlug[category.Slug] = category
+		if category.ParentId == nil {
+			t.roots = append(t.roots, category)
+		} else {
+			t.children[*category.ParentId] = append(t.children[*category.ParentId], category)
+		}
+	}
+	return t
+}
+
+// descendants returns the ids of a category and, if asked, of every category
+// under it
+func (t *tree) descendants(id uuid.UUID, all bool) map[uuid.UUID]bool {
+	ids := map[uuid.UUID]bool{id: true}
+	if !all {
+		return ids
+	}
+	queue := []uuid.UUID{id}
+	for len(queue) > 0 {
+		for _, child := range t.children[queue[0]] {
+			if !ids[child.ID] {
+				ids[child.ID] = true
+				queue = append(queue, child.ID)
+			}
+		}
+		queue = queue[1:]
+	}
+	return ids
+}
+
+// loaders is what the resolvers of one request share. Nothing is kept
+// between requests, so every response reflects the current content.
+type loaders struct {
+	locale     string
+	published  lazy[*catalog]
+	categories lazy[*tree]
+	tagCounts  lazy[map[uuid.UUID]tags.TagCount]
+	// taggedPosts is the published posts of every tag, by tag id
+	taggedPosts lazy[map[uuid.UUID][]uuid.UUID]
+	postTags    *loader[uuid.UUID, []tags.Tag]
+}
+
+// postColumns are the members of a post each field of the Post type reads
+var postColumns = map[string][]string{
+	"title":       {"title"},
+	"summary":     {"summary"},
+	"content":     {"content"},
+	"contentHtml": {"content_html"},
+	"format":      {"format"},
+	"columns":     {"columns"},
+	"updatedAt":   {"updated_at"},
+	"author":      {"author_name"},
+}
+
+// catalogColumns are the members the catalog filters, groups and pages posts
+// by, read whatever the query asks for
+var catalogColumns = []string{"id", "slug", "status", "published_at", "author_id", "category_id", "locale", "translation_group"}
+
+// postSelection reads only the members of the posts the query asks for, so
+// that listing posts without their content does not read every content
+func postSelection(selected selected) (fields.Selection, error) {
+	var names []string
+	for field := range selected["Post"] {
+		names = append(names, postColumns[field]...)
+	}
+	return posts.Fields.Select(names, catalogColumns...)
+}
+
+func (s *service) newLoaders(locale string, selected selected) *loaders {
+	l := &loaders{locale: locale}
+	l.published.fetch = func() (*catalog, error) {
+		selection, err := postSelection(selected)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := s.postService.GetPublishedPosts("", selection)
+		if err != nil {
+			return nil, err
+		}
+		return newCatalog(rows), nil
+	}
+	l.categories.fetch = func() (*tree, error) {
+		all, err := s.categoryService.GetAll(locale, categories.Fields.All())
+		if err != nil {
+			return nil, err
+		}
+		return newTree(all), nil
+	}
+	l.tagCounts.fetch = func() (map[uuid.UUID]tags.TagCount, error) {
+		all, err := s.tagService.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		counts := make(map[uuid.UUID]tags.TagCount, len(all))
+		for _, tag := range all {
+			counts[tag.ID] = tag
+		}
+		return counts, nil
+	}
+	l.taggedPosts.fetch = func() (map[uuid.UUID][]uuid.UUID, error) {
+		published, err := l.published.get()
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]uuid.UUID, len(published.posts))
+		for i, post := range published.posts {
+			ids[i] = post.ID
+		}
+		byPost, err := s.tagService.GetByPosts(ids)
+		if err != nil {
+			return nil, err
+		}
+		byTag := make(map[uuid.UUID][]uuid.UUID)
+		for postId, postTags := range byPost {
+			for _, tag := range postTags {
+				byTag[tag.ID] = append(byTag[tag.ID], postId)
+			}
+		}
+		return byTag, nil
+	}
+	l.postTags = newLoader(s.tagService.GetByPosts)
+	return l
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
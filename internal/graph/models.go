@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Request is a GraphQL request as sent over HTTP, in a POST body or the query
+// string of a GET
+type Request struct {
+	Query         string         `json:"query" form:"query"`
+	OperationName string         `json:"operationName" form:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    *Extensions    `json:"extensions"`
+	// register lets a request that sends both the text and the hash persist
+	// the query. Only POST requests may, so that a crawled GET cannot fill
+	// the store.
+	register bool
+}
+
+// Extensions carries the hash of a persisted query, as Apollo clients send it
+type Extensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery"`
+}
+
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// QueryRequest registers a query ahead of time, for when only persisted
+// queries are accepted
+type QueryRequest struct {
+	Query string `json:"query" binding:"required"`
+}
+
+type QueryResponse struct {
+	Hash string `json:"hash"`
+}
+
+// Config is the part of the server configuration the endpoint reads
+type Config struct {
+	Introspection bool
+	MaxDepth      int
+	MaxComplexity int
+	MaxQuerySize  int
+	PersistedOnly bool
+}
+
+// author is a user as far as the public schema shows one: their published
+// posts name them
+type author struct {
+	ID       uuid.UUID
+	Username string
+}
+
+// rawVariables accepts variables given as a JSON string in a query string
+func rawVariables(value string) (map[string]any, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var variables map[string]any
+	err := json.Unmarshal([]byte(value), &variables)
+	return variables, err
+}
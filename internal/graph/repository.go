@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"database/sql"
+
+	"havamal-api/internal/db"
+)
+
+// Repository stores persisted queries by the SHA-256 of their text
+type Repository interface {
+	GetQuery(hash string) (string, error)
+	SaveQuery(hash string, query string) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) GetQuery(hash string) (string, error) {
+	var query string
+	err := r.db.QueryRow(`SELECT query FROM graphql_persisted_queries WHERE hash = $1`, hash).Scan(&query)
+	return query, db.Translate(err, ErrPersistedNotFound, nil)
+}
+
+// SaveQuery keeps the first text stored under a hash; both are the same query
+func (r *repository) SaveQuery(hash string, query string) error {
+	_, err := r.db.Exec(`INSERT INTO graphql_persisted_queries (hash, query) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING`, hash, query)
+	return err
+}
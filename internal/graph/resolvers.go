@@ -0,0 +1,330 @@
+package graph
+
+import (
+	"havamal-api/internal/categories"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/posts"
+	"havamal-api/internal/tags"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+func (s *service) resolvePost(p graphql.ResolveParams) (any, error) {
+	var post *posts.Response
+	var err error
+	if slug, ok := p.Args["slug"].(string); ok {
+		post, err = s.postService.GetPostBySlug(slug)
+	} else if id, ok := optionalId(p.Args, "id"); ok {
+		post, err = s.postService.GetPost(id.String())
+	} else {
+		return nil, nil
+	}
+	if err != nil {
+		return missing(nil, err)
+	}
+	if post.Status != posts.Published {
+		return nil, nil
+	}
+	return *post, nil
+}
+
+func (s *service) resolvePosts(p graphql.ResolveParams) (any, error) {
+	l := loadersFrom(p.Context)
+	published, err := l.published.get()
+	if err != nil {
+		return nil, err
+	}
+	keep := func(post posts.Response) bool { return true }
+	if slug, ok := p.Args["category"].(string); ok {
+		tree, err := l.categories.get()
+		if err != nil {
+			return nil, err
+		}
+		category, ok := tree.bySlug[slug]
+		if !ok {
+			return []posts.Response{}, nil
+		}
+		ids := tree.descendants(category.ID, false)
+		previous := keep
+		keep = func(post posts.Response) bool { return previous(post) && published.inCategory(post, ids) }
+	}
+	if slug, ok := p.Args["tag"].(string); ok {
+		tag, err := s.tagService.GetBySlug(slug)
+		if err != nil {
+			return missing([]posts.Response{}, err)
+		}
+		tagged, err := l.taggedPosts.get()
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[uuid.UUID]bool)
+		for _, id := range tagged[tag.ID] {
+			ids[id] = true
+		}
+		previous := keep
+		keep = func(post posts.Response) bool { return previous(post) && ids[post.ID] }
+	}
+	if _, ok := p.Args["author"]; ok {
+		id, _ := optionalId(p.Args, "author")
+		previous := keep
+		keep = func(post posts.Response) bool { return previous(post) && post.AuthorId == id }
+	}
+	return page(published.filter(l.locale, keep), p.Args), nil
+}
+
+func (s *service) resolvePostCategory(p graphql.ResolveParams) (any, error) {
+	post := p.Source.(posts.Response)
+	tree, err := loadersFrom(p.Context).categories.get()
+	if err != nil {
+		return nil, err
+	}
+	if category, ok := tree.byId[post.CategoryId]; ok {
+		return category, nil
+	}
+	return nil, nil
+}
+
+func (s *service) resolvePostCategories(p graphql.ResolveParams) (any, error) {
+	post := p.Source.(posts.Response)
+	l := loadersFrom(p.Context)
+	published, err := l.published.get()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := l.categories.get()
+	if err != nil {
+		return nil, err
+	}
+	filed := make([]categories.Category, 0)
+	for _, id := range published.categories[post.ID] {
+		if category, ok := tree.byId[id]; ok {
+			filed = append(filed, category)
+		}
+	}
+	return filed, nil
+}
+
+// resolvePostTags uses the tags a single post comes with, else loads the tags
+// of every post of the list in one query
+func (s *service) resolvePostTags(p graphql.ResolveParams) (any, error) {
+	post := p.Source.(posts.Response)
+	if post.Tags != nil {
+		return post.Tags, nil
+	}
+	load := loadersFrom(p.Context).postTags.load(post.ID)
+	return thunk(func() (any, error) {
+		postTags, err := load()
+		if postTags == nil {
+			postTags = []tags.Tag{}
+		}
+		return postTags, err
+	}), nil
+}
+
+func (s *service) resolvePostAlternates(p graphql.ResolveParams) (any, error) {
+	post := p.Source.(posts.Response)
+	if post.Alternates != nil {
+		return post.Alternates, nil
+	}
+	published, err := loadersFrom(p.Context).published.get()
+	if err != nil {
+		return nil, err
+	}
+	translations := make([]posts.Alternate, 0)
+	for _, translation := range published.groups[post.TranslationGroup] {
+		translations = append(translations, posts.Alternate{Hreflang: translation.Locale, Slug: translation.Slug})
+	}
+	alternates := posts.Alternates(translations)
+	if alternates == nil {
+		alternates = []posts.Alternate{}
+	}
+	return alternates, nil
+}
+
+func (s *service) resolveCategory(p graphql.ResolveParams) (any, error) {
+	tree, err := loadersFrom(p.Context).categories.get()
+	if err != nil {
+		return nil, err
+	}
+	if slug, ok := p.Args["slug"].(string); ok {
+		if category, ok := tree.bySlug[slug]; ok {
+			return category, nil
+		}
+	} else if id, ok := optionalId(p.Args, "id"); ok {
+		if category, ok := tree.byId[id]; ok {
+			return category, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *service) resolveCategories(p graphql.ResolveParams) (any, error) {
+	tree, err := loadersFrom(p.Context).categories.get()
+	if err != nil {
+		return nil, err
+	}
+	if roots, _ := p.Args["roots"].(bool); roots {
+		return nonNil(tree.roots), nil
+	}
+	all := make([]categories.Category, 0, len(tree.byId))
+	all = append(all, tree.roots...)
+	for i := 0; i < len(all); i++ {
+		all = append(all, tree.children[all[i].ID]...)
+	}
+	return all, nil
+}
+
+func (s *service) resolveCategoryParent(p graphql.ResolveParams) (any, error) {
+	category := p.Source.(categories.Category)
+	if category.ParentId == nil {
+		return nil, nil
+	}
+	tree, err := loadersFrom(p.Context).categories.get()
+	if err != nil {
+		return nil, err
+	}
+	if parent, ok := tree.byId[*category.ParentId]; ok {
+		return parent, nil
+	}
+	return nil, nil
+}
+
+func (s *service) resolveCategoryChildren(p graphql.ResolveParams) (any, error) {
+	category := p.Source.(categories.Category)
+	tree, err := loadersFrom(p.Context).categories.get()
+	if err != nil {
+		return nil, err
+	}
+	return nonNil(tree.children[category.ID]), nil
+}
+
+func (s *service) resolveCategoryPosts(p graphql.ResolveParams) (any, error) {
+	category := p.Source.(categories.Category)
+	l := loadersFrom(p.Context)
+	published, err := l.published.get()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := l.categories.get()
+	if err != nil {
+		return nil, err
+	}
+	descendants, _ := p.Args["includeDescendants"].(bool)
+	ids := tree.descendants(category.ID, descendants)
+	return page(published.filter(l.locale, func(post posts.Response) bool {
+		return published.inCategory(post, ids)
+	}), p.Args), nil
+}
+
+func (s *service) resolveTag(p graphql.ResolveParams) (any, error) {
+	tag, err := s.tagService.GetBySlug(p.Args["slug"].(string))
+	if err != nil {
+		return missing(nil, err)
+	}
+	return *tag, nil
+}
+
+func (s *service) resolveTags(p graphql.ResolveParams) (any, error) {
+	all, err := s.tagService.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]tags.Tag, len(all))
+	for i, tag := range all {
+		list[i] = tag.Tag
+	}
+	return list, nil
+}
+
+func (s *service) resolveTagCount(p graphql.ResolveParams) (any, error) {
+	tag := p.Source.(tags.Tag)
+	counts, err := loadersFrom(p.Context).tagCounts.get()
+	if err != nil {
+		return nil, err
+	}
+	return counts[tag.ID].Count, nil
+}
+
+func (s *service) resolveTagPosts(p graphql.ResolveParams) (any, error) {
+	tag := p.Source.(tags.Tag)
+	l := loadersFrom(p.Context)
+	published, err := l.published.get()
+	if err != nil {
+		return nil, err
+	}
+	tagged, err := l.taggedPosts.get()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[uuid.UUID]bool)
+	for _, id := range tagged[tag.ID] {
+		ids[id] = true
+	}
+	return page(published.filter(l.locale, func(post posts.Response) bool { return ids[post.ID] }), p.Args), nil
+}
+
+// resolveAuthor only finds users with published posts, so the schema cannot
+// be used to list accounts
+func (s *service) resolveAuthor(p graphql.ResolveParams) (any, error) {
+	id, ok := optionalId(p.Args, "id")
+	if !ok {
+		return nil, nil
+	}
+	published, err := loadersFrom(p.Context).published.get()
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range published.posts {
+		if post.AuthorId == id {
+			return author{ID: id, Username: post.AuthorName}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *service) resolveAuthorPosts(p graphql.ResolveParams) (any, error) {
+	writer := p.Source.(author)
+	l := loadersFrom(p.Context)
+	published, err := l.published.get()
+	if err != nil {
+		return nil, err
+	}
+	return page(published.filter(l.locale, func(post posts.Response) bool {
+		return post.AuthorId == writer.ID
+	}), p.Args), nil
+}
+
+func (s *service) resolveNavigation(p graphql.ResolveParams) (any, error) {
+	return s.navigationService.GetTree(loadersFrom(p.Context).locale)
+}
+
+func (s *service) resolveNavigationCategory(p graphql.ResolveParams) (any, error) {
+	item := p.Source.(navigation.TreeNode)
+	if item.CategoryId == nil {
+		return nil, nil
+	}
+	tree, err := loadersFrom(p.Context).categories.get()
+	if err != nil {
+		return nil, err
+	}
+	if category, ok := tree.byId[*item.CategoryId]; ok {
+		return category, nil
+	}
+	return nil, nil
+}
+
+func (s *service) resolveMenu(p graphql.ResolveParams) (any, error) {
+	menu, err := s.navigationService.GetMenu(p.Args["slug"].(string), loadersFrom(p.Context).locale)
+	if err != nil {
+		return missing(nil, err)
+	}
+	return menu, nil
+}
+
+func nonNil(list []categories.Category) []categories.Category {
+	if list == nil {
+		return []categories.Category{}
+	}
+	return list
+}
@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"havamal-api/internal/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterEndpointRoutes registers the endpoint itself, outside the API
+// versions: the schema evolves by deprecating fields instead
+func RegisterEndpointRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("", handler.Query)
+	router.POST("", handler.Query)
+}
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/graphql/queries", handler.Register)
+}
+
+// EndpointOperations describes the routes of RegisterEndpointRoutes
+func EndpointOperations() []openapi.Operation {
+	description := "Read-only schema over published posts, categories, tags, authors and navigation. " +
+		"Send extensions.persistedQuery.sha256Hash to run a persisted query; errors are answered in the body with a code in their extensions."
+	result := &openapi.Schema{Type: "object", Description: "data and errors, as the GraphQL specification describes them"}
+	return []openapi.Operation{
+		{Method: "GET", Path: "", Tag: "graphql", Summary: "Run a GraphQL query", Description: description,
+			Query: []openapi.Param{
+				openapi.Query("query", "The query text"),
+				openapi.Query("operationName", "The operation to run"),
+				openapi.Query("variables", "Variables, as JSON"),
+				openapi.Query("extensions", "Extensions, as JSON"),
+			},
+			Response: result},
+		{Method: "POST", Path: "", Tag: "graphql", Summary: "Run a GraphQL query", Description: description,
+			Body: Request{}, Response: result},
+	}
+}
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/graphql/queries", Tag: "graphql", Summary: "Persist a query",
+			Description: "Registers a query by its SHA-256, for when only persisted queries are accepted.",
+			Body:        QueryRequest{}, Response: QueryResponse{}, Status: http.StatusCreated},
+	}
+}
@@ -0,0 +1,308 @@
+package graph
+
+import (
+	"errors"
+	"strings"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/categories"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/posts"
+	"havamal-api/internal/tags"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// thunk is what a resolver returns to let the executor batch the loads of a
+// whole level of the query
+type thunk = func() (any, error)
+
+// field resolves a field from its parent, which is of type T
+func field[T any](get func(source T) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(T)), nil
+	}
+}
+
+// pageArgs are the arguments of every paginated list
+var pageArgs = graphql.FieldConfigArgument{
+	"first":  {Type: graphql.Int, DefaultValue: defaultPageSize, Description: "At most 100"},
+	"offset": {Type: graphql.Int, DefaultValue: 0},
+}
+
+func page(list []posts.Response, args map[string]any) []posts.Response {
+	first, _ := args["first"].(int)
+	offset, _ := args["offset"].(int)
+	if first < 0 || first > maxPageSize {
+		first = maxPageSize
+	}
+	if offset < 0 || offset >= len(list) {
+		return []posts.Response{}
+	}
+	list = list[offset:]
+	if first < len(list) {
+		list = list[:first]
+	}
+	return list
+}
+
+func withArgs(args graphql.FieldConfigArgument, extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	all := graphql.FieldConfigArgument{}
+	for name, arg := range args {
+		all[name] = arg
+	}
+	for name, arg := range extra {
+		all[name] = arg
+	}
+	return all
+}
+
+// optionalId parses an ID argument. Malformed ids match nothing.
+func optionalId(args map[string]any, name string) (uuid.UUID, bool) {
+	value, ok := args[name].(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(value)
+	return id, err == nil
+}
+
+// missing turns the not found errors of the services into null
+func missing(value any, err error) (any, error) {
+	if errors.Is(err, apierror.ErrNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func nonNullList(t graphql.Type) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+// newSchema builds the read-only schema of the public blog. Posts are only
+// ever the published ones.
+func (s *service) newSchema() (graphql.Schema, error) {
+	var postType, categoryType, tagType, authorType, navigationType *graphql.Object
+
+	alternateType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Alternate",
+		Description: "A published translation of a post, for hreflang links",
+		Fields: graphql.Fields{
+			"hreflang": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(a posts.Alternate) any { return a.Hreflang })},
+			"slug":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(a posts.Alternate) any { return a.Slug })},
+			"href":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(a posts.Alternate) any { return a.Href })},
+		},
+	})
+
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(p posts.Response) any { return p.ID.String() })},
+				"title":       {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return p.Title })},
+				"slug":        {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return p.Slug })},
+				"summary":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return p.Summary })},
+				"content":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return p.Content })},
+				"contentHtml": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return p.ContentHTML })},
+				"format":      {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return string(p.Format) })},
+				"columns":     {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(p posts.Response) any { return p.Columns })},
+				"locale":      {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p posts.Response) any { return p.Locale })},
+				"publishedAt": {Type: graphql.NewNonNull(graphql.DateTime), Resolve: field(func(p posts.Response) any { return p.PublishedAt })},
+				"updatedAt":   {Type: graphql.NewNonNull(graphql.DateTime), Resolve: field(func(p posts.Response) any { return p.UpdatedAt })},
+				"author": {Type: graphql.NewNonNull(authorType), Resolve: field(func(p posts.Response) any {
+					return author{ID: p.AuthorId, Username: p.AuthorName}
+				})},
+				"category": {
+					Type:        categoryType,
+					Description: "The main category",
+					Resolve:     s.resolvePostCategory,
+				},
+				"categories": {Type: nonNullList(categoryType), Resolve: s.resolvePostCategories},
+				"tags":       {Type: nonNullList(tagType), Resolve: s.resolvePostTags},
+				"alternates": {Type: nonNullList(alternateType), Resolve: s.resolvePostAlternates},
+			}
+		}),
+	})
+
+	categoryType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(c categories.Category) any { return c.ID.String() })},
+				"name":        {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(c categories.Category) any { return c.Name })},
+				"slug":        {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(c categories.Category) any { return c.Slug })},
+				"description": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(c categories.Category) any { return c.Description })},
+				"order":       {Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(c categories.Category) any { return c.Order })},
+				"parent":      {Type: categoryType, Resolve: s.resolveCategoryParent},
+				"children":    {Type: nonNullList(categoryType), Resolve: s.resolveCategoryChildren},
+				"posts": {
+					Type: nonNullList(postType),
+					Args: withArgs(pageArgs, graphql.FieldConfigArgument{
+						"includeDescendants": {Type: graphql.Boolean, DefaultValue: false, Description: "Also list the posts of every subcategory"},
+					}),
+					Resolve: s.resolveCategoryPosts,
+				},
+			}
+		}),
+	})
+
+	tagType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Tag",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(t tags.Tag) any { return t.ID.String() })},
+				"name": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(t tags.Tag) any { return t.Name })},
+				"slug": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(t tags.Tag) any { return t.Slug })},
+				"postCount": {
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "Published posts using the tag, in every locale",
+					Resolve:     s.resolveTagCount,
+				},
+				"posts": {Type: nonNullList(postType), Args: pageArgs, Resolve: s.resolveTagPosts},
+			}
+		}),
+	})
+
+	authorType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Author",
+		Description: "A user with published posts",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(a author) any { return a.ID.String() })},
+				"username": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(a author) any { return a.Username })},
+				"posts":    {Type: nonNullList(postType), Args: pageArgs, Resolve: s.resolveAuthorPosts},
+			}
+		}),
+	})
+
+	navigationType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "NavigationItem",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(n navigation.TreeNode) any { return n.ID.String() })},
+				"label":    {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(n navigation.TreeNode) any { return n.Label })},
+				"href":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(n navigation.TreeNode) any { return n.Href })},
+				"external": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: field(func(n navigation.TreeNode) any { return n.Type == navigation.TypeExternal })},
+				"category": {Type: categoryType, Description: "The category the item links to", Resolve: s.resolveNavigationCategory},
+				"children": {Type: nonNullList(navigationType), Resolve: field(func(n navigation.TreeNode) any { return n.Children })},
+			}
+		}),
+	})
+
+	menuType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Menu",
+		Fields: graphql.Fields{
+			"id":       {Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(m *navigation.MenuResponse) any { return m.ID.String() })},
+			"name":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(m *navigation.MenuResponse) any { return m.Name })},
+			"slug":     {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(m *navigation.MenuResponse) any { return m.Slug })},
+			"location": {Type: graphql.NewNonNull(graphql.String), Resolve: field(func(m *navigation.MenuResponse) any { return string(m.Location) })},
+			"items":    {Type: nonNullList(navigationType), Resolve: field(func(m *navigation.MenuResponse) any { return m.Items })},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"post": {
+				Type:        postType,
+				Description: "A published post by slug or id",
+				Args: graphql.FieldConfigArgument{
+					"slug": {Type: graphql.String},
+					"id":   {Type: graphql.ID},
+				},
+				Resolve: s.resolvePost,
+			},
+			"posts": {
+				Type:        nonNullList(postType),
+				Description: "Published posts in the request's locale, newest first",
+				Args: withArgs(pageArgs, graphql.FieldConfigArgument{
+					"category": {Type: graphql.String, Description: "Category slug"},
+					"tag":      {Type: graphql.String, Description: "Tag slug"},
+					"author":   {Type: graphql.ID},
+				}),
+				Resolve: s.resolvePosts,
+			},
+			"category": {
+				Type: categoryType,
+				Args: graphql.FieldConfigArgument{
+					"slug": {Type: graphql.String},
+					"id":   {Type: graphql.ID},
+				},
+				Resolve: s.resolveCategory,
+			},
+			"categories": {
+				Type: nonNullList(categoryType),
+				Args: graphql.FieldConfigArgument{
+					"roots": {Type: graphql.Boolean, DefaultValue: false, Description: "Only the top-level categories"},
+				},
+				Resolve: s.resolveCategories,
+			},
+			"tag": {
+				Type:    tagType,
+				Args:    graphql.FieldConfigArgument{"slug": {Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: s.resolveTag,
+			},
+			"tags": {Type: nonNullList(tagType), Resolve: s.resolveTags},
+			"author": {
+				Type:    authorType,
+				Args:    graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: s.resolveAuthor,
+			},
+			"navigation": {
+				Type:        nonNullList(navigationType),
+				Description: "The navigation tree in the request's locale",
+				Resolve:     s.resolveNavigation,
+			},
+			"menu": {
+				Type:    menuType,
+				Args:    graphql.FieldConfigArgument{"slug": {Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: s.resolveMenu,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+	if err != nil {
+		return schema, err
+	}
+	for name, named := range schema.TypeMap() {
+		object, ok := named.(*graphql.Object)
+		if !ok || strings.HasPrefix(name, "__") {
+			continue
+		}
+		for _, definition := range object.Fields() {
+			if definition.Resolve != nil {
+				definition.Resolve = localized(definition.Resolve)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// localized turns the errors of a resolver, or of the thunk it returns, into
+// field errors in the request's locale
+func localized(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		locale := loadersFrom(p.Context).locale
+		value, err := resolve(p)
+		if err != nil {
+			return nil, newFieldError(err, locale)
+		}
+		if load, ok := value.(thunk); ok {
+			return thunk(func() (any, error) {
+				value, err := load()
+				if err != nil {
+					return nil, newFieldError(err, locale)
+				}
+				return value, nil
+			}), nil
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,215 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/categories"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/posts"
+	"havamal-api/internal/tags"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type Service interface {
+	// Execute runs a request in locale. Failures are part of the result, as
+	// GraphQL errors; internal causes are returned apart, to be logged.
+	Execute(ctx context.Context, request *Request, locale string) (*graphql.Result, []error)
+	// Persist registers a query and returns its hash
+	Persist(query string) (string, error)
+}
+
+type service struct {
+	repository        Repository
+	config            Config
+	postService       posts.Service
+	categoryService   categories.Service
+	tagService        tags.Service
+	navigationService navigation.Service
+	schema            graphql.Schema
+}
+
+func NewService(repository Repository, config Config, postService posts.Service, categoryService categories.Service, tagService tags.Service, navigationService navigation.Service) (Service, error) {
+	s := &service{
+		repository:        repository,
+		config:            config,
+		postService:       postService,
+		categoryService:   categoryService,
+		tagService:        tagService,
+		navigationService: navigationService,
+	}
+	schema, err := s.newSchema()
+	if err != nil {
+		return nil, err
+	}
+	s.schema = schema
+	return s, nil
+}
+
+func (s *service) Execute(ctx context.Context, request *Request, locale string) (*graphql.Result, []error) {
+	query, persist, err := s.query(request)
+	if err != nil {
+		return failure(err, locale), causes(err)
+	}
+	document, errs := s.parse(query)
+	if errs != nil {
+		return invalid(errs), nil
+	}
+	selected, err := s.check(&s.schema, document, request.Variables)
+	if err != nil {
+		return failure(err, locale), nil
+	}
+	if persist {
+		if err := s.repository.SaveQuery(hashOf(query), query); err != nil {
+			return failure(err, locale), causes(err)
+		}
+	}
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       withLoaders(ctx, s.newLoaders(locale, selected)),
+	})
+	var internal []error
+	for i, formatted := range result.Errors {
+		fieldErr, ok := cause(formatted).(*fieldError)
+		if !ok {
+			continue
+		}
+		result.Errors[i].Message = fieldErr.Error()
+		result.Errors[i].Extensions = fieldErr.Extensions()
+		if fieldErr.code == apierror.Internal {
+			internal = append(internal, fieldErr.cause)
+		}
+	}
+	return result, internal
+}
+
+// query returns the text of the query a request runs. A request may send the
+// text, the hash of a persisted query or both, which asks for the query to be
+// persisted once it proves valid: persist tells whether it should be. Only
+// POST requests register queries, and none do when only persisted queries are
+// accepted.
+func (s *service) query(request *Request) (query string, persist bool, err error) {
+	if len(request.Query) > s.config.MaxQuerySize {
+		return "", false, ErrQueryTooLarge
+	}
+	if request.Extensions == nil || request.Extensions.PersistedQuery == nil {
+		if s.config.PersistedOnly {
+			return "", false, ErrPersistedOnly
+		}
+		if strings.TrimSpace(request.Query) == "" {
+			return "", false, ErrQueryRequired
+		}
+		return request.Query, false, nil
+	}
+	hash := strings.ToLower(request.Extensions.PersistedQuery.Sha256Hash)
+	if request.Query == "" {
+		query, err := s.repository.GetQuery(hash)
+		return query, false, err
+	}
+	if hashOf(request.Query) != hash {
+		return "", false, ErrHashMismatch
+	}
+	if s.config.PersistedOnly {
+		if _, err := s.repository.GetQuery(hash); err != nil {
+			if errors.Is(err, ErrPersistedNotFound) {
+				return "", false, ErrPersistedOnly
+			}
+			return "", false, err
+		}
+		return request.Query, false, nil
+	}
+	return request.Query, request.register, nil
+}
+
+func (s *service) Persist(query string) (string, error) {
+	if len(query) > s.config.MaxQuerySize {
+		return "", ErrQueryTooLarge
+	}
+	if _, errs := s.parse(query); errs != nil {
+		return "", apierror.New(apierror.GraphQLInvalidQuery)
+	}
+	hash := hashOf(query)
+	return hash, s.repository.SaveQuery(hash, query)
+}
+
+// parse parses and validates a query against the schema
+func (s *service) parse(query string) (*ast.Document, []gqlerrors.FormattedError) {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"})})
+	if err != nil {
+		return nil, gqlerrors.FormatErrors(err)
+	}
+	if validation := graphql.ValidateDocument(&s.schema, document, nil); !validation.IsValid {
+		return nil, validation.Errors
+	}
+	return document, nil
+}
+
+func hashOf(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// failure is the result of a request refused before it runs. The message of
+// an unknown persisted query is the one Apollo clients look for to send the
+// query text again.
+func failure(err error, locale string) *graphql.Result {
+	code := apierror.Lookup(err)
+	message := apierror.Message(code, locale)
+	if code == apierror.GraphQLPersistedNotFound {
+		message = "PersistedQueryNotFound"
+	}
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+		Message:    message,
+		Extensions: map[string]any{"code": code},
+	}}}
+}
+
+// invalid is the result of a query that does not parse or validate, with
+// the errors that say why
+func invalid(errs []gqlerrors.FormattedError) *graphql.Result {
+	for i := range errs {
+		errs[i].Extensions = map[string]any{"code": apierror.GraphQLInvalidQuery}
+	}
+	return &graphql.Result{Errors: errs}
+}
+
+// causes lists err when it is internal, for the handler to log
+func causes(err error) []error {
+	if apierror.Lookup(err) == apierror.Internal {
+		return []error{err}
+	}
+	return nil
+}
+
+// cause digs the error a resolver returned out of the wrappers of the
+// executor
+func cause(err error) error {
+	for {
+		switch wrapped := err.(type) {
+		case gqlerrors.FormattedError:
+			if wrapped.OriginalError() == nil {
+				return err
+			}
+			err = wrapped.OriginalError()
+		case *gqlerrors.Error:
+			if wrapped.OriginalError == nil {
+				return err
+			}
+			err = wrapped.OriginalError
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/tags"
+
+	"github.com/google/uuid"
+)
+
+type fakeRepository struct {
+	queries map[string]string
+	saved   []string
+}
+
+func (f *fakeRepository) GetQuery(hash string) (string, error) {
+	query, ok := f.queries[hash]
+	if !ok {
+		return "", ErrPersistedNotFound
+	}
+	return query, nil
+}
+
+func (f *fakeRepository) SaveQuery(hash string, query string) error {
+	f.saved = append(f.saved, hash)
+	return nil
+}
+
+// fakeTags lets the loaders be built; no test query loads tags
+type fakeTags struct {
+	tags.Service
+}
+
+func (fakeTags) GetByPosts(postIds []uuid.UUID) (map[uuid.UUID][]tags.Tag, error) {
+	return nil, nil
+}
+
+func persisted(query string, hash string, register bool) *Request {
+	return &Request{
+		Query:      query,
+		Extensions: &Extensions{PersistedQuery: &PersistedQuery{Sha256Hash: hash}},
+		register:   register,
+	}
+}
+
+func TestExecutePersistedQueries(t *testing.T) {
+	valid := `{ __typename }`
+	stored := `query Stored { __typename }`
+	tests := []struct {
+		name          string
+		persistedOnly bool
+		request       *Request
+		code          apierror.Code
+		saved         bool
+	}{
+		{"text only", false, &Request{Query: valid}, "", false},
+		{"registered from a POST", false, persisted(valid, hashOf(valid), true), "", true},
+		{"not registered from a GET", false, persisted(valid, hashOf(valid), false), "", false},
+		{"hash only", false, persisted("", hashOf(stored), false), "", false},
+		{"unknown hash", false, persisted("", hashOf(valid), false), apierror.GraphQLPersistedNotFound, false},
+		{"hash mismatch", false, persisted(valid, hashOf(stored), true), apierror.GraphQLPersistedHashInvalid, false},
+		{"invalid query is not stored", false, persisted(`{ nothing }`, hashOf(`{ nothing }`), true), apierror.GraphQLInvalidQuery, false},
+		{"too complex query is not stored", false, persisted(`{ posts(first: 100) { title } }`, hashOf(`{ posts(first: 100) { title } }`), true), apierror.GraphQLTooComplex, false},
+		{"too large", false, &Request{Query: "{ __typename " + strings.Repeat(" ", 200) + "}"}, apierror.GraphQLQueryTooLarge, false},
+		{"persisted only refuses text", true, &Request{Query: valid}, apierror.GraphQLPersistedOnly, false},
+		{"persisted only refuses new queries", true, persisted(valid, hashOf(valid), true), apierror.GraphQLPersistedOnly, false},
+		{"persisted only runs stored queries", true, persisted(stored, hashOf(stored), true), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{queries: map[string]string{hashOf(stored): stored}}
+			s := newTestService(t, Config{MaxDepth: 5, MaxComplexity: 50, MaxQuerySize: 200, PersistedOnly: tt.persistedOnly})
+			s.repository = repository
+			s.tagService = fakeTags{}
+
+			result, internal := s.Execute(context.Background(), tt.request, "en")
+			if len(internal) > 0 {
+				t.Fatalf("Execute() internal errors = %v", internal)
+			}
+			var code apierror.Code
+			if len(result.Errors) > 0 {
+				code, _ = result.Errors[0].Extensions["code"].(apierror.Code)
+			}
+			if code != tt.code {
+				t.Errorf("Execute() code = %q, want %q (%v)", code, tt.code, result.Errors)
+			}
+			if saved := len(repository.saved) > 0; saved != tt.saved {
+				t.Errorf("Execute() saved = %v, want %v", saved, tt.saved)
+			}
+		})
+	}
+}
+
+func TestPersist(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr apierror.Code
+	}{
+		{"valid", `{ __typename }`, ""},
+		{"invalid", `{ nothing }`, apierror.GraphQLInvalidQuery},
+		{"too large", "{ __typename " + strings.Repeat(" ", 200) + "}", apierror.GraphQLQueryTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{}
+			s := newTestService(t, Config{MaxQuerySize: 200})
+			s.repository = repository
+			hash, err := s.Persist(tt.query)
+			if tt.wantErr != "" {
+				if code := apierror.Lookup(err); code != tt.wantErr {
+					t.Fatalf("Persist() error = %v, want %s", err, tt.wantErr)
+				}
+				if len(repository.saved) > 0 {
+					t.Error("Persist() stored a refused query")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Persist() error = %v", err)
+			}
+			if hash != hashOf(tt.query) || len(repository.saved) != 1 {
+				t.Errorf("Persist() = %s, saved %v", hash, repository.saved)
+			}
+		})
+	}
+}
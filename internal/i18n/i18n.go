@@ -0,0 +1,68 @@
+// Package i18n picks the locale of a request among the ones the blog is
+// published in.
+package i18n
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// Default is the locale content is written in unless stated otherwise, and
+// the one readers fall back to
+const Default = "ca"
+
+// Supported lists the locales content may be published in, Default first
+var Supported = []string{Default, "es", "en"}
+
+var (
+	ErrUnsupportedLocale = errors.New("unsupported locale")
+	ErrDefaultLocale     = errors.New("the default locale is edited on the resource itself, not as a translation")
+)
+
+var matcher = language.NewMatcher([]language.Tag{language.Catalan, language.Spanish, language.English})
+
+// IsSupported reports whether locale is one of Supported
+func IsSupported(locale string) bool {
+	for _, supported := range Supported {
+		if locale == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns Default for an empty locale and rejects unsupported ones
+func Normalize(locale string) (string, error) {
+	if locale == "" {
+		return Default, nil
+	}
+	if !IsSupported(locale) {
+		return "", ErrUnsupportedLocale
+	}
+	return locale, nil
+}
+
+// FromRequest returns the locale asked for with ?lang=, else the best match
+// of the Accept-Language header, else Default. Responses then vary on
+// Accept-Language.
+func FromRequest(c *gin.Context) string {
+	c.Header("Vary", "Accept-Language")
+	if lang := c.Query("lang"); IsSupported(lang) {
+		return lang
+	}
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return Default
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Supported[index]
+}
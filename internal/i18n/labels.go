@@ -0,0 +1,19 @@
+package i18n
+
+// Keys of the interface text the API writes into responses itself
+const (
+	LabelHome = "home"
+)
+
+var labels = map[string]map[string]string{
+	LabelHome: {"ca": "Inici", "es": "Inicio", "en": "Home"},
+}
+
+// Label returns the text of key in locale, falling back to Default
+func Label(key string, locale string) string {
+	texts := labels[key]
+	if text, ok := texts[locale]; ok {
+		return text
+	}
+	return texts[Default]
+}
@@ -1,21 +1,20 @@
 package images
 
 import (
-	"fmt"
-	"io"
+	"havamal-api/internal/apierror"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
+
+	"havamal-api/internal/media"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-type Handler struct{}
+type Handler struct {
+	mediaService media.Service
+}
 
-func NewHandler() Handler {
-	return Handler{}
+func NewHandler(mediaService media.Service) Handler {
+	return Handler{mediaService: mediaService}
 }
 
 // UploadImage handles image file uploads
@@ -23,66 +22,23 @@ func (h *Handler) UploadImage(c *gin.Context) {
 	// Get the file from the request
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
+		apierror.Write(c, apierror.MediaMissingFile)
 		return
 	}
 	defer file.Close()
 
-	// Validate file size (max 10MB)
-	const maxFileSize = 10 * 1024 * 1024 // 10MB
-	if header.Size > maxFileSize {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File size exceeds 10MB limit"})
-		return
-	}
-
-	// Validate file type
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	allowedExts := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
-	}
-
-	if !allowedExts[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Allowed: jpg, jpeg, png, gif, webp"})
-		return
-	}
-
-	// Generate unique filename
-	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-
-	// Determine the images directory path
-	// Assuming images directory is at the project root level
-	imagesDir := filepath.Join("..", "images")
-	
-	// Create images directory if it doesn't exist
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create images directory"})
-		return
-	}
-
-	// Create the destination file
-	destPath := filepath.Join(imagesDir, filename)
-	dest, err := os.Create(destPath)
+	// Size and type limits come from the image media kind
+	image, err := h.mediaService.Upload(file, header, media.KindImage)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
-		return
-	}
-	defer dest.Close()
-
-	// Copy the uploaded file to the destination
-	if _, err := io.Copy(dest, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		apierror.Respond(c, err)
 		return
 	}
 
 	// Return the image URL
-	imageURL := fmt.Sprintf("./images/%s", filename)
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Image uploaded successfully",
-		"url":     imageURL,
-		"filename": filename,
+		"message":  "Image uploaded successfully",
+		"id":       image.ID,
+		"url":      image.URL,
+		"filename": image.Filename,
 	})
 }
@@ -1,7 +1,10 @@
 package images
 
 import (
+	"havamal-api/internal/openapi"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // RegisterRoutes registers the protected image routes
@@ -11,3 +14,17 @@ func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 		images.POST("/upload", handler.UploadImage)
 	}
 }
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/images/upload", Tag: "media", Summary: "Upload an image",
+			Body: openapi.Form("image", nil), BodyMedia: "multipart/form-data",
+			Response: struct {
+				Message  string    `json:"message"`
+				ID       uuid.UUID `json:"id"`
+				URL      string    `json:"url"`
+				Filename string    `json:"filename"`
+			}{}},
+	}
+}
@@ -0,0 +1,12 @@
+package media
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound         = apierror.New(apierror.MediaNotFound)
+	ErrMediaInUse       = apierror.New(apierror.MediaInUse)
+	ErrUnsupportedType  = apierror.New(apierror.MediaUnsupportedType)
+	ErrFileTooLarge     = apierror.New(apierror.MediaTooLarge)
+	ErrInvalidSignature = apierror.New(apierror.MediaInvalidSignature)
+	ErrSignatureExpired = apierror.New(apierror.MediaSignatureExpired)
+)
@@ -0,0 +1,174 @@
+package media
+
+import (
+	"errors"
+	"havamal-api/internal/apierror"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// Upload handles uploads of any media kind from the "file" form field
+func (h *Handler) Upload(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		apierror.Write(c, apierror.MediaMissingFile)
+		return
+	}
+	defer file.Close()
+
+	media, err := h.service.Upload(file, header)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if c.PostForm("private") == "true" {
+		media, err = h.service.SetVisibility(media.ID.String(), true)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+// Serve streams a media file with Range support, so audio and video can seek.
+// Files are shown inline unless ?download=true is given. Private media needs
+// a signed URL.
+func (h *Handler) Serve(c *gin.Context) {
+	id := c.Param("id")
+	media, file, err := h.service.Open(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	defer file.Close()
+	h.serve(c, media, file)
+}
+
+// ServeFile serves a file of the media directory by name, replacing the
+// public static /images route so private images stay behind signatures.
+func (h *Handler) ServeFile(c *gin.Context) {
+	filename := c.Param("filename")
+	media, file, err := h.service.OpenFile(filename)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	defer file.Close()
+	h.serve(c, media, file)
+}
+
+func (h *Handler) serve(c *gin.Context, media *Media, file *os.File) {
+	if err := h.service.Authorize(media, c.Query("expires"), c.Query("signature")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	filename := info.Name()
+	if media != nil {
+		if media.OriginalName != "" {
+			filename = media.OriginalName
+		}
+		c.Header("Content-Type", media.MimeType)
+		if media.IsPrivate {
+			c.Header("Cache-Control", "private, no-store")
+		}
+	}
+
+	disposition := "inline"
+	if c.Query("download") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, filename, info.ModTime(), file)
+}
+
+// Sign mints an expiring signed URL for a media file
+func (h *Handler) Sign(c *gin.Context) {
+	id := c.Param("id")
+	var request SignRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+	}
+	signed, err := h.service.Sign(id, time.Duration(request.ExpiresIn)*time.Second)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, signed)
+}
+
+func (h *Handler) SetVisibility(c *gin.Context) {
+	id := c.Param("id")
+	var request VisibilityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	media, err := h.service.SetVisibility(id, request.IsPrivate)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+func (h *Handler) GetAll(c *gin.Context) {
+	media, err := h.service.GetAll()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+func (h *Handler) GetById(c *gin.Context) {
+	id := c.Param("id")
+	media, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	force := c.Query("force") == "true"
+	usage, err := h.service.Delete(id, force)
+	if err != nil {
+		if errors.Is(err, ErrMediaInUse) {
+			apierror.Respond(c, err, gin.H{"used_in": usage})
+			return
+		}
+		apierror.Respond(c, err)
+		return
+	}
+	if len(usage) > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully", "warning": "Media was still referenced by posts", "used_in": usage})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
+}
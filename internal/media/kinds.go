@@ -0,0 +1,109 @@
+package media
+
+import (
+	"sort"
+	"strings"
+)
+
+type Kind string
+
+const (
+	KindImage    Kind = "image"
+	KindDocument Kind = "document"
+	KindAudio    Kind = "audio"
+	KindVideo    Kind = "video"
+)
+
+// KindRule describes what may be uploaded for a media kind: the maximum file
+// size and the accepted extensions with the MIME type they are served as.
+type KindRule struct {
+	MaxSize    int64
+	Extensions map[string]string
+}
+
+const megabyte = 1024 * 1024
+
+var kindRules = map[Kind]KindRule{
+	KindImage: {
+		MaxSize: 10 * megabyte,
+		Extensions: map[string]string{
+			".jpg":  "image/jpeg",
+			".jpeg": "image/jpeg",
+			".png":  "image/png",
+			".gif":  "image/gif",
+			".webp": "image/webp",
+		},
+	},
+	KindDocument: {
+		MaxSize: 25 * megabyte,
+		Extensions: map[string]string{
+			".pdf": "application/pdf",
+		},
+	},
+	KindAudio: {
+		MaxSize: 100 * megabyte,
+		Extensions: map[string]string{
+			".mp3":  "audio/mpeg",
+			".wav":  "audio/wav",
+			".flac": "audio/flac",
+			".ogg":  "audio/ogg",
+			".m4a":  "audio/mp4",
+		},
+	},
+	KindVideo: {
+		MaxSize: 500 * megabyte,
+		Extensions: map[string]string{
+			".mp4":  "video/mp4",
+			".webm": "video/webm",
+		},
+	},
+}
+
+// sniffAliases lists what http.DetectContentType reports for the accepted
+// types when it differs from the MIME type we serve.
+var sniffAliases = map[string][]string{
+	"audio/wav":  {"audio/wave"},
+	"audio/ogg":  {"application/ogg"},
+	"audio/mp4":  {"video/mp4"},
+	"audio/flac": {"audio/x-flac"},
+}
+
+// kindForExtension returns the kind accepting ext and the MIME type files with
+// that extension are served as.
+func kindForExtension(ext string) (Kind, string, bool) {
+	ext = strings.ToLower(ext)
+	for kind, rule := range kindRules {
+		if mimeType, ok := rule.Extensions[ext]; ok {
+			return kind, mimeType, true
+		}
+	}
+	return "", "", false
+}
+
+// sniffMatches reports whether the sniffed content type is consistent with the
+// MIME type expected from the extension. Formats the sniffer does not know are
+// reported as application/octet-stream and accepted on the extension alone.
+func sniffMatches(sniffed, expected string) bool {
+	sniffed = strings.TrimSpace(strings.SplitN(sniffed, ";", 2)[0])
+	if sniffed == expected || sniffed == "application/octet-stream" {
+		return true
+	}
+	for _, alias := range sniffAliases[expected] {
+		if sniffed == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedExtensions lists the extensions accepted for the given kinds.
+func AllowedExtensions(kinds ...Kind) []string {
+	exts := make([]string, 0)
+	for _, kind := range kinds {
+		for ext := range kindRules[kind].Extensions {
+			exts = append(exts, strings.TrimPrefix(ext, "."))
+		}
+	}
+	sort.Strings(exts)
+	return exts
+}
@@ -0,0 +1,305 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Metadata holds the properties extracted from an uploaded file. Fields that
+// do not apply to the file kind, or could not be read, are left nil.
+type Metadata struct {
+	Width           *int
+	Height          *int
+	PageCount       *int
+	DurationSeconds *float64
+}
+
+// ExtractMetadata reads basic metadata from the file at path. Extraction is
+// best effort: unknown or malformed files yield empty metadata, not an error.
+func ExtractMetadata(path string, mimeType string) Metadata {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}
+	}
+	defer f.Close()
+
+	var meta Metadata
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			meta.Width, meta.Height = &cfg.Width, &cfg.Height
+		}
+	case "application/pdf":
+		if pages, err := pdfPageCount(f); err == nil {
+			meta.PageCount = &pages
+		}
+	case "audio/wav":
+		if seconds, err := wavDuration(f); err == nil {
+			meta.DurationSeconds = &seconds
+		}
+	case "audio/mpeg":
+		if seconds, err := mp3Duration(f); err == nil {
+			meta.DurationSeconds = &seconds
+		}
+	case "audio/flac":
+		if seconds, err := flacDuration(f); err == nil {
+			meta.DurationSeconds = &seconds
+		}
+	case "audio/mp4", "video/mp4":
+		if seconds, err := mp4Duration(f); err == nil {
+			meta.DurationSeconds = &seconds
+		}
+	}
+	return meta
+}
+
+var errNoMetadata = errors.New("metadata not found")
+
+var (
+	pdfPagesCount = regexp.MustCompile(`/Type\s*/Pages\b[^>]*?/Count\s+(\d+)|/Count\s+(\d+)[^>]*?/Type\s*/Pages\b`)
+	pdfPageObject = regexp.MustCompile(`/Type\s*/Page[^s]`)
+)
+
+// pdfPageCount prefers the largest /Count of a page tree node, which is the
+// root, and falls back to counting page objects.
+func pdfPageCount(r io.Reader) (int, error) {
+	data, err := io.ReadAll(io.LimitReader(r, kindRules[KindDocument].MaxSize))
+	if err != nil {
+		return 0, err
+	}
+	pages := 0
+	for _, match := range pdfPagesCount.FindAllSubmatch(data, -1) {
+		value := match[1]
+		if len(value) == 0 {
+			value = match[2]
+		}
+		if n, err := strconv.Atoi(string(value)); err == nil && n > pages {
+			pages = n
+		}
+	}
+	if pages == 0 {
+		pages = len(pdfPageObject.FindAll(data, -1))
+	}
+	if pages == 0 {
+		return 0, errNoMetadata
+	}
+	return pages, nil
+}
+
+// wavDuration walks the RIFF chunks for the byte rate and the data size.
+func wavDuration(r io.Reader) (float64, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, errNoMetadata
+	}
+	var byteRate uint32
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return 0, err
+		}
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			// Only the fixed 16 byte PCM header is read, whatever size the
+			// chunk claims, and the extension bytes are skipped
+			var body [16]byte
+			if size < uint32(len(body)) {
+				return 0, errNoMetadata
+			}
+			if _, err := io.ReadFull(r, body[:]); err != nil {
+				return 0, err
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(size-uint32(len(body)))+int64(size%2)); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(body[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, errNoMetadata
+			}
+			return float64(size) / float64(byteRate), nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+var (
+	mp3Bitrates = [2][16]int{
+		// MPEG-1 Layer III
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		// MPEG-2/2.5 Layer III
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	mp3SampleRates = [3][3]int{
+		{44100, 48000, 32000}, // MPEG-1
+		{22050, 24000, 16000}, // MPEG-2
+		{11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// mp3Duration reads the first Layer III frame header. The frame count from a
+// Xing/Info header gives an exact duration; otherwise the stream is assumed to
+// be constant bitrate.
+func mp3Duration(f *os.File) (float64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	data := make([]byte, 64*1024)
+	n, err := io.ReadFull(f, data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, err
+	}
+	data = data[:n]
+
+	offset, base := 0, int64(0)
+	if len(data) >= 10 && string(data[0:3]) == "ID3" {
+		size := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+		offset = 10 + size
+		if offset >= len(data) {
+			if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+				return 0, err
+			}
+			n, err := io.ReadFull(f, data[:cap(data)])
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, err
+			}
+			data = data[:n]
+			base, offset = int64(offset), 0
+		}
+	}
+
+	for i := offset; i+4 <= len(data); i++ {
+		if data[i] != 0xff || data[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+		versionBits := (data[i+1] >> 3) & 0x03
+		layerBits := (data[i+1] >> 1) & 0x03
+		bitrateIndex := data[i+2] >> 4
+		rateIndex := (data[i+2] >> 2) & 0x03
+		if versionBits == 1 || layerBits != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+
+		version, samplesPerFrame, bitrateTable := 0, 1152, 0
+		switch versionBits {
+		case 2:
+			version, samplesPerFrame, bitrateTable = 1, 576, 1
+		case 0:
+			version, samplesPerFrame, bitrateTable = 2, 576, 1
+		}
+		sampleRate := mp3SampleRates[version][rateIndex]
+		bitrate := mp3Bitrates[bitrateTable][bitrateIndex] * 1000
+
+		for _, tag := range []string{"Xing", "Info"} {
+			pos := bytes.Index(data[i:min(i+200, len(data))], []byte(tag))
+			if pos < 0 || i+pos+12 > len(data) {
+				continue
+			}
+			flags := binary.BigEndian.Uint32(data[i+pos+4 : i+pos+8])
+			if flags&0x1 != 0 {
+				frames := binary.BigEndian.Uint32(data[i+pos+8 : i+pos+12])
+				return float64(frames) * float64(samplesPerFrame) / float64(sampleRate), nil
+			}
+		}
+
+		audioBytes := stat.Size() - base - int64(i)
+		return float64(audioBytes) * 8 / float64(bitrate), nil
+	}
+	return 0, errNoMetadata
+}
+
+// flacDuration reads total samples and sample rate from the STREAMINFO block.
+func flacDuration(r io.Reader) (float64, error) {
+	var header [42]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	if string(header[0:4]) != "fLaC" || header[4]&0x7f != 0 {
+		return 0, errNoMetadata
+	}
+	info := header[8:]
+	sampleRate := uint32(info[10])<<12 | uint32(info[11])<<4 | uint32(info[12])>>4
+	totalSamples := uint64(info[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 || totalSamples == 0 {
+		return 0, errNoMetadata
+	}
+	return float64(totalSamples) / float64(sampleRate), nil
+}
+
+// mp4Duration finds moov/mvhd and divides its duration by its timescale.
+func mp4Duration(f *os.File) (float64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return mp4FindDuration(f, 0, info.Size(), []string{"moov", "mvhd"})
+}
+
+func mp4FindDuration(f *os.File, start, end int64, path []string) (float64, error) {
+	var header [16]byte
+	for pos := start; pos+8 <= end; {
+		if _, err := f.ReadAt(header[:8], pos); err != nil {
+			return 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			if _, err := f.ReadAt(header[8:16], pos+8); err != nil {
+				return 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return 0, errNoMetadata
+		}
+		if boxType == path[0] {
+			if len(path) > 1 {
+				return mp4FindDuration(f, pos+headerSize, pos+size, path[1:])
+			}
+			return mvhdDuration(f, pos+headerSize)
+		}
+		pos += size
+	}
+	return 0, errNoMetadata
+}
+
+func mvhdDuration(f *os.File, pos int64) (float64, error) {
+	var body [32]byte
+	if _, err := f.ReadAt(body[:], pos); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	var timescale, duration uint64
+	if body[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(body[20:24]))
+		duration = binary.BigEndian.Uint64(body[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(body[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+	}
+	if timescale == 0 {
+		return 0, errNoMetadata
+	}
+	return float64(duration) / float64(timescale), nil
+}
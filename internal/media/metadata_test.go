@@ -0,0 +1,138 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+type chunk struct {
+	id   string
+	size uint32
+	body []byte
+}
+
+func riff(chunks ...chunk) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	b.WriteString("WAVE")
+	for _, c := range chunks {
+		b.WriteString(c.id)
+		binary.Write(&b, binary.LittleEndian, c.size)
+		b.Write(c.body)
+	}
+	return b.Bytes()
+}
+
+func pcm(byteRate uint32, extra int) chunk {
+	body := make([]byte, 16+extra)
+	binary.LittleEndian.PutUint16(body[0:2], 1)
+	binary.LittleEndian.PutUint16(body[2:4], 2)
+	binary.LittleEndian.PutUint32(body[4:8], 44100)
+	binary.LittleEndian.PutUint32(body[8:12], byteRate)
+	return chunk{"fmt ", uint32(len(body)), body}
+}
+
+func TestWavDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    float64
+		wantErr bool
+	}{
+		{"pcm", riff(pcm(176400, 0), chunk{"data", 352800, nil}), 2, false},
+		{"extended fmt", riff(pcm(1000, 2), chunk{"data", 500, nil}), 0.5, false},
+		{"odd chunk is padded", riff(pcm(1000, 0), chunk{"LIST", 3, []byte{1, 2, 3, 0}}, chunk{"data", 4000, nil}), 4, false},
+		{"not riff", []byte("RIFX\x00\x00\x00\x00WAVE"), 0, true},
+		{"data before fmt", riff(chunk{"data", 100, nil}), 0, true},
+		{"short fmt", riff(chunk{"fmt ", 8, make([]byte, 8)}, chunk{"data", 100, nil}), 0, true},
+		{"huge fmt size", riff(chunk{"fmt ", math.MaxUint32, make([]byte, 16)}), 0, true},
+		{"huge skipped chunk", riff(pcm(1000, 0), chunk{"junk", math.MaxUint32, nil}), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wavDuration(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wavDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("wavDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A crafted fmt size must not be allocated up front
+func TestWavDurationBoundsFmtChunk(t *testing.T) {
+	data := riff(chunk{"fmt ", math.MaxUint32, make([]byte, 16)})
+	allocs := testing.AllocsPerRun(10, func() {
+		wavDuration(bytes.NewReader(data))
+	})
+	if allocs > 10 {
+		t.Errorf("wavDuration() made %v allocations", allocs)
+	}
+}
+
+func TestFlacDuration(t *testing.T) {
+	streamInfo := func(sampleRate uint32, samples uint64) []byte {
+		header := make([]byte, 42)
+		copy(header, "fLaC")
+		info := header[8:]
+		info[10] = byte(sampleRate >> 12)
+		info[11] = byte(sampleRate >> 4)
+		info[12] = byte(sampleRate<<4) | 0x02
+		info[13] = byte(samples>>32) & 0x0f
+		binary.BigEndian.PutUint32(info[14:18], uint32(samples))
+		return header
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    float64
+		wantErr bool
+	}{
+		{"streaminfo", streamInfo(44100, 441000), 10, false},
+		{"no samples", streamInfo(44100, 0), 0, true},
+		{"not flac", make([]byte, 42), 0, true},
+		{"truncated", []byte("fLaC"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := flacDuration(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("flacDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("flacDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPdfPageCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{"page tree", "<< /Type /Pages /Kids [3 0 R] /Count 3 >> << /Type /Pages /Count 12 >>", 12, false},
+		{"count first", "<< /Count 5 /Type /Pages >>", 5, false},
+		{"page objects", "<< /Type /Page >> << /Type /Page >>", 2, false},
+		{"empty", "%PDF-1.7", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pdfPageCount(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pdfPageCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pdfPageCount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
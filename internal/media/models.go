@@ -0,0 +1,49 @@
+package media
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Media struct {
+	ID              uuid.UUID  `json:"id"`
+	Kind            Kind       `json:"kind"`
+	Filename        string     `json:"filename"`
+	OriginalName    string     `json:"original_name"`
+	URL             string     `json:"url"`
+	MimeType        string     `json:"mime_type"`
+	Size            int64      `json:"size"`
+	Width           *int       `json:"width,omitempty"`
+	Height          *int       `json:"height,omitempty"`
+	PageCount       *int       `json:"page_count,omitempty"`
+	DurationSeconds *float64   `json:"duration_seconds,omitempty"`
+	IsPrivate       bool       `json:"is_private"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+}
+
+type Usage struct {
+	PostId uuid.UUID `json:"post_id"`
+	Title  string    `json:"title"`
+	Slug   string    `json:"slug"`
+	Status string    `json:"status"`
+}
+
+type Response struct {
+	Media
+	UsedIn []Usage `json:"used_in"`
+}
+
+type VisibilityRequest struct {
+	IsPrivate bool `json:"is_private"`
+}
+
+type SignRequest struct {
+	ExpiresIn int `json:"expires_in"`
+}
+
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,50 @@
+package media
+
+import (
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// referencePattern matches uploaded file URLs (images/<uuid>.<ext>) and media
+// API URLs (media/<uuid>) inside post content, whatever markup wraps them.
+var referencePattern = regexp.MustCompile(`(?i)(?:images|media)/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+
+// urlPattern matches a whole reference up to the end of its path, with the
+// question mark of a query that follows it
+var urlPattern = regexp.MustCompile(`(?i)(?:images|media)/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})(?:\.[0-9a-z]+)?(\?)?`)
+
+// ExtractReferences returns the distinct media ids referenced in content.
+func ExtractReferences(content string) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	ids := make([]uuid.UUID, 0)
+	for _, match := range referencePattern.FindAllStringSubmatch(content, -1) {
+		id, err := uuid.Parse(match[1])
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// appendQuery adds query to every reference in content for which queries
+// returns one
+func appendQuery(content string, queries map[uuid.UUID]string) string {
+	return urlPattern.ReplaceAllStringFunc(content, func(match string) string {
+		groups := urlPattern.FindStringSubmatch(match)
+		id, err := uuid.Parse(groups[1])
+		if err != nil {
+			return match
+		}
+		query, ok := queries[id]
+		if !ok {
+			return match
+		}
+		if groups[2] != "" {
+			return match + query + "&"
+		}
+		return match + "?" + query
+	})
+}
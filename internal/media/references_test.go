@@ -0,0 +1,62 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	first  = "3f1c2a52-8d4b-4f7e-9a55-1b2c3d4e5f60"
+	second = "9a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d"
+)
+
+func TestExtractReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"image", `<img src="/images/` + first + `.png">`, []string{first}},
+		{"media api", `[file](https://example.com/blog/media/` + first + `)`, []string{first}},
+		{"distinct", `images/` + first + `.jpg images/` + first + `.jpg media/` + second, []string{first, second}},
+		{"upper case", `IMAGES/` + first, []string{first}},
+		{"none", `https://example.com/other/` + first, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractReferences(tt.content)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ExtractReferences() = %v, want %v", got, tt.want)
+			}
+			for i, id := range got {
+				if id.String() != tt.want[i] {
+					t.Errorf("ExtractReferences()[%d] = %v, want %v", i, id, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	queries := map[uuid.UUID]string{uuid.MustParse(first): "expires=1&signature=abc"}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"image", `<img src="/images/` + first + `.png">`, `<img src="/images/` + first + `.png?expires=1&signature=abc">`},
+		{"media api", `(/blog/media/` + first + `)`, `(/blog/media/` + first + `?expires=1&signature=abc)`},
+		{"existing query", `/blog/media/` + first + `?download=1`, `/blog/media/` + first + `?expires=1&signature=abc&download=1`},
+		{"public media is left alone", `/images/` + second + `.png`, `/images/` + second + `.png`},
+		{"every occurrence", `images/` + first + `.png images/` + first + `.png`,
+			`images/` + first + `.png?expires=1&signature=abc images/` + first + `.png?expires=1&signature=abc`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendQuery(tt.content, queries); got != tt.want {
+				t.Errorf("appendQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package media
+
+import (
+	"database/sql"
+	"time"
+
+	"havamal-api/internal/db"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(media *Media, steps ...db.Step) error
+	GetAll() ([]Media, error)
+	GetById(id uuid.UUID) (*Media, error)
+	GetByFilename(filename string) (*Media, error)
+	SetPrivate(id uuid.UUID, private bool, steps ...db.Step) error
+	GetUsage(id uuid.UUID) ([]Usage, error)
+	GetOrphans(before time.Time) ([]Media, error)
+	SyncPostMedia(postId uuid.UUID, mediaIds []uuid.UUID) error
+	ReleasePost(postId uuid.UUID) error
+	Delete(id uuid.UUID, steps ...db.Step) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// translate returns the domain error of a failed media query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
+const mediaColumns = `m.id, m.kind, m.filename, COALESCE(m.original_name, ''), m.url, COALESCE(m.mime_type, ''), m.size,
+	m.width, m.height, m.page_count, m.duration_seconds, m.is_private, m.created_at, m.last_used_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOne(row scanner) (*Media, error) {
+	var media Media
+	var width, height, pageCount sql.NullInt32
+	var duration sql.NullFloat64
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&media.ID, &media.Kind, &media.Filename, &media.OriginalName, &media.URL, &media.MimeType, &media.Size,
+		&width, &height, &pageCount, &duration, &media.IsPrivate, &media.CreatedAt, &lastUsedAt); err != nil {
+		return nil, translate(err)
+	}
+	if width.Valid {
+		v := int(width.Int32)
+		media.Width = &v
+	}
+	if height.Valid {
+		v := int(height.Int32)
+		media.Height = &v
+	}
+	if pageCount.Valid {
+		v := int(pageCount.Int32)
+		media.PageCount = &v
+	}
+	if duration.Valid {
+		media.DurationSeconds = &duration.Float64
+	}
+	if lastUsedAt.Valid {
+		media.LastUsedAt = &lastUsedAt.Time
+	}
+	return &media, nil
+}
+
+// Create inserts a media record; steps run in the same transaction
+func (r *repository) Create(media *Media, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO media (id, kind, filename, original_name, url, mime_type, size, width, height, page_count, duration_seconds, is_private, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	if _, err := tx.Exec(query, media.ID, media.Kind, media.Filename, media.OriginalName, media.URL, media.MimeType, media.Size,
+		media.Width, media.Height, media.PageCount, media.DurationSeconds, media.IsPrivate, media.CreatedAt); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) GetAll() ([]Media, error) {
+	query := `SELECT ` + mediaColumns + `
+	FROM media m
+	ORDER BY m.created_at DESC`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMedia(rows)
+}
+
+func (r *repository) GetById(id uuid.UUID) (*Media, error) {
+	query := `SELECT ` + mediaColumns + `
+	FROM media m
+	WHERE m.id = $1`
+	return scanOne(r.db.QueryRow(query, id))
+}
+
+func (r *repository) GetByFilename(filename string) (*Media, error) {
+	query := `SELECT ` + mediaColumns + `
+	FROM media m
+	WHERE m.filename = $1`
+	return scanOne(r.db.QueryRow(query, filename))
+}
+
+func (r *repository) SetPrivate(id uuid.UUID, private bool, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE media SET is_private = $2 WHERE id = $1`
+	result, err := tx.Exec(query, id, private)
+	if err != nil {
+		return translate(err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) GetUsage(id uuid.UUID) ([]Usage, error) {
+	query := `SELECT p.id, p.title, p.slug, p.status
+	FROM post_media pm
+		INNER JOIN posts p ON pm.post_id = p.id
+	WHERE pm.media_id = $1
+	ORDER BY p.title`
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	usages := make([]Usage, 0)
+	for rows.Next() {
+		var usage Usage
+		if err := rows.Scan(&usage.PostId, &usage.Title, &usage.Slug, &usage.Status); err != nil {
+			return nil, err
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// GetOrphans returns media that no post references and that has not been
+// referenced (or uploaded) since before. Besides the post_media links, the
+// content of posts and of their saved versions is searched for the media id,
+// so restoring an old version never points at a deleted file.
+func (r *repository) GetOrphans(before time.Time) ([]Media, error) {
+	query := `SELECT ` + mediaColumns + `
+	FROM media m
+	WHERE NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = m.id)
+		AND NOT EXISTS (SELECT 1 FROM posts p WHERE strpos(lower(p.content), m.id::text) > 0)
+		AND NOT EXISTS (SELECT 1 FROM versions v WHERE strpos(lower(v.content), m.id::text) > 0)
+		AND COALESCE(m.last_used_at, m.created_at) < $1
+	ORDER BY m.created_at`
+	rows, err := r.db.Query(query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMedia(rows)
+}
+
+// SyncPostMedia replaces the media links of a post. Ids that are not known
+// media are ignored. Every media gaining or losing the post is touched so
+// the garbage collector counts from the last change, and its visibility is
+// recomputed: media used only by unpublished posts is private, media used by
+// any published post is public.
+func (r *repository) SyncPostMedia(postId uuid.UUID, mediaIds []uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	affected := make([]string, 0, len(mediaIds))
+	for _, id := range mediaIds {
+		affected = append(affected, id.String())
+	}
+	ids := pq.Array(affected)
+
+	rows, err := tx.Query(`DELETE FROM post_media WHERE post_id = $1 RETURNING media_id`, postId)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		affected = append(affected, id.String())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO post_media (post_id, media_id)
+	SELECT $1, id FROM media WHERE id::text = ANY($2)`
+	if _, err := tx.Exec(insert, postId, ids); err != nil {
+		return translate(err)
+	}
+
+	touch := `UPDATE media m SET last_used_at = NOW(),
+		is_private = CASE
+			WHEN NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = m.id) THEN m.is_private
+			ELSE NOT EXISTS (
+				SELECT 1 FROM post_media pm
+					INNER JOIN posts p ON pm.post_id = p.id
+				WHERE pm.media_id = m.id AND p.status = 'published')
+		END
+	WHERE m.id::text = ANY($1)`
+	if _, err := tx.Exec(touch, pq.Array(affected)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) ReleasePost(postId uuid.UUID) error {
+	return r.SyncPostMedia(postId, nil)
+}
+
+func (r *repository) Delete(id uuid.UUID, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM media WHERE id = $1`, id); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanMedia(rows *sql.Rows) ([]Media, error) {
+	items := make([]Media, 0)
+	for rows.Next() {
+		media, err := scanOne(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *media)
+	}
+	return items, nil
+}
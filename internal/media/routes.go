@@ -0,0 +1,71 @@
+package media
+
+import (
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/media/upload", handler.Upload)
+	router.GET("/media", handler.GetAll)
+	router.GET("/media/:id", handler.GetById)
+	router.DELETE("/media/:id", handler.Delete)
+	router.POST("/media/:id/sign", handler.Sign)
+	router.PUT("/media/:id/visibility", handler.SetVisibility)
+}
+
+func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/media/:id", handler.Serve)
+	router.HEAD("/media/:id", handler.Serve)
+}
+
+// RegisterFileRoutes serves the media directory by file name
+func RegisterFileRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/:filename", handler.ServeFile)
+	router.HEAD("/:filename", handler.ServeFile)
+}
+
+
+// signature lets anyone holding a signed URL read a private file
+var signature = []openapi.Param{
+	openapi.Query("expires", "Expiry of a signed URL, in Unix seconds"),
+	openapi.Query("signature", "Signature of a signed URL"),
+	openapi.Flag("download", "Serve as an attachment"),
+}
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/media/upload", Tag: "media", Summary: "Upload a file",
+			Body: openapi.Form("file", map[string]*openapi.Schema{"private": {Type: "boolean"}}), BodyMedia: "multipart/form-data", Response: Media{}},
+		{Method: "GET", Path: "/media", Tag: "media", Summary: "List media", Response: []Media{}},
+		{Method: "GET", Path: "/media/:id", Tag: "media", Summary: "Get a file's details and the posts using it", Response: Response{}},
+		{Method: "DELETE", Path: "/media/:id", Tag: "media", Summary: "Delete a file",
+			Description: "A file still used by posts is kept unless force is set; the answer then lists them in used_in.",
+			Query:       []openapi.Param{openapi.Flag("force", "Delete even when posts still use it")},
+			Response: struct {
+				Message string  `json:"message"`
+				Warning string  `json:"warning,omitempty"`
+				UsedIn  []Usage `json:"used_in,omitempty"`
+			}{}},
+		{Method: "POST", Path: "/media/:id/sign", Tag: "media", Summary: "Sign a URL to a private file", Body: SignRequest{}, OptionalBody: true, Response: SignedURL{}},
+		{Method: "PUT", Path: "/media/:id/visibility", Tag: "media", Summary: "Make a file private or public", Body: VisibilityRequest{}, Response: Media{}},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/media/:id", Tag: "media", Summary: "Download a file", Query: signature, Response: openapi.Binary, Media: "*/*"},
+		{Method: "HEAD", Path: "/media/:id", Tag: "media", Summary: "Check a file", Query: signature, Response: openapi.Binary, Media: "*/*"},
+	}
+}
+
+// FileOperations describes the routes of RegisterFileRoutes
+func FileOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/:filename", Tag: "media", Summary: "Download a file by name", Query: signature, Response: openapi.Binary, Media: "*/*"},
+		{Method: "HEAD", Path: "/:filename", Tag: "media", Summary: "Check a file by name", Query: signature, Response: openapi.Binary, Media: "*/*"},
+	}
+}
@@ -0,0 +1,358 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"havamal-api/internal/db"
+	"havamal-api/internal/events"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Upload(file multipart.File, header *multipart.FileHeader, kinds ...Kind) (*Media, error)
+	Register(media *Media) error
+	Open(id string) (*Media, *os.File, error)
+	OpenFile(filename string) (*Media, *os.File, error)
+	Authorize(media *Media, expires string, signature string) error
+	Sign(id string, expiresIn time.Duration) (*SignedURL, error)
+	SignContent(content string) (string, error)
+	SetVisibility(id string, private bool) (*Media, error)
+	GetAll() ([]Media, error)
+	GetById(id string) (*Response, error)
+	Delete(id string, force bool) ([]Usage, error)
+	SyncPost(postId uuid.UUID, content string) error
+	ReleasePost(postId uuid.UUID) error
+	CollectGarbage(olderThan time.Duration, dryRun bool) ([]Media, error)
+}
+
+type service struct {
+	repo      Repository
+	dir       string
+	signer    Signer
+	signedTTL time.Duration
+	publisher events.Publisher
+}
+
+// maxSignedTTL bounds how long a signed URL may stay valid
+const maxSignedTTL = 7 * 24 * time.Hour
+
+func NewService(repo Repository, dir string, signer Signer, signedTTL time.Duration, publisher events.Publisher) Service {
+	return &service{repo: repo, dir: dir, signer: signer, signedTTL: signedTTL, publisher: publisher}
+}
+
+// Upload validates a file against the rules of the allowed kinds (all kinds
+// when none are given), stores it, extracts its metadata and registers it.
+func (s *service) Upload(file multipart.File, header *multipart.FileHeader, kinds ...Kind) (*Media, error) {
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	kind, mimeType, ok := kindForExtension(ext)
+	if !ok || (len(kinds) > 0 && !containsKind(kinds, kind)) {
+		if len(kinds) == 0 {
+			kinds = []Kind{KindImage, KindDocument, KindAudio, KindVideo}
+		}
+		return nil, fmt.Errorf("%w. Allowed: %s", ErrUnsupportedType, strings.Join(AllowedExtensions(kinds...), ", "))
+	}
+
+	rule := kindRules[kind]
+	if header.Size > rule.MaxSize {
+		return nil, fmt.Errorf("%w: %s files are limited to %dMB", ErrFileTooLarge, kind, rule.MaxSize/megabyte)
+	}
+
+	// Check the content matches the extension
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if !sniffMatches(http.DetectContentType(sniff[:n]), mimeType) {
+		return nil, fmt.Errorf("%w: content does not match %s", ErrUnsupportedType, ext)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// The media id doubles as the file name
+	id := uuid.New()
+	filename := id.String() + ext
+	destPath := filepath.Join(s.dir, filename)
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(dest, file); err != nil {
+		dest.Close()
+		os.Remove(destPath)
+		return nil, err
+	}
+	if err := dest.Close(); err != nil {
+		os.Remove(destPath)
+		return nil, err
+	}
+
+	meta := ExtractMetadata(destPath, mimeType)
+	media := &Media{
+		ID:              id,
+		Kind:            kind,
+		Filename:        filename,
+		OriginalName:    header.Filename,
+		URL:             publicURL(kind, id, filename),
+		MimeType:        mimeType,
+		Size:            header.Size,
+		Width:           meta.Width,
+		Height:          meta.Height,
+		PageCount:       meta.PageCount,
+		DurationSeconds: meta.DurationSeconds,
+	}
+	if err := s.register(media, s.publisher.Publish(events.MediaUploaded, media)); err != nil {
+		os.Remove(destPath)
+		return nil, err
+	}
+	return media, nil
+}
+
+// publicURL keeps images on the static /images path and serves every other
+// kind through the media handler, which supports ranges and dispositions.
+func publicURL(kind Kind, id uuid.UUID, filename string) string {
+	if kind == KindImage {
+		return "./images/" + filename
+	}
+	return "./blog/media/" + id.String()
+}
+
+func containsKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) Register(media *Media) error {
+	return s.register(media)
+}
+
+// register stores the record of a media file; steps run in the same
+// transaction
+func (s *service) register(media *Media, steps ...db.Step) error {
+	if media.CreatedAt.IsZero() {
+		media.CreatedAt = time.Now()
+	}
+	return s.repo.Create(media, steps...)
+}
+
+func (s *service) GetAll() ([]Media, error) {
+	return s.repo.GetAll()
+}
+
+func (s *service) GetById(id string) (*Response, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	media, err := s.repo.GetById(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.repo.GetUsage(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Media: *media, UsedIn: usage}, nil
+}
+
+// Open returns the media record together with its file, which the caller
+// must close.
+func (s *service) Open(id string) (*Media, *os.File, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	media, err := s.repo.GetById(parsedId)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(filepath.Join(s.dir, filepath.Base(media.Filename)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return media, file, nil
+}
+
+// OpenFile opens a file of the media directory by name. Files uploaded before
+// media was tracked have no record and are returned with a nil media.
+func (s *service) OpenFile(filename string) (*Media, *os.File, error) {
+	filename = filepath.Base(filename)
+	media, err := s.repo.GetByFilename(filename)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, nil, err
+	}
+	file, err := os.Open(filepath.Join(s.dir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return media, file, nil
+}
+
+// Authorize allows public media, and private media only with a valid,
+// unexpired signature.
+func (s *service) Authorize(media *Media, expires string, signature string) error {
+	if media == nil || !media.IsPrivate {
+		return nil
+	}
+	return s.signer.Verify(media.ID, expires, signature)
+}
+
+// Sign mints a URL for the media valid for expiresIn, or the configured
+// default when zero.
+func (s *service) Sign(id string, expiresIn time.Duration) (*SignedURL, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	media, err := s.repo.GetById(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	if expiresIn <= 0 {
+		expiresIn = s.signedTTL
+	}
+	if expiresIn > maxSignedTTL {
+		expiresIn = maxSignedTTL
+	}
+	expiresAt := time.Now().Add(expiresIn).Truncate(time.Second)
+	return &SignedURL{
+		URL:       media.URL + "?" + s.signedQuery(media.ID, expiresAt),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// SignContent appends a signature valid for the configured default to every
+// URL of private media in content, so that drafts can be shown to someone
+// without an account.
+func (s *service) SignContent(content string) (string, error) {
+	expiresAt := time.Now().Add(s.signedTTL).Truncate(time.Second)
+	queries := make(map[uuid.UUID]string)
+	for _, id := range ExtractReferences(content) {
+		media, err := s.repo.GetById(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if media.IsPrivate {
+			queries[id] = s.signedQuery(id, expiresAt)
+		}
+	}
+	if len(queries) == 0 {
+		return content, nil
+	}
+	return appendQuery(content, queries), nil
+}
+
+func (s *service) signedQuery(id uuid.UUID, expiresAt time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", s.signer.Sign(id, expiresAt))
+	return query.Encode()
+}
+
+func (s *service) SetVisibility(id string, private bool) (*Media, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	media, err := s.repo.GetById(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	media.IsPrivate = private
+	if err := s.repo.SetPrivate(parsedId, private, s.publisher.Publish(events.MediaChanged, media)); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// Delete removes a media file and its record. Media still referenced by posts
+// is only removed when force is set; otherwise ErrMediaInUse is returned
+// together with the posts using it.
+func (s *service) Delete(id string, force bool) ([]Usage, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	media, err := s.repo.GetById(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.repo.GetUsage(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	if len(usage) > 0 && !force {
+		return usage, ErrMediaInUse
+	}
+	if err := s.remove(media); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (s *service) SyncPost(postId uuid.UUID, content string) error {
+	return s.repo.SyncPostMedia(postId, ExtractReferences(content))
+}
+
+func (s *service) ReleasePost(postId uuid.UUID) error {
+	return s.repo.ReleasePost(postId)
+}
+
+// CollectGarbage finds media unreferenced for longer than olderThan and, unless
+// dryRun is set, deletes both the files and their records.
+func (s *service) CollectGarbage(olderThan time.Duration, dryRun bool) ([]Media, error) {
+	orphans, err := s.repo.GetOrphans(time.Now().Add(-olderThan))
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return orphans, nil
+	}
+	removed := make([]Media, 0, len(orphans))
+	for _, media := range orphans {
+		if err := s.remove(&media); err != nil {
+			slog.Error("Failed to remove orphan media", slog.String("id", media.ID.String()), slog.Any("error", err))
+			continue
+		}
+		removed = append(removed, media)
+	}
+	return removed, nil
+}
+
+func (s *service) remove(media *Media) error {
+	path := filepath.Join(s.dir, filepath.Base(media.Filename))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.repo.Delete(media.ID, s.publisher.Publish(events.MediaDeleted, media))
+}
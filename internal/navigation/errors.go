@@ -0,0 +1,23 @@
+package navigation
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound            = apierror.New(apierror.NavigationNotFound)
+	ErrTranslationNotFound = apierror.New(apierror.TranslationNotFound)
+	ErrMenuNotFound        = apierror.New(apierror.MenuNotFound)
+	// ErrMenuExists means another menu already uses the slug
+	ErrMenuExists          = apierror.New(apierror.SlugConflict)
+	ErrNavigationCycle     = apierror.New(apierror.NavigationCycle)
+	ErrInvalidType         = apierror.New(apierror.NavigationInvalidType)
+	ErrInvalidLinkSource   = apierror.New(apierror.NavigationInvalidLinkSource)
+	ErrExternalLinkSource  = apierror.New(apierror.NavigationExternalLinkSource)
+	ErrMissingReference    = apierror.New(apierror.NavigationMissingReference)
+	ErrUnexpectedReference = apierror.New(apierror.NavigationUnexpectedReference)
+	ErrUnknownReference    = apierror.New(apierror.NavigationUnknownReference)
+	ErrInvalidLocation     = apierror.New(apierror.MenuInvalidLocation)
+	ErrInvalidMenuSlug     = apierror.New(apierror.MenuInvalidSlug)
+	ErrUnknownItem         = apierror.New(apierror.MenuUnknownItem)
+	ErrDuplicateItem       = apierror.New(apierror.MenuDuplicateItem)
+	ErrIncompleteItems     = apierror.New(apierror.MenuIncompleteItems)
+)
@@ -1,6 +1,15 @@
 package navigation
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/patch"
+
+	"github.com/gin-gonic/gin"
+)
 
 type Handler struct {
 	service Service
@@ -13,66 +22,234 @@ func NewHandler(service Service) Handler {
 func (h *Handler) Create(c *gin.Context) {
 	var request Request
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	navigation, err := h.service.Create(&request)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(200, navigation)
 }
 
 func (h *Handler) GetAll(c *gin.Context) {
-	navigations, err := h.service.GetAll()
+	relations := h.service.Relations()
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	navigations, err := h.service.GetAll(selection)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(200, navigations)
+	fields.JSON(c, selection, relations, navigations)
+}
+
+func (h *Handler) GetTree(c *gin.Context) {
+	tree, err := h.service.GetTree(i18n.FromRequest(c))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, tree)
 }
 
 func (h *Handler) GetById(c *gin.Context) {
 	id := c.Param("id")
 	navigation, err := h.service.GetById(id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(200, navigation)
+	etag.JSON(c, navigation.Revision, navigation)
 }
 
 func (h *Handler) GetBySlug(c *gin.Context) {
 	slug := c.Param("slug")
 	navigation, err := h.service.GetBySlug(slug)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(200, navigation)
+	etag.JSON(c, navigation.Revision, navigation)
 }
 
 func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
 	var request Request
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
-	navigation, err := h.service.Update(id, &request)
+	navigation, err := h.service.Update(id, &request, revision)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(200, navigation)
+	etag.Written(c, navigation.Revision, navigation)
+}
+
+// Patch applies a merge patch or JSON Patch to the navigation item and answers
+// with the updated item. Members the patch leaves out keep their current value.
+func (h *Handler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	current, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	request := current.request()
+	if err := patch.Apply(c, &request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	navigation, err := h.service.Update(id, &request, revision)
+	if err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Written(c, navigation.Revision, navigation)
 }
 
 func (h *Handler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.service.Delete(id); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(id, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(200, gin.H{"message": "Navigation deleted successfully"})
 }
+
+// conflict answers a stale write with the navigation item as it is now
+func (h *Handler) conflict(c *gin.Context, id string) {
+	navigation, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Conflict(c, navigation.Revision, navigation)
+}
+
+func (h *Handler) CreateMenu(c *gin.Context) {
+	var request MenuRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	menu, err := h.service.CreateMenu(&request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, menu)
+}
+
+func (h *Handler) GetMenus(c *gin.Context) {
+	menus, err := h.service.GetMenus()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, menus)
+}
+
+func (h *Handler) GetMenu(c *gin.Context) {
+	menu, err := h.service.GetMenu(c.Param("slug"), i18n.FromRequest(c))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, menu)
+}
+
+func (h *Handler) UpdateMenu(c *gin.Context) {
+	var request MenuRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	menu, err := h.service.UpdateMenu(c.Param("id"), &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, menu)
+}
+
+func (h *Handler) SetMenuItems(c *gin.Context) {
+	var request ItemsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.SetMenuItems(c.Param("id"), request.Items); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Menu items reordered successfully"})
+}
+
+func (h *Handler) DeleteMenu(c *gin.Context) {
+	if err := h.service.DeleteMenu(c.Param("id")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Menu deleted successfully"})
+}
+
+func (h *Handler) GetTranslations(c *gin.Context) {
+	translations, err := h.service.GetTranslations(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, translations)
+}
+
+func (h *Handler) SetTranslation(c *gin.Context) {
+	var request TranslationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	translation, err := h.service.SetTranslation(c.Param("id"), c.Param("locale"), &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, translation)
+}
+
+func (h *Handler) DeleteTranslation(c *gin.Context) {
+	if err := h.service.DeleteTranslation(c.Param("id"), c.Param("locale")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Translation deleted successfully"})
+}
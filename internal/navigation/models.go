@@ -1,9 +1,14 @@
 package navigation
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Type string
 type LinkSource string
+type Location string
 
 const (
 	TypeInternal Type = "internal"
@@ -12,17 +17,22 @@ const (
 	LinkSourceCustom   LinkSource = "custom"
 	LinkSourceCategory LinkSource = "category"
 	LinkSourcePost     LinkSource = "post"
+
+	LocationHeader  Location = "header"
+	LocationFooter  Location = "footer"
+	LocationSidebar Location = "sidebar"
 )
 
 type Request struct {
-	Label      string     `json:"label"`
-	Slug       string     `json:"slug"`
-	Type       Type       `json:"type"`
-	Order      int        `json:"order"`
-	ParentId   string     `json:"parent_id"`
-	LinkSource LinkSource `json:"link_source"`
-	CategoryId string     `json:"category_id"`
-	PostId     string     `json:"post_id"`
+	Label      string     `json:"label" binding:"required,max=100"`
+	Slug       string     `json:"slug" binding:"required_if=Type external,max=2048"`
+	Type       Type       `json:"type" binding:"omitempty,oneof=internal external"`
+	Order      int        `json:"order" binding:"min=0"`
+	ParentId   string     `json:"parent_id" binding:"omitempty,uuid"`
+	LinkSource LinkSource `json:"link_source" binding:"omitempty,oneof=custom category post"`
+	CategoryId string     `json:"category_id" binding:"required_if=LinkSource category,excluded_unless=LinkSource category,omitempty,uuid"`
+	PostId     string     `json:"post_id" binding:"required_if=LinkSource post,excluded_unless=LinkSource post,omitempty,uuid"`
+	MenuId     string     `json:"menu_id" binding:"omitempty,uuid"`
 }
 
 type Navigation struct {
@@ -35,4 +45,92 @@ type Navigation struct {
 	LinkSource LinkSource  `json:"link_source"`
 	CategoryId *uuid.UUID  `json:"category_id"`
 	PostId     *uuid.UUID  `json:"post_id"`
-}
\ No newline at end of file
+	MenuId     *uuid.UUID  `json:"menu_id"`
+	Revision   int         `json:"revision"`
+}
+
+// request returns the item as the body of a full update, for patches to
+// apply to
+func (n *Navigation) request() Request {
+	return Request{
+		Label:      n.Label,
+		Slug:       n.Slug,
+		Type:       n.Type,
+		Order:      n.Order,
+		ParentId:   optionalId(n.ParentId),
+		LinkSource: n.LinkSource,
+		CategoryId: optionalId(n.CategoryId),
+		PostId:     optionalId(n.PostId),
+		MenuId:     optionalId(n.MenuId),
+	}
+}
+
+func optionalId(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// change is what navigation events tell when only the translation of an item
+// changed
+type change struct {
+	ID     uuid.UUID `json:"id"`
+	Locale string    `json:"locale"`
+}
+
+// Translation is the label of a navigation item in a locale other than the
+// default one
+type Translation struct {
+	NavigationId uuid.UUID `json:"navigation_id"`
+	Locale       string    `json:"locale"`
+	Label        string    `json:"label"`
+}
+
+type TranslationRequest struct {
+	Label string `json:"label" binding:"required,max=100"`
+}
+
+// Link is a navigation item together with the slugs of the category or post it
+// points to
+type Link struct {
+	Navigation
+	CategorySlug string
+	PostSlug     string
+}
+
+type TreeNode struct {
+	Navigation
+	Href     string     `json:"href"`
+	Children []TreeNode `json:"children"`
+}
+
+type Menu struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Location  Location  `json:"location"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MenuRequest struct {
+	Name     string   `json:"name" binding:"required,max=100"`
+	Slug     string   `json:"slug" binding:"omitempty,slug,max=200"`
+	Location Location `json:"location" binding:"required,oneof=header footer sidebar"`
+}
+
+type MenuResponse struct {
+	Menu
+	Items []TreeNode `json:"items"`
+}
+
+// ItemOrder is one item of a drag-and-drop menu editor, with its children in
+// their new order
+type ItemOrder struct {
+	ID       string      `json:"id" binding:"required,uuid"`
+	Children []ItemOrder `json:"children" binding:"dive"`
+}
+
+type ItemsRequest struct {
+	Items []ItemOrder `json:"items" binding:"required,dive"`
+}
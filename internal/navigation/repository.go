@@ -2,17 +2,35 @@ package navigation
 
 import (
 	"database/sql"
+	"havamal-api/internal/db"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/fields"
 
 	"github.com/google/uuid"
 )
 
 type Repository interface {
-	Create(navigation *Navigation) error
-	GetAll() ([]Navigation, error)
+	Create(navigation *Navigation, steps ...db.Step) error
+	GetAll(selection fields.Selection) ([]Navigation, error)
 	GetById(id uuid.UUID) (*Navigation, error)
 	GetBySlug(slug string) (*Navigation, error)
-	Update(id uuid.UUID, navigation *Navigation) error
-	Delete(id uuid.UUID) error
+	GetVisibleLinks(menuId *uuid.UUID, locale string) ([]Link, error)
+	GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error)
+	ReferencesExist(navigation *Navigation) (bool, error)
+	Update(id uuid.UUID, navigation *Navigation, steps ...db.Step) error
+	Delete(id uuid.UUID, revision int, steps ...db.Step) error
+	GetTranslations(id uuid.UUID) ([]Translation, error)
+	SetTranslation(translation *Translation, steps ...db.Step) error
+	DeleteTranslation(id uuid.UUID, locale string, steps ...db.Step) error
+
+	CreateMenu(menu *Menu, steps ...db.Step) error
+	GetMenus() ([]Menu, error)
+	GetMenuById(id uuid.UUID) (*Menu, error)
+	GetMenuBySlug(slug string) (*Menu, error)
+	GetMenuItemIds(menuId uuid.UUID) ([]uuid.UUID, error)
+	SetMenuItems(menuId uuid.UUID, items []Navigation, steps ...db.Step) error
+	UpdateMenu(menu *Menu, steps ...db.Step) error
+	DeleteMenu(id uuid.UUID, steps ...db.Step) error
 }
 
 type repository struct {
@@ -23,28 +41,87 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) Create(navigation *Navigation) error {
-	query := `INSERT INTO navigation (id, label, slug, type, "order", parent_id, link_source, category_id, post_id)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	var parentID, categoryID, postID interface{}
-	if navigation.ParentId != nil {
-		parentID = *navigation.ParentId
+// translate returns the domain error of a failed navigation item query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
+// translateMenu returns the domain error of a failed menu query
+func translateMenu(err error) error {
+	return db.Translate(err, ErrMenuNotFound, ErrMenuExists)
+}
+
+const navigationColumns = `id, label, slug, type, "order", parent_id, link_source, category_id, post_id, menu_id, revision`
+
+// Fields are the members of a navigation item, in the order of
+// navigationColumns
+var Fields = fields.NewSet(
+	fields.Column{Name: "id", SQL: "id"},
+	fields.Column{Name: "label", SQL: "label", Empty: "''"},
+	fields.Column{Name: "slug", SQL: "slug", Empty: "''"},
+	fields.Column{Name: "type", SQL: "type"},
+	fields.Column{Name: "order", SQL: `"order"`},
+	fields.Column{Name: "parent_id", SQL: "parent_id"},
+	fields.Column{Name: "link_source", SQL: "link_source"},
+	fields.Column{Name: "category_id", SQL: "category_id"},
+	fields.Column{Name: "post_id", SQL: "post_id"},
+	fields.Column{Name: "menu_id", SQL: "menu_id"},
+	fields.Column{Name: "revision", SQL: "revision"},
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNavigation(row scanner, navigation *Navigation, extra ...any) error {
+	var parentID, categoryID, postID, menuID uuid.NullUUID
+	dest := []any{&navigation.ID, &navigation.Label, &navigation.Slug, &navigation.Type, &navigation.Order, &parentID, &navigation.LinkSource, &categoryID, &postID, &menuID, &navigation.Revision}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if parentID.Valid {
+		navigation.ParentId = &parentID.UUID
+	}
+	if categoryID.Valid {
+		navigation.CategoryId = &categoryID.UUID
+	}
+	if postID.Valid {
+		navigation.PostId = &postID.UUID
 	}
-	if navigation.CategoryId != nil {
-		categoryID = *navigation.CategoryId
+	if menuID.Valid {
+		navigation.MenuId = &menuID.UUID
 	}
-	if navigation.PostId != nil {
-		postID = *navigation.PostId
+	return nil
+}
+
+func nullableId(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
 	}
-	_, err := r.db.Exec(query, navigation.ID, navigation.Label, navigation.Slug, navigation.Type, navigation.Order, parentID, navigation.LinkSource, categoryID, postID)
+	return *id
+}
+
+// Create inserts an item; steps run in the same transaction
+func (r *repository) Create(navigation *Navigation, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `INSERT INTO navigation (id, label, slug, type, "order", parent_id, link_source, category_id, post_id, menu_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if _, err := tx.Exec(query, navigation.ID, navigation.Label, navigation.Slug, navigation.Type, navigation.Order, nullableId(navigation.ParentId), navigation.LinkSource, nullableId(navigation.CategoryId), nullableId(navigation.PostId), nullableId(navigation.MenuId)); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *repository) GetAll() ([]Navigation, error) {
-	query := `SELECT id, label, slug, type, "order", parent_id, link_source, category_id, post_id FROM navigation`
+func (r *repository) GetAll(selection fields.Selection) ([]Navigation, error) {
+	query := `SELECT ` + selection.SQL() + ` FROM navigation`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -54,88 +131,350 @@ func (r *repository) GetAll() ([]Navigation, error) {
 	var navigations []Navigation
 	for rows.Next() {
 		var navigation Navigation
-		var parentID, categoryID, postID uuid.NullUUID
-		if err := rows.Scan(&navigation.ID, &navigation.Label, &navigation.Slug, &navigation.Type, &navigation.Order, &parentID, &navigation.LinkSource, &categoryID, &postID); err != nil {
+		if err := scanNavigation(rows, &navigation); err != nil {
 			return nil, err
 		}
-		if parentID.Valid {
-			navigation.ParentId = &parentID.UUID
-		}
-		if categoryID.Valid {
-			navigation.CategoryId = &categoryID.UUID
-		}
-		if postID.Valid {
-			navigation.PostId = &postID.UUID
-		}
 		navigations = append(navigations, navigation)
 	}
 	return navigations, nil
 }
 
 func (r *repository) GetById(id uuid.UUID) (*Navigation, error) {
-	query := `SELECT id, label, slug, type, "order", parent_id, link_source, category_id, post_id FROM navigation WHERE id = $1`
-	row := r.db.QueryRow(query, id)
+	query := `SELECT ` + navigationColumns + ` FROM navigation WHERE id = $1`
 	var navigation Navigation
-	var parentID, categoryID, postID uuid.NullUUID
-	if err := row.Scan(&navigation.ID, &navigation.Label, &navigation.Slug, &navigation.Type, &navigation.Order, &parentID, &navigation.LinkSource, &categoryID, &postID); err != nil {
+	if err := scanNavigation(r.db.QueryRow(query, id), &navigation); err != nil {
+		return nil, translate(err)
+	}
+	return &navigation, nil
+}
+
+func (r *repository) GetBySlug(slug string) (*Navigation, error) {
+	query := `SELECT ` + navigationColumns + ` FROM navigation WHERE slug = $1`
+	var navigation Navigation
+	if err := scanNavigation(r.db.QueryRow(query, slug), &navigation); err != nil {
+		return nil, translate(err)
+	}
+	return &navigation, nil
+}
+
+// GetVisibleLinks returns the items of a menu, or of the global navigation when
+// menuId is nil, except those pointing at a missing category or at a post that
+// is not published. Labels are translated into locale where possible, and post
+// links lead to the post's published translation into locale if it has one.
+func (r *repository) GetVisibleLinks(menuId *uuid.UUID, locale string) ([]Link, error) {
+	query := `SELECT n.id, COALESCE(nt.label, n.label), n.slug, n.type, n."order", n.parent_id, n.link_source, n.category_id, n.post_id, n.menu_id, n.revision,
+		COALESCE(c.slug, ''), COALESCE(tp.slug, p.slug, '')
+	FROM navigation n
+		LEFT JOIN navigation_translations nt ON nt.navigation_id = n.id AND nt.locale = $2
+		LEFT JOIN categories c ON n.category_id = c.id
+		LEFT JOIN posts p ON n.post_id = p.id
+		LEFT JOIN posts tp ON tp.translation_group = p.translation_group AND tp.locale = $2 AND tp.status = 'published'
+	WHERE n.menu_id IS NOT DISTINCT FROM $1::uuid
+		AND (n.link_source <> 'category' OR c.id IS NOT NULL)
+		AND (n.link_source <> 'post' OR p.status = 'published')
+	ORDER BY n."order", n.label`
+	rows, err := r.db.Query(query, nullableId(menuId), locale)
+	if err != nil {
 		return nil, err
 	}
-	if parentID.Valid {
-		navigation.ParentId = &parentID.UUID
+	defer rows.Close()
+
+	links := make([]Link, 0)
+	for rows.Next() {
+		var link Link
+		if err := scanNavigation(rows, &link.Navigation, &link.CategorySlug, &link.PostSlug); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
 	}
-	if categoryID.Valid {
-		navigation.CategoryId = &categoryID.UUID
+	return links, nil
+}
+
+// GetAncestorIds returns id followed by the ids of all its ancestors
+func (r *repository) GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error) {
+	query := `WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM navigation WHERE id = $1
+		UNION
+		SELECT n.id, n.parent_id FROM navigation n
+			INNER JOIN ancestors a ON n.id = a.parent_id
+	)
+	SELECT id FROM ancestors`
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
 	}
-	if postID.Valid {
-		navigation.PostId = &postID.UUID
+	defer rows.Close()
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var ancestorId uuid.UUID
+		if err := rows.Scan(&ancestorId); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestorId)
 	}
-	return &navigation, nil
+	return ids, nil
 }
 
-func (r *repository) GetBySlug(slug string) (*Navigation, error) {
-	query := `SELECT id, label, slug, type, "order", parent_id, link_source, category_id, post_id FROM navigation WHERE slug = $1`
-	row := r.db.QueryRow(query, slug)
-	var navigation Navigation
-	var parentID, categoryID, postID uuid.NullUUID
-	if err := row.Scan(&navigation.ID, &navigation.Label, &navigation.Slug, &navigation.Type, &navigation.Order, &parentID, &navigation.LinkSource, &categoryID, &postID); err != nil {
+// ReferencesExist reports whether the parent, menu, category and post of an
+// item exist, and whether the parent belongs to the same menu
+func (r *repository) ReferencesExist(navigation *Navigation) (bool, error) {
+	query := `SELECT
+		($1::uuid IS NULL OR EXISTS (SELECT 1 FROM navigation WHERE id = $1 AND menu_id IS NOT DISTINCT FROM $2::uuid))
+		AND ($2::uuid IS NULL OR EXISTS (SELECT 1 FROM menus WHERE id = $2))
+		AND ($3::uuid IS NULL OR EXISTS (SELECT 1 FROM categories WHERE id = $3))
+		AND ($4::uuid IS NULL OR EXISTS (SELECT 1 FROM posts WHERE id = $4))`
+	var exists bool
+	err := r.db.QueryRow(query, nullableId(navigation.ParentId), nullableId(navigation.MenuId), nullableId(navigation.CategoryId), nullableId(navigation.PostId)).Scan(&exists)
+	return exists, err
+}
+
+// Update only applies when navigation.Revision is still the current revision
+// or etag.Any, and sets it to the revision written before steps run
+func (r *repository) Update(id uuid.UUID, navigation *Navigation, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE navigation SET label = $2, slug = $3, type = $4, "order" = $5, parent_id = $6, link_source = $7, category_id = $8, post_id = $9, menu_id = $10,
+		revision = revision + 1
+	WHERE id = $1 AND ($11 = 0 OR revision = $11)`
+	result, err := tx.Exec(query, id, navigation.Label, navigation.Slug, navigation.Type, navigation.Order, nullableId(navigation.ParentId), navigation.LinkSource, nullableId(navigation.CategoryId), nullableId(navigation.PostId), nullableId(navigation.MenuId), navigation.Revision)
+	if err != nil {
+		return translate(err)
+	}
+	if err := etag.Check(result, tx, "navigation", id); err != nil {
+		return translate(err)
+	}
+	if err := tx.QueryRow(`SELECT revision FROM navigation WHERE id = $1`, id).Scan(&navigation.Revision); err != nil {
+		return err
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) Delete(id uuid.UUID, revision int, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM navigation WHERE id = $1 AND ($2 = 0 OR revision = $2)`
+	result, err := tx.Exec(query, id, revision)
+	if err != nil {
+		return translate(err)
+	}
+	if err := etag.Check(result, tx, "navigation", id); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) GetTranslations(id uuid.UUID) ([]Translation, error) {
+	query := `SELECT navigation_id, locale, label FROM navigation_translations WHERE navigation_id = $1 ORDER BY locale`
+	rows, err := r.db.Query(query, id)
+	if err != nil {
 		return nil, err
 	}
-	if parentID.Valid {
-		navigation.ParentId = &parentID.UUID
+	defer rows.Close()
+	translations := make([]Translation, 0)
+	for rows.Next() {
+		var translation Translation
+		if err := rows.Scan(&translation.NavigationId, &translation.Locale, &translation.Label); err != nil {
+			return nil, err
+		}
+		translations = append(translations, translation)
 	}
-	if categoryID.Valid {
-		navigation.CategoryId = &categoryID.UUID
+	return translations, nil
+}
+
+// SetTranslation creates or replaces the label of an item in a locale and
+// bumps the item's revision
+func (r *repository) SetTranslation(translation *Translation, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
-	if postID.Valid {
-		navigation.PostId = &postID.UUID
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE navigation SET revision = revision + 1 WHERE id = $1`, translation.NavigationId)
+	if err != nil {
+		return err
 	}
-	return &navigation, nil
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	query := `INSERT INTO navigation_translations (navigation_id, locale, label) VALUES ($1, $2, $3)
+	ON CONFLICT (navigation_id, locale) DO UPDATE SET label = EXCLUDED.label`
+	if _, err := tx.Exec(query, translation.NavigationId, translation.Locale, translation.Label); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *repository) Update(id uuid.UUID, navigation *Navigation) error {
-	query := `UPDATE navigation SET label = $2, slug = $3, type = $4, "order" = $5, parent_id = $6, link_source = $7, category_id = $8, post_id = $9 WHERE id = $1`
-	var parentID, categoryID, postID interface{}
-	if navigation.ParentId != nil {
-		parentID = *navigation.ParentId
+func (r *repository) DeleteTranslation(id uuid.UUID, locale string, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
-	if navigation.CategoryId != nil {
-		categoryID = *navigation.CategoryId
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM navigation_translations WHERE navigation_id = $1 AND locale = $2`, id, locale)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrTranslationNotFound
+	}
+	if _, err := tx.Exec(`UPDATE navigation SET revision = revision + 1 WHERE id = $1`, id); err != nil {
+		return err
 	}
-	if navigation.PostId != nil {
-		postID = *navigation.PostId
+	if err := db.Run(tx, steps...); err != nil {
+		return err
 	}
-	_, err := r.db.Exec(query, id, navigation.Label, navigation.Slug, navigation.Type, navigation.Order, parentID, navigation.LinkSource, categoryID, postID)
+	return tx.Commit()
+}
+
+func (r *repository) CreateMenu(menu *Menu, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `INSERT INTO menus (id, name, slug, location, created_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(query, menu.ID, menu.Name, menu.Slug, menu.Location, menu.CreatedAt); err != nil {
+		return translateMenu(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *repository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM navigation WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+func (r *repository) GetMenus() ([]Menu, error) {
+	query := `SELECT id, name, slug, location, created_at FROM menus ORDER BY location, name`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	menus := make([]Menu, 0)
+	for rows.Next() {
+		var menu Menu
+		if err := rows.Scan(&menu.ID, &menu.Name, &menu.Slug, &menu.Location, &menu.CreatedAt); err != nil {
+			return nil, err
+		}
+		menus = append(menus, menu)
+	}
+	return menus, nil
+}
+
+func (r *repository) GetMenuById(id uuid.UUID) (*Menu, error) {
+	query := `SELECT id, name, slug, location, created_at FROM menus WHERE id = $1`
+	var menu Menu
+	if err := r.db.QueryRow(query, id).Scan(&menu.ID, &menu.Name, &menu.Slug, &menu.Location, &menu.CreatedAt); err != nil {
+		return nil, translateMenu(err)
+	}
+	return &menu, nil
+}
+
+func (r *repository) GetMenuBySlug(slug string) (*Menu, error) {
+	query := `SELECT id, name, slug, location, created_at FROM menus WHERE slug = $1`
+	var menu Menu
+	if err := r.db.QueryRow(query, slug).Scan(&menu.ID, &menu.Name, &menu.Slug, &menu.Location, &menu.CreatedAt); err != nil {
+		return nil, translateMenu(err)
+	}
+	return &menu, nil
+}
+
+func (r *repository) GetMenuItemIds(menuId uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT id FROM navigation WHERE menu_id = $1`
+	rows, err := r.db.Query(query, menuId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SetMenuItems rewrites parent_id and "order" of the menu items in one
+// transaction
+func (r *repository) SetMenuItems(menuId uuid.UUID, items []Navigation, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `UPDATE navigation SET parent_id = $2, "order" = $3, revision = revision + 1 WHERE id = $1 AND menu_id = $4`
+	for _, item := range items {
+		if _, err := tx.Exec(query, item.ID, nullableId(item.ParentId), item.Order, menuId); err != nil {
+			return translate(err)
+		}
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) UpdateMenu(menu *Menu, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE menus SET name = $2, slug = $3, location = $4 WHERE id = $1`
+	result, err := tx.Exec(query, menu.ID, menu.Name, menu.Slug, menu.Location)
+	if err != nil {
+		return translateMenu(err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrMenuNotFound
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) DeleteMenu(id uuid.UUID, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM menus WHERE id = $1`
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return translateMenu(err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrMenuNotFound
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -1,15 +1,66 @@
 package navigation
 
-import "github.com/gin-gonic/gin"
+import (
+	"havamal-api/internal/fields"
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/navigation", handler.Create)			
 	router.PUT("/navigation/:id", handler.Update)
+	router.PATCH("/navigation/:id", handler.Patch)
 	router.DELETE("/navigation/:id", handler.Delete)
+	router.GET("/navigation/:id/translations", handler.GetTranslations)
+	router.PUT("/navigation/:id/translations/:locale", handler.SetTranslation)
+	router.DELETE("/navigation/:id/translations/:locale", handler.DeleteTranslation)
+
+	router.POST("/menus", handler.CreateMenu)
+	router.PUT("/menus/:id", handler.UpdateMenu)
+	router.PUT("/menus/:id/items", handler.SetMenuItems)
+	router.DELETE("/menus/:id", handler.DeleteMenu)
 }
 
 func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.GET("/navigation", handler.GetAll)
+	router.GET("/navigation/tree", handler.GetTree)
 	router.GET("/navigation/:id", handler.GetById)
 	router.GET("/navigation/slug/:slug", handler.GetBySlug)
+
+	router.GET("/menus", handler.GetMenus)
+	router.GET("/menus/:slug", handler.GetMenu)
+}
+
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/navigation", Tag: "navigation", Summary: "Create a navigation item", Body: Request{}, Response: Navigation{}},
+		{Method: "PUT", Path: "/navigation/:id", Tag: "navigation", Summary: "Replace a navigation item", Body: Request{}, Response: Navigation{}, IfMatch: true},
+		{Method: "PATCH", Path: "/navigation/:id", Tag: "navigation", Summary: "Patch a navigation item", Body: Request{}, Response: Navigation{}, IfMatch: true},
+		{Method: "DELETE", Path: "/navigation/:id", Tag: "navigation", Summary: "Delete a navigation item", IfMatch: true},
+		{Method: "GET", Path: "/navigation/:id/translations", Tag: "navigation", Summary: "List a navigation item's translations", Response: []Translation{}},
+		{Method: "PUT", Path: "/navigation/:id/translations/:locale", Tag: "navigation", Summary: "Set a navigation item's translation", Body: TranslationRequest{}, Response: Translation{}},
+		{Method: "DELETE", Path: "/navigation/:id/translations/:locale", Tag: "navigation", Summary: "Delete a navigation item's translation"},
+
+		{Method: "POST", Path: "/menus", Tag: "menus", Summary: "Create a menu", Body: MenuRequest{}, Response: Menu{}},
+		{Method: "PUT", Path: "/menus/:id", Tag: "menus", Summary: "Update a menu", Body: MenuRequest{}, Response: Menu{}},
+		{Method: "PUT", Path: "/menus/:id/items", Tag: "menus", Summary: "Set the items of a menu and their order", Body: ItemsRequest{}},
+		{Method: "DELETE", Path: "/menus/:id", Tag: "menus", Summary: "Delete a menu"},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/navigation", Tag: "navigation", Summary: "List navigation items",
+			Query: fields.Params(Fields, "parent", "children", "menu"), Response: []Navigation{}},
+		{Method: "GET", Path: "/navigation/tree", Tag: "navigation", Summary: "Get the navigation tree with resolved links", Query: []openapi.Param{openapi.Lang}, Response: []TreeNode{}},
+		{Method: "GET", Path: "/navigation/:id", Tag: "navigation", Summary: "Get a navigation item", Response: Navigation{}, Cached: true},
+		{Method: "GET", Path: "/navigation/slug/:slug", Tag: "navigation", Summary: "Get a navigation item by slug", Response: Navigation{}, Cached: true},
+
+		{Method: "GET", Path: "/menus", Tag: "menus", Summary: "List menus", Response: []Menu{}},
+		{Method: "GET", Path: "/menus/:slug", Tag: "menus", Summary: "Get a menu with its item tree", Query: []openapi.Param{openapi.Lang}, Response: MenuResponse{}},
+	}
 }
@@ -1,54 +1,86 @@
 package navigation
 
-import "github.com/google/uuid"
+import (
+	"havamal-api/internal/events"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/slugs"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CategoryPath = "/category/"
+	PostPath     = "/post/"
+)
 
 type Service interface {
 	Create(navigation *Request) (*Navigation, error)
-	GetAll() ([]Navigation, error)
+	GetAll(selection fields.Selection) ([]Navigation, error)
+	// Relations are what lists of navigation items can embed with ?include=
+	Relations() fields.Relations
 	GetById(id string) (*Navigation, error)
 	GetBySlug(slug string) (*Navigation, error)
-	Update(id string, navigation *Request) (*Navigation, error)
-	Delete(id string) error
+	GetTree(locale string) ([]TreeNode, error)
+	Update(id string, navigation *Request, revision int) (*Navigation, error)
+	Delete(id string, revision int) error
+	GetTranslations(id string) ([]Translation, error)
+	SetTranslation(id string, locale string, request *TranslationRequest) (*Translation, error)
+	DeleteTranslation(id string, locale string) error
+
+	CreateMenu(request *MenuRequest) (*Menu, error)
+	GetMenus() ([]Menu, error)
+	GetMenu(slug string, locale string) (*MenuResponse, error)
+	UpdateMenu(id string, request *MenuRequest) (*Menu, error)
+	SetMenuItems(id string, items []ItemOrder) error
+	DeleteMenu(id string) error
 }
 
 type service struct {
 	repository Repository
+	publisher  events.Publisher
 }
 
-func NewService(repository Repository) Service {
-	return &service{repository: repository}
+func NewService(repository Repository, publisher events.Publisher) Service {
+	return &service{repository: repository, publisher: publisher}
 }
 
 func (s *service) Create(request *Request) (*Navigation, error) {
-	var parentID *uuid.UUID
-	if request.ParentId != "" {
-		parsed, err := uuid.Parse(request.ParentId)
-		if err != nil {
-			return nil, err
-		}
-		parentID = &parsed
+	navigation, err := s.fromRequest(uuid.New(), request)
+	if err != nil {
+		return nil, err
 	}
-
-	var categoryID *uuid.UUID
-	if request.CategoryId != "" {
-		parsed, err := uuid.Parse(request.CategoryId)
-		if err != nil {
-			return nil, err
-		}
-		categoryID = &parsed
+	navigation.Revision = 1
+	if err := s.repository.Create(navigation, s.publisher.Publish(events.NavigationChanged, navigation)); err != nil {
+		return nil, err
 	}
+	return navigation, nil
+}
 
-	var postID *uuid.UUID
-	if request.PostId != "" {
-		parsed, err := uuid.Parse(request.PostId)
-		if err != nil {
-			return nil, err
-		}
-		postID = &parsed
+// fromRequest parses the ids of a request and checks that type, link_source
+// and references agree before the database constraints get to see them
+func (s *service) fromRequest(id uuid.UUID, request *Request) (*Navigation, error) {
+	parentID, err := parseOptionalId(request.ParentId)
+	if err != nil {
+		return nil, err
+	}
+	categoryID, err := parseOptionalId(request.CategoryId)
+	if err != nil {
+		return nil, err
+	}
+	postID, err := parseOptionalId(request.PostId)
+	if err != nil {
+		return nil, err
+	}
+	menuID, err := parseOptionalId(request.MenuId)
+	if err != nil {
+		return nil, err
 	}
 
 	navigation := Navigation{
-		ID:         uuid.New(),
+		ID:         id,
 		Label:      request.Label,
 		Slug:       request.Slug,
 		Type:       request.Type,
@@ -57,15 +89,122 @@ func (s *service) Create(request *Request) (*Navigation, error) {
 		LinkSource: request.LinkSource,
 		CategoryId: categoryID,
 		PostId:     postID,
+		MenuId:     menuID,
+	}
+	if navigation.Type == "" {
+		navigation.Type = TypeInternal
+	}
+	if navigation.LinkSource == "" {
+		navigation.LinkSource = LinkSourceCustom
+	}
+	if err := validate(&navigation); err != nil {
+		return nil, err
+	}
+	if err := s.checkParent(id, parentID); err != nil {
+		return nil, err
 	}
-	if err := s.repository.Create(&navigation); err != nil {
+	exists, err := s.repository.ReferencesExist(&navigation)
+	if err != nil {
 		return nil, err
 	}
+	if !exists {
+		return nil, ErrUnknownReference
+	}
 	return &navigation, nil
 }
 
-func (s *service) GetAll() ([]Navigation, error) {
-	return s.repository.GetAll()
+// validate mirrors the navigation_single_reference constraint
+func validate(navigation *Navigation) error {
+	if navigation.Type != TypeInternal && navigation.Type != TypeExternal {
+		return ErrInvalidType
+	}
+	switch navigation.LinkSource {
+	case LinkSourceCustom:
+		if navigation.CategoryId != nil || navigation.PostId != nil {
+			return ErrUnexpectedReference
+		}
+	case LinkSourceCategory:
+		if navigation.CategoryId == nil {
+			return ErrMissingReference
+		}
+		if navigation.PostId != nil {
+			return ErrUnexpectedReference
+		}
+	case LinkSourcePost:
+		if navigation.PostId == nil {
+			return ErrMissingReference
+		}
+		if navigation.CategoryId != nil {
+			return ErrUnexpectedReference
+		}
+	default:
+		return ErrInvalidLinkSource
+	}
+	if navigation.Type == TypeExternal && navigation.LinkSource != LinkSourceCustom {
+		return ErrExternalLinkSource
+	}
+	return nil
+}
+
+func parseOptionalId(id string) (*uuid.UUID, error) {
+	if id == "" {
+		return nil, nil
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func (s *service) GetAll(selection fields.Selection) ([]Navigation, error) {
+	return s.repository.GetAll(selection)
+}
+
+// Relations embeds the parent, the children and the menu of items, read from
+// the whole navigation and the list of menus
+func (s *service) Relations() fields.Relations {
+	return fields.Relations{
+		"parent": {Key: "parent_id", Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			all, err := s.repository.GetAll(Fields.All())
+			if err != nil {
+				return nil, err
+			}
+			parents := make(map[uuid.UUID]any, len(ids))
+			for _, navigation := range all {
+				parents[navigation.ID] = navigation
+			}
+			return parents, nil
+		}},
+		"children": {Key: "id", Many: true, Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			all, err := s.repository.GetAll(Fields.All())
+			if err != nil {
+				return nil, err
+			}
+			children := make(map[uuid.UUID][]Navigation)
+			for _, navigation := range all {
+				if navigation.ParentId != nil {
+					children[*navigation.ParentId] = append(children[*navigation.ParentId], navigation)
+				}
+			}
+			loaded := make(map[uuid.UUID]any, len(children))
+			for id, list := range children {
+				loaded[id] = list
+			}
+			return loaded, nil
+		}},
+		"menu": {Key: "menu_id", Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			menus, err := s.repository.GetMenus()
+			if err != nil {
+				return nil, err
+			}
+			loaded := make(map[uuid.UUID]any, len(menus))
+			for _, menu := range menus {
+				loaded[menu.ID] = menu
+			}
+			return loaded, nil
+		}},
+	}
 }
 
 func (s *service) GetById(id string) (*Navigation, error) {
@@ -80,59 +219,281 @@ func (s *service) GetBySlug(slug string) (*Navigation, error) {
 	return s.repository.GetBySlug(slug)
 }
 
-func (s *service) Update(id string, request *Request) (*Navigation, error) {
-	parsedId, err := uuid.Parse(id)
+// GetTree nests the visible items of the global navigation under their
+// parents, sorted by order. Children of hidden items are hidden with them.
+func (s *service) GetTree(locale string) ([]TreeNode, error) {
+	return s.getTree(nil, locale)
+}
+
+func (s *service) getTree(menuId *uuid.UUID, locale string) ([]TreeNode, error) {
+	links, err := s.repository.GetVisibleLinks(menuId, locale)
 	if err != nil {
 		return nil, err
 	}
-	var parentID *uuid.UUID
-	if request.ParentId != "" {
-		parsed, err := uuid.Parse(request.ParentId)
-		if err != nil {
-			return nil, err
+	children := make(map[uuid.UUID][]Link)
+	visible := make(map[uuid.UUID]bool, len(links))
+	for _, link := range links {
+		visible[link.ID] = true
+	}
+	roots := make([]Link, 0)
+	for _, link := range links {
+		if link.ParentId == nil {
+			roots = append(roots, link)
+		} else if visible[*link.ParentId] {
+			children[*link.ParentId] = append(children[*link.ParentId], link)
 		}
-		parentID = &parsed
 	}
+	return buildTree(roots, children), nil
+}
 
-	var categoryID *uuid.UUID
-	if request.CategoryId != "" {
-		parsed, err := uuid.Parse(request.CategoryId)
-		if err != nil {
-			return nil, err
-		}
-		categoryID = &parsed
+func buildTree(links []Link, children map[uuid.UUID][]Link) []TreeNode {
+	nodes := make([]TreeNode, 0, len(links))
+	for _, link := range links {
+		nodes = append(nodes, TreeNode{
+			Navigation: link.Navigation,
+			Href:       href(link),
+			Children:   buildTree(children[link.ID], children),
+		})
 	}
+	return nodes
+}
 
-	var postID *uuid.UUID
-	if request.PostId != "" {
-		parsed, err := uuid.Parse(request.PostId)
-		if err != nil {
-			return nil, err
+// href resolves the URL a navigation item points to
+func href(link Link) string {
+	switch link.LinkSource {
+	case LinkSourceCategory:
+		return CategoryPath + link.CategorySlug
+	case LinkSourcePost:
+		return PostPath + link.PostSlug
+	}
+	if link.Type == TypeExternal || strings.HasPrefix(link.Slug, "/") {
+		return link.Slug
+	}
+	return "/" + link.Slug
+}
+
+// checkParent rejects moving a navigation item under itself or one of its
+// descendants
+func (s *service) checkParent(id uuid.UUID, parentId *uuid.UUID) error {
+	if parentId == nil {
+		return nil
+	}
+	ancestors, err := s.repository.GetAncestorIds(*parentId)
+	if err != nil {
+		return err
+	}
+	for _, ancestorId := range ancestors {
+		if ancestorId == id {
+			return ErrNavigationCycle
 		}
-		postID = &parsed
 	}
+	return nil
+}
 
-	navigation := Navigation{
-		ID:         parsedId,
-		Label:      request.Label,
-		Slug:       request.Slug,
-		Type:       request.Type,
-		Order:      request.Order,
-		ParentId:   parentID,
-		LinkSource: request.LinkSource,
-		CategoryId: categoryID,
-		PostId:     postID,
+func (s *service) Update(id string, request *Request, revision int) (*Navigation, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.repository.Update(parsedId, &navigation); err != nil {
+	navigation, err := s.fromRequest(parsedId, request)
+	if err != nil {
 		return nil, err
 	}
-	return &navigation, nil
+	navigation.Revision = revision
+	// The event is recorded with the revision written
+	if err := s.repository.Update(parsedId, navigation, s.publisher.Publish(events.NavigationChanged, navigation)); err != nil {
+		return nil, err
+	}
+	return navigation, nil
+}
+
+func (s *service) Delete(id string, revision int) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	existing, err := s.repository.GetById(parsedId)
+	if err != nil {
+		return err
+	}
+	return s.repository.Delete(parsedId, revision, s.publisher.Publish(events.NavigationDeleted, existing))
+}
+
+func (s *service) GetTranslations(id string) ([]Translation, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repository.GetById(parsedId); err != nil {
+		return nil, err
+	}
+	return s.repository.GetTranslations(parsedId)
+}
+
+// SetTranslation stores the label of an item in locale. The default locale is
+// the item's own label.
+func (s *service) SetTranslation(id string, locale string, request *TranslationRequest) (*Translation, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTranslationLocale(locale); err != nil {
+		return nil, err
+	}
+	translation := &Translation{NavigationId: parsedId, Locale: locale, Label: request.Label}
+	if err := s.repository.SetTranslation(translation, s.publisher.Publish(events.NavigationChanged, change{ID: parsedId, Locale: locale})); err != nil {
+		return nil, err
+	}
+	return translation, nil
+}
+
+func (s *service) DeleteTranslation(id string, locale string) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	if err := checkTranslationLocale(locale); err != nil {
+		return err
+	}
+	return s.repository.DeleteTranslation(parsedId, locale, s.publisher.Publish(events.NavigationChanged, change{ID: parsedId, Locale: locale}))
+}
+
+func checkTranslationLocale(locale string) error {
+	if !i18n.IsSupported(locale) {
+		return i18n.ErrUnsupportedLocale
+	}
+	if locale == i18n.Default {
+		return i18n.ErrDefaultLocale
+	}
+	return nil
+}
+
+func (s *service) CreateMenu(request *MenuRequest) (*Menu, error) {
+	menu := &Menu{ID: uuid.New(), CreatedAt: time.Now()}
+	if err := applyMenuRequest(menu, request); err != nil {
+		return nil, err
+	}
+	if err := s.repository.CreateMenu(menu, s.publisher.Publish(events.MenuChanged, menu)); err != nil {
+		return nil, err
+	}
+	return menu, nil
+}
+
+func (s *service) GetMenus() ([]Menu, error) {
+	return s.repository.GetMenus()
+}
+
+func (s *service) GetMenu(slug string, locale string) (*MenuResponse, error) {
+	menu, err := s.repository.GetMenuBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.getTree(&menu.ID, locale)
+	if err != nil {
+		return nil, err
+	}
+	return &MenuResponse{Menu: *menu, Items: items}, nil
+}
+
+func (s *service) UpdateMenu(id string, request *MenuRequest) (*Menu, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	menu, err := s.repository.GetMenuById(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMenuRequest(menu, request); err != nil {
+		return nil, err
+	}
+	if err := s.repository.UpdateMenu(menu, s.publisher.Publish(events.MenuChanged, menu)); err != nil {
+		return nil, err
+	}
+	return menu, nil
+}
+
+func applyMenuRequest(menu *Menu, request *MenuRequest) error {
+	switch request.Location {
+	case LocationHeader, LocationFooter, LocationSidebar:
+	default:
+		return ErrInvalidLocation
+	}
+	slug := request.Slug
+	if slug == "" {
+		slug = request.Name
+	}
+	menu.Name = strings.TrimSpace(request.Name)
+	menu.Slug = slugs.Make(slug)
+	menu.Location = request.Location
+	if menu.Slug == "" {
+		return ErrInvalidMenuSlug
+	}
+	return nil
 }
 
-func (s *service) Delete(id string) error {
+// SetMenuItems takes the complete nested ordering of a menu, as sent by a
+// drag-and-drop editor, and rewrites every item's parent and order from it
+func (s *service) SetMenuItems(id string, items []ItemOrder) error {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return err
 	}
-	return s.repository.Delete(parsedId)
+	menu, err := s.repository.GetMenuById(parsedId)
+	if err != nil {
+		return err
+	}
+	existing, err := s.repository.GetMenuItemIds(parsedId)
+	if err != nil {
+		return err
+	}
+	known := make(map[uuid.UUID]bool, len(existing))
+	for _, itemId := range existing {
+		known[itemId] = true
+	}
+
+	positions := make([]Navigation, 0, len(existing))
+	if err := flattenItems(items, nil, known, &positions); err != nil {
+		return err
+	}
+	if len(positions) != len(existing) {
+		return ErrIncompleteItems
+	}
+	return s.repository.SetMenuItems(parsedId, positions, s.publisher.Publish(events.MenuChanged, menu))
+}
+
+// flattenItems walks the nested ordering, recording each item's parent and
+// position. Listed items are removed from known, so duplicates are caught.
+func flattenItems(items []ItemOrder, parentId *uuid.UUID, known map[uuid.UUID]bool, positions *[]Navigation) error {
+	for i, item := range items {
+		itemId, err := uuid.Parse(item.ID)
+		if err != nil {
+			return err
+		}
+		listed, ok := known[itemId]
+		if !ok {
+			return ErrUnknownItem
+		}
+		if !listed {
+			return ErrDuplicateItem
+		}
+		known[itemId] = false
+		*positions = append(*positions, Navigation{ID: itemId, ParentId: parentId, Order: i})
+		if err := flattenItems(item.Children, &itemId, known, positions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) DeleteMenu(id string) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	menu, err := s.repository.GetMenuById(parsedId)
+	if err != nil {
+		return err
+	}
+	return s.repository.DeleteMenu(parsedId, s.publisher.Publish(events.MenuDeleted, menu))
 }
@@ -0,0 +1,147 @@
+package navigation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidate(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name       string
+		navigation Navigation
+		wantErr    error
+	}{
+		{"custom", Navigation{Type: TypeInternal, LinkSource: LinkSourceCustom}, nil},
+		{"external", Navigation{Type: TypeExternal, LinkSource: LinkSourceCustom}, nil},
+		{"category", Navigation{Type: TypeInternal, LinkSource: LinkSourceCategory, CategoryId: &id}, nil},
+		{"post", Navigation{Type: TypeInternal, LinkSource: LinkSourcePost, PostId: &id}, nil},
+		{"unknown type", Navigation{Type: "button", LinkSource: LinkSourceCustom}, ErrInvalidType},
+		{"unknown link source", Navigation{Type: TypeInternal, LinkSource: "tag"}, ErrInvalidLinkSource},
+		{"custom with a reference", Navigation{Type: TypeInternal, LinkSource: LinkSourceCustom, PostId: &id}, ErrUnexpectedReference},
+		{"category without one", Navigation{Type: TypeInternal, LinkSource: LinkSourceCategory}, ErrMissingReference},
+		{"category with a post", Navigation{Type: TypeInternal, LinkSource: LinkSourceCategory, CategoryId: &id, PostId: &id}, ErrUnexpectedReference},
+		{"post without one", Navigation{Type: TypeInternal, LinkSource: LinkSourcePost}, ErrMissingReference},
+		{"post with a category", Navigation{Type: TypeInternal, LinkSource: LinkSourcePost, PostId: &id, CategoryId: &id}, ErrUnexpectedReference},
+		{"external to a post", Navigation{Type: TypeExternal, LinkSource: LinkSourcePost, PostId: &id}, ErrExternalLinkSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(&tt.navigation); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHref(t *testing.T) {
+	tests := []struct {
+		name string
+		link Link
+		want string
+	}{
+		{"category", Link{Navigation: Navigation{LinkSource: LinkSourceCategory}, CategorySlug: "eddas"}, "/category/eddas"},
+		{"post", Link{Navigation: Navigation{LinkSource: LinkSourcePost}, PostSlug: "havamal"}, "/post/havamal"},
+		{"custom slug", Link{Navigation: Navigation{Type: TypeInternal, LinkSource: LinkSourceCustom, Slug: "about"}}, "/about"},
+		{"custom path", Link{Navigation: Navigation{Type: TypeInternal, LinkSource: LinkSourceCustom, Slug: "/about/us"}}, "/about/us"},
+		{"external", Link{Navigation: Navigation{Type: TypeExternal, LinkSource: LinkSourceCustom, Slug: "https://example.com"}}, "https://example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := href(tt.link); got != tt.want {
+				t.Errorf("href() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	home := Link{Navigation: Navigation{ID: uuid.New(), Label: "Home", Slug: "/"}}
+	poems := Link{Navigation: Navigation{ID: uuid.New(), Label: "Poems", LinkSource: LinkSourceCategory}, CategorySlug: "poems"}
+	havamal := Link{Navigation: Navigation{ID: uuid.New(), Label: "Hávamál", LinkSource: LinkSourcePost, ParentId: &poems.ID}, PostSlug: "havamal"}
+	children := map[uuid.UUID][]Link{poems.ID: {havamal}}
+
+	tree := buildTree([]Link{home, poems}, children)
+	if len(tree) != 2 || tree[0].Href != "/" || len(tree[0].Children) != 0 {
+		t.Fatalf("buildTree() = %+v", tree)
+	}
+	if tree[1].Href != "/category/poems" || len(tree[1].Children) != 1 || tree[1].Children[0].Href != "/post/havamal" {
+		t.Errorf("buildTree() nested %+v", tree[1])
+	}
+	// Leaves answer an empty list of children, not null
+	if tree[1].Children[0].Children == nil {
+		t.Error("buildTree() leaves children nil")
+	}
+}
+
+func TestFlattenItems(t *testing.T) {
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	nested := []ItemOrder{
+		{ID: second.String(), Children: []ItemOrder{{ID: third.String()}}},
+		{ID: first.String()},
+	}
+	tests := []struct {
+		name    string
+		items   []ItemOrder
+		want    []Navigation
+		wantErr error
+	}{
+		{"nested", nested, []Navigation{
+			{ID: second, Order: 0},
+			{ID: third, ParentId: &second, Order: 0},
+			{ID: first, Order: 1},
+		}, nil},
+		{"unknown item", []ItemOrder{{ID: uuid.NewString()}}, nil, ErrUnknownItem},
+		{"listed twice", []ItemOrder{{ID: first.String(), Children: []ItemOrder{{ID: first.String()}}}}, nil, ErrDuplicateItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			known := map[uuid.UUID]bool{first: true, second: true, third: true}
+			positions := make([]Navigation, 0)
+			err := flattenItems(tt.items, nil, known, &positions)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("flattenItems() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(positions) != len(tt.want) {
+				t.Fatalf("flattenItems() = %+v, want %+v", positions, tt.want)
+			}
+			for i, got := range positions {
+				want := tt.want[i]
+				if got.ID != want.ID || got.Order != want.Order || (got.ParentId == nil) != (want.ParentId == nil) ||
+					(got.ParentId != nil && *got.ParentId != *want.ParentId) {
+					t.Errorf("flattenItems()[%d] = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyMenuRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  MenuRequest
+		wantSlug string
+		wantErr  error
+	}{
+		{"slug from the name", MenuRequest{Name: " Menú principal ", Location: LocationHeader}, "menu-principal", nil},
+		{"given slug", MenuRequest{Name: "Footer", Slug: "bottom", Location: LocationFooter}, "bottom", nil},
+		{"unknown location", MenuRequest{Name: "Side", Location: "aside"}, "", ErrInvalidLocation},
+		{"nothing to slug", MenuRequest{Name: "…", Location: LocationSidebar}, "", ErrInvalidMenuSlug},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			menu := &Menu{}
+			if err := applyMenuRequest(menu, &tt.request); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyMenuRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (menu.Slug != tt.wantSlug || menu.Location != tt.request.Location) {
+				t.Errorf("applyMenuRequest() = %+v, want slug %s", menu, tt.wantSlug)
+			}
+		})
+	}
+}
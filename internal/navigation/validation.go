@@ -0,0 +1,27 @@
+package navigation
+
+import (
+	"havamal-api/internal/apierror"
+	"net/url"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	apierror.RegisterStructRule(validateRequest, Request{})
+}
+
+// validateRequest checks the rules of external items, whose slug is the URL
+// they link to and whose link source can only be custom
+func validateRequest(sl validator.StructLevel) {
+	request := sl.Current().Interface().(Request)
+	if request.Type != TypeExternal {
+		return
+	}
+	if parsed, err := url.Parse(request.Slug); request.Slug != "" && (err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "") {
+		sl.ReportError(request.Slug, "slug", "Slug", "url", "")
+	}
+	if request.LinkSource != "" && request.LinkSource != LinkSourceCustom {
+		sl.ReportError(request.LinkSource, "link_source", "LinkSource", "eq", string(LinkSourceCustom))
+	}
+}
@@ -0,0 +1,51 @@
+package navigation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+func TestValidateRequest(t *testing.T) {
+	id := uuid.NewString()
+	tests := []struct {
+		name    string
+		request Request
+		want    []string
+	}{
+		{"internal", Request{Label: "About", Slug: "about"}, nil},
+		{"external", Request{Label: "Edda", Slug: "https://example.com/edda", Type: TypeExternal}, nil},
+		{"external without a URL", Request{Label: "Edda", Slug: "edda", Type: TypeExternal}, []string{"slug url"}},
+		{"external ftp link", Request{Label: "Edda", Slug: "ftp://example.com", Type: TypeExternal}, []string{"slug url"}},
+		{"external without a slug", Request{Label: "Edda", Type: TypeExternal}, []string{"slug required_if"}},
+		{"external to a post", Request{Label: "Edda", Slug: "https://example.com", Type: TypeExternal, LinkSource: LinkSourcePost, PostId: id}, []string{"link_source eq"}},
+		{"category without its id", Request{Label: "Poems", LinkSource: LinkSourceCategory}, []string{"category_id required_if"}},
+		{"post with a category id", Request{Label: "Poems", LinkSource: LinkSourcePost, PostId: id, CategoryId: id}, []string{"category_id excluded_unless"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := binding.Validator.ValidateStruct(tt.request)
+			var failures validator.ValidationErrors
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("validation error = %v, want none", err)
+				}
+				return
+			}
+			if !errors.As(err, &failures) {
+				t.Fatalf("validation error = %v, want field failures", err)
+			}
+			got := make([]string, len(failures))
+			for i, failure := range failures {
+				got[i] = failure.Field() + " " + failure.Tag()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validation failures = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
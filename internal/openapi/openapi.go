@@ -0,0 +1,358 @@
+// Package openapi builds the OpenAPI 3.1 document of the API from the
+// operations each package describes next to its routes, with schemas derived
+// from the request and response types by reflection.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/patch"
+)
+
+// Operation describes a route. Path is the gin path relative to the group the
+// route is registered on. Body and Response are values of the request and
+// response types, or schemas; a nil Response is a message.
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Query       []Param
+	Body        any
+	BodyMedia   string
+	// OptionalBody marks bodies that may be left out
+	OptionalBody bool
+	Response     any
+	Media        string
+	Status       int
+	// IfMatch marks writes that need the ETag the client last read
+	IfMatch bool
+	// Cached marks reads that answer ETag and honour If-None-Match
+	Cached bool
+	// Authenticated marks routes outside the protected group that need a
+	// token all the same
+	Authenticated bool
+	Deprecated    bool
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Schema      *Schema
+	Required    bool
+}
+
+// Query returns a string query parameter
+func Query(name string, description string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// Flag returns a boolean query parameter
+func Flag(name string, description string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "boolean"}}
+}
+
+// Enum returns a query parameter taking one of values
+func Enum(name string, description string, values ...string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "string", Enum: values}}
+}
+
+// Lang is the ?lang parameter of localised reads
+var Lang = Param{Name: "lang", Description: "Locale, instead of Accept-Language", Schema: &Schema{Type: "string", Enum: locales()}}
+
+// Message is the body of writes that only confirm what they did
+var Message = &Schema{
+	Type:       "object",
+	Properties: map[string]*Schema{"message": {Type: "string"}},
+	Required:   []string{"message"},
+}
+
+// Binary is the body of file uploads and downloads
+var Binary = &Schema{Type: "string", Format: "binary"}
+
+// Form returns the multipart body of an upload with the file in field and
+// the other form fields given
+func Form(field string, fields map[string]*Schema) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{field: Binary}, Required: []string{field}}
+	for name, property := range fields {
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+// Document is an OpenAPI 3.1 document
+type Document struct {
+	OpenAPI    string                        `json:"openapi"`
+	Info       Info                          `json:"info"`
+	Paths      map[string]map[string]*PathOp `json:"paths"`
+	Components Components                    `json:"components"`
+	Tags       []Tag                         `json:"tags,omitempty"`
+	schemas    *registry
+	tags       map[string]bool
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme  `json:"securitySchemes"`
+	Responses       map[string]*ResponseObject `json:"responses"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// PathOp is an operation as it appears in the document
+type PathOp struct {
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description,omitempty"`
+	OperationID string                     `json:"operationId"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []ParameterObject          `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+}
+
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type ResponseObject struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+const bearer = "bearerAuth"
+
+// New returns a document with the shared security scheme and error responses
+func New(title string, version string, description string) *Document {
+	d := &Document{
+		OpenAPI: "3.1.0",
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   make(map[string]map[string]*PathOp),
+		schemas: newRegistry(),
+		tags:    make(map[string]bool),
+	}
+	d.Components.Schemas = d.schemas.components
+	d.Components.SecuritySchemes = map[string]SecurityScheme{
+		bearer: {
+			Type:         "http",
+			Scheme:       "bearer",
+			BearerFormat: "JWT",
+			Description:  "Token from POST /auth/login. Also accepted as the token query parameter or the jwt cookie.",
+		},
+	}
+	body := d.schemas.of(problem{})
+	d.Components.Responses = map[string]*ResponseObject{
+		"Problem": {
+			Description: "Problem details (RFC 7807)",
+			Content:     map[string]MediaType{apierror.ContentType: {Schema: body}},
+		},
+	}
+	return d
+}
+
+// Add documents operations registered on the group at prefix. Protected
+// operations need a bearer token.
+func (d *Document) Add(prefix string, protected bool, operations ...Operation) {
+	for _, op := range operations {
+		path, params := convertPath(prefix + op.Path)
+		method := strings.ToLower(op.Method)
+		if d.Paths[path] == nil {
+			d.Paths[path] = make(map[string]*PathOp)
+		}
+		if op.Tag != "" && !d.tags[op.Tag] {
+			d.tags[op.Tag] = true
+			d.Tags = append(d.Tags, Tag{Name: op.Tag})
+		}
+		d.Paths[path][method] = d.operation(op, method, path, params, protected || op.Authenticated)
+	}
+}
+
+// Has reports whether the document describes the gin route
+func (d *Document) Has(method string, ginPath string) bool {
+	path, _ := convertPath(ginPath)
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// Operations lists the documented routes as gin method and path pairs
+func (d *Document) Operations() [][2]string {
+	operations := make([][2]string, 0)
+	for path, methods := range d.Paths {
+		for method := range methods {
+			operations = append(operations, [2]string{strings.ToUpper(method), ginPath(path)})
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i][1]+operations[i][0] < operations[j][1]+operations[j][0]
+	})
+	return operations
+}
+
+func (d *Document) operation(op Operation, method string, path string, params []string, protected bool) *PathOp {
+	result := &PathOp{
+		Summary:     op.Summary,
+		Description: op.Description,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]*ResponseObject),
+		Deprecated:  op.Deprecated,
+	}
+	if op.Tag != "" {
+		result.Tags = []string{op.Tag}
+	}
+	for _, name := range params {
+		schema := &Schema{Type: "string"}
+		switch {
+		case name == "id" || strings.HasSuffix(name, "_id"):
+			schema.Format = "uuid"
+		case name == "locale":
+			schema.Enum = locales()
+		}
+		result.Parameters = append(result.Parameters, ParameterObject{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	for _, param := range op.Query {
+		result.Parameters = append(result.Parameters, ParameterObject{
+			Name: param.Name, In: "query", Description: param.Description, Required: param.Required, Schema: param.Schema,
+		})
+	}
+	if op.IfMatch {
+		result.Parameters = append(result.Parameters, ParameterObject{
+			Name: "If-Match", In: "header", Required: true, Description: "ETag of the revision the change is based on", Schema: &Schema{Type: "string"},
+		})
+	}
+	if op.Cached {
+		result.Parameters = append(result.Parameters, ParameterObject{
+			Name: "If-None-Match", In: "header", Description: "ETag the client already has", Schema: &Schema{Type: "string"},
+		})
+	}
+
+	if op.Body != nil {
+		schema := d.schemas.of(op.Body)
+		content := make(map[string]MediaType)
+		switch {
+		case op.BodyMedia != "":
+			content[op.BodyMedia] = MediaType{Schema: schema}
+		case method == "patch":
+			content[patch.MergePatch] = MediaType{Schema: &Schema{Type: "object", Description: "Members of " + schema.name() + " to change; null removes one"}}
+			content[patch.JSONPatch] = MediaType{Schema: d.schemas.named("JSONPatch", jsonPatch)}
+		default:
+			content["application/json"] = MediaType{Schema: schema}
+		}
+		result.RequestBody = &RequestBody{Required: !op.OptionalBody, Content: content}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &ResponseObject{Description: http.StatusText(status)}
+	media := op.Media
+	if media == "" {
+		media = "application/json"
+	}
+	if status != http.StatusNoContent {
+		schema := Message
+		if op.Response != nil {
+			schema = d.schemas.of(op.Response)
+		}
+		success.Content = map[string]MediaType{media: {Schema: schema}}
+	}
+	if op.IfMatch || op.Cached {
+		success.Headers = map[string]Header{"ETag": {Description: "Current revision", Schema: &Schema{Type: "string"}}}
+	}
+	result.Responses[strconv.Itoa(status)] = success
+	if op.Cached {
+		result.Responses["304"] = &ResponseObject{Description: "The client's copy is current"}
+	}
+
+	problem := &ResponseObject{Ref: "#/components/responses/Problem"}
+	if protected {
+		result.Security = []map[string][]string{{bearer: {}}}
+		result.Responses["401"] = problem
+	}
+	if op.Body != nil {
+		result.Responses["422"] = problem
+	}
+	if len(params) > 0 {
+		result.Responses["404"] = problem
+	}
+	if op.IfMatch {
+		result.Responses["412"] = problem
+		result.Responses["428"] = problem
+	}
+	result.Responses["default"] = problem
+	return result
+}
+
+var paramPattern = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+
+// convertPath turns a gin path into an OpenAPI path and its parameter names
+func convertPath(path string) (string, []string) {
+	params := make([]string, 0)
+	converted := paramPattern.ReplaceAllStringFunc(path, func(match string) string {
+		params = append(params, match[1:])
+		return "{" + match[1:] + "}"
+	})
+	return converted, params
+}
+
+var templatePattern = regexp.MustCompile(`\{([A-Za-z_]+)\}`)
+
+func ginPath(path string) string {
+	return templatePattern.ReplaceAllString(path, ":$1")
+}
+
+func operationID(method string, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' || r == '-' || r == '_' }) {
+		part = strings.Trim(part, "{}")
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
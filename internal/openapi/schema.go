@@ -0,0 +1,278 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/i18n"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// name returns the component a reference points to
+func (s *Schema) name() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// problem is the shape apierror.Render answers with
+type problem struct {
+	Type     string                `json:"type"`
+	Title    string                `json:"title"`
+	Status   int                   `json:"status"`
+	Instance string                `json:"instance"`
+	Code     string                `json:"code"`
+	Detail   string                `json:"detail,omitempty"`
+	Errors   []apierror.FieldError `json:"errors,omitempty"`
+}
+
+var jsonPatch = &Schema{
+	Type:        "array",
+	Description: "JSON Patch (RFC 6902)",
+	Items: &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"op":    {Type: "string", Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+			"path":  {Type: "string", Description: "JSON Pointer"},
+			"from":  {Type: "string", Description: "JSON Pointer, for move and copy"},
+			"value": {Description: "For add, replace and test"},
+		},
+		Required: []string{"op", "path"},
+	},
+}
+
+func locales() []string {
+	return append([]string(nil), i18n.Supported...)
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// registry collects the component schemas of named struct types
+type registry struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newRegistry() *registry {
+	return &registry{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// of returns the schema of a value, or the value itself when it is a schema
+func (r *registry) of(value any) *Schema {
+	if schema, ok := value.(*Schema); ok {
+		return schema
+	}
+	return r.schema(reflect.TypeOf(value))
+}
+
+// named registers a schema under name and returns a reference to it
+func (r *registry) named(name string, schema *Schema) *Schema {
+	r.components[name] = schema
+	return ref(name)
+}
+
+func (r *registry) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t)
+		}
+		if name, ok := r.names[t]; ok {
+			return ref(name)
+		}
+		name := typeName(t)
+		r.names[t] = name
+		// Register first so that recursive types refer to themselves
+		r.components[name] = &Schema{}
+		*r.components[name] = *r.object(t)
+		return ref(name)
+	}
+	return &Schema{}
+}
+
+// typeName prefixes a type with its package, e.g. PostsRequest, unless it
+// already names what the package is about, e.g. Post, or is shared by all
+func typeName(t reflect.Type) string {
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if pkg == "openapi" || pkg == "apierror" || strings.HasPrefix(strings.ToLower(name), singular(pkg)) {
+		return name
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+func singular(word string) string {
+	if strings.HasSuffix(word, "ies") {
+		return strings.TrimSuffix(word, "ies") + "y"
+	}
+	return strings.TrimSuffix(word, "s")
+}
+
+func (r *registry) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.fields(t, schema)
+	return schema
+}
+
+// fields adds the JSON members of a struct, flattening embedded structs
+func (r *registry) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.fields(embedded, schema)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := r.schema(field.Type)
+		if rules(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// rules carries binding rules over to the schema and reports whether the
+// field is required. Rules after dive apply to the items.
+func rules(schema *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	required := false
+	target := schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			if target.Items == nil {
+				break
+			}
+			target = target.Items
+			continue
+		}
+		if target.Ref != "" {
+			if name == "required" && target == schema {
+				required = true
+			}
+			continue
+		}
+		switch name {
+		case "required":
+			if target == schema {
+				required = true
+			}
+		case "min", "gte":
+			bound(target, param, true)
+		case "max", "lte":
+			bound(target, param, false)
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "email":
+			target.Format = "email"
+		case "uuid":
+			target.Format = "uuid"
+		case "url":
+			target.Format = "uri"
+		case "slug":
+			target.Pattern = `^[a-z0-9]+(-[a-z0-9]+)*$`
+		case "locale":
+			target.Enum = locales()
+		}
+	}
+	return required
+}
+
+func bound(schema *Schema, param string, lower bool) {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+	switch schema.Type {
+	case "string":
+		if lower {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	case "array":
+		if lower {
+			schema.MinItems = &n
+		} else {
+			schema.MaxItems = &n
+		}
+	case "integer", "number":
+		if lower {
+			schema.Minimum = &n
+		} else {
+			schema.Maximum = &n
+		}
+	}
+}
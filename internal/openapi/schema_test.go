@@ -0,0 +1,129 @@
+package openapi
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type entry struct {
+	Id string `json:"id"`
+}
+
+type node struct {
+	entry
+	Title    string            `json:"title" binding:"required,min=1,max=200"`
+	Status   string            `json:"status" binding:"omitempty,oneof=draft published"`
+	Slug     string            `json:"slug" binding:"omitempty,slug"`
+	Columns  int               `json:"columns" binding:"omitempty,gte=1,lte=4"`
+	Tags     []string          `json:"tags" binding:"max=10,dive,max=50"`
+	Parent   *node             `json:"parent"`
+	Children []node            `json:"children"`
+	Meta     map[string]string `json:"meta"`
+	Created  time.Time         `json:"created_at"`
+	Owner    uuid.UUID         `json:"owner" binding:"required"`
+	Secret   string            `json:"-"`
+	hidden   string
+}
+
+func TestSchema(t *testing.T) {
+	r := newRegistry()
+	if got := r.of(node{}); got.Ref != "#/components/schemas/Node" {
+		t.Fatalf("of() = %+v, want a reference to Node", got)
+	}
+	schema := r.components["Node"]
+	if schema == nil || schema.Type != "object" {
+		t.Fatalf("Node schema = %+v", schema)
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	// Embedded structs are flattened and hidden members left out
+	if len(names) != 11 || schema.Properties["id"] == nil || schema.Properties["Secret"] != nil || schema.Properties["hidden"] != nil {
+		t.Errorf("Node properties = %v", names)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"title", "owner"}) {
+		t.Errorf("Node required = %v, want title and owner", schema.Required)
+	}
+
+	title := schema.Properties["title"]
+	if *title.MinLength != 1 || *title.MaxLength != 200 {
+		t.Errorf("title = %+v, want 1 to 200 characters", title)
+	}
+	if status := schema.Properties["status"]; !reflect.DeepEqual(status.Enum, []string{"draft", "published"}) {
+		t.Errorf("status enum = %v", status.Enum)
+	}
+	if slug := schema.Properties["slug"]; slug.Pattern == "" {
+		t.Error("slug has no pattern")
+	}
+	if columns := schema.Properties["columns"]; *columns.Minimum != 1 || *columns.Maximum != 4 {
+		t.Errorf("columns = %+v, want 1 to 4", columns)
+	}
+	// Rules after dive bound the items, not the list
+	if tags := schema.Properties["tags"]; *tags.MaxItems != 10 || *tags.Items.MaxLength != 50 || tags.MaxLength != nil {
+		t.Errorf("tags = %+v, items %+v", tags, tags.Items)
+	}
+	if parent := schema.Properties["parent"]; parent.Ref != "#/components/schemas/Node" {
+		t.Errorf("parent = %+v, want a reference to Node itself", parent)
+	}
+	if children := schema.Properties["children"]; children.Type != "array" || children.Items.Ref != "#/components/schemas/Node" {
+		t.Errorf("children = %+v", children)
+	}
+	if meta := schema.Properties["meta"]; meta.Type != "object" || meta.AdditionalProperties.Type != "string" {
+		t.Errorf("meta = %+v", meta)
+	}
+	if created := schema.Properties["created_at"]; created.Format != "date-time" {
+		t.Errorf("created_at = %+v", created)
+	}
+	if owner := schema.Properties["owner"]; owner.Format != "uuid" {
+		t.Errorf("owner = %+v", owner)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{http.Request{}, "HttpRequest"},
+		{url.URL{}, "URL"},
+		{node{}, "Node"},
+	}
+	for _, tt := range tests {
+		if got := typeName(reflect.TypeOf(tt.value)); got != tt.want {
+			t.Errorf("typeName(%T) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+	for word, want := range map[string]string{"categories": "category", "posts": "post", "media": "media"} {
+		if got := singular(word); got != want {
+			t.Errorf("singular(%s) = %s, want %s", word, got, want)
+		}
+	}
+}
+
+func TestPaths(t *testing.T) {
+	path, params := convertPath("/api/posts/:id/preview-links/:link_id")
+	if path != "/api/posts/{id}/preview-links/{link_id}" || !reflect.DeepEqual(params, []string{"id", "link_id"}) {
+		t.Errorf("convertPath() = %s %v", path, params)
+	}
+	if got := ginPath(path); got != "/api/posts/:id/preview-links/:link_id" {
+		t.Errorf("ginPath() = %s", got)
+	}
+	if got := operationID("get", path); got != "getApiPostsIdPreviewLinksLinkId" {
+		t.Errorf("operationID() = %s", got)
+	}
+
+	d := New("Hávamál", "1", "")
+	d.Add("/api", true, Operation{Method: "GET", Path: "/posts/:id", Tag: "posts", Response: node{}})
+	if !d.Has("GET", "/api/posts/:id") || d.Has("DELETE", "/api/posts/:id") {
+		t.Error("Has() does not match the documented operations")
+	}
+	if got := d.Operations(); !reflect.DeepEqual(got, [][2]string{{"GET", "/api/posts/:id"}}) {
+		t.Errorf("Operations() = %v", got)
+	}
+}
@@ -0,0 +1,22 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed docs.html
+var docs []byte
+
+// Serve answers the document
+func (d *Document) Serve(c *gin.Context) {
+	c.JSON(http.StatusOK, d)
+}
+
+// Docs answers a page that renders the document from /openapi.json. It is
+// bundled so that it works without reaching any CDN.
+func Docs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docs)
+}
@@ -0,0 +1,224 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var errValueDiffers = errors.New("value differs")
+
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// apply runs JSON Patch operations in order; the patch fails as a whole when
+// any of them does
+func apply(document any, operations []operation) (any, error) {
+	for i, op := range operations {
+		var err error
+		if document, err = op.apply(document); err != nil {
+			kind := ErrInvalid
+			if errors.Is(err, errValueDiffers) {
+				kind = ErrTestFailed
+			}
+			return nil, fmt.Errorf("%w: operation %d (%s %s): %v", kind, i, op.Op, op.Path, err)
+		}
+	}
+	return document, nil
+}
+
+func (op operation) apply(document any) (any, error) {
+	path, err := pointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("value is required")
+		}
+		var value any
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return add(document, path, value)
+		case "replace":
+			if len(path) == 0 {
+				return value, nil
+			}
+			if document, _, err = remove(document, path); err != nil {
+				return nil, err
+			}
+			return add(document, path, value)
+		}
+		current, err := get(document, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, errValueDiffers
+		}
+		return document, nil
+	case "remove":
+		document, _, err = remove(document, path)
+		return document, err
+	case "move", "copy":
+		from, err := pointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("a value cannot be moved into itself")
+		}
+		var value any
+		if op.Op == "move" {
+			document, value, err = remove(document, from)
+		} else if value, err = get(document, from); err == nil {
+			// The copy must not share objects or arrays with the original
+			value, err = clone(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(document, path, value)
+	}
+	return nil, fmt.Errorf("unknown operation")
+}
+
+func clone(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied any
+	err = json.Unmarshal(data, &copied)
+	return copied, err
+}
+
+// pointer splits a JSON Pointer (RFC 6901) into its unescaped tokens
+func pointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func index(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length || (i == length && !allowEnd) || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("index %s out of range", token)
+	}
+	return i, nil
+}
+
+func get(document any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := document.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %s not found", token)
+			}
+			document = value
+		case []any:
+			i, err := index(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			document = node[i]
+		default:
+			return nil, fmt.Errorf("%s is not inside an object or array", token)
+		}
+	}
+	return document, nil
+}
+
+// edit rebuilds document with change applied to the parent of path; change
+// returns the new parent and the value it took out, if any
+func edit(document any, path []string, change func(parent any, token string) (any, any, error)) (any, any, error) {
+	if len(path) == 1 {
+		return change(document, path[0])
+	}
+	child, err := get(document, path[:1])
+	if err != nil {
+		return nil, nil, err
+	}
+	child, taken, err := edit(child, path[1:], change)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch node := document.(type) {
+	case map[string]any:
+		node[path[0]] = child
+	case []any:
+		i, _ := index(path[0], len(node), false)
+		node[i] = child
+	}
+	return document, taken, nil
+}
+
+func add(document any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	document, _, err := edit(document, path, func(parent any, token string) (any, any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil, nil
+		case []any:
+			i, err := index(token, len(node), true)
+			if err != nil {
+				return nil, nil, err
+			}
+			node = append(node[:i], append([]any{value}, node[i:]...)...)
+			return node, nil, nil
+		}
+		return nil, nil, fmt.Errorf("%s is not inside an object or array", token)
+	})
+	return document, err
+}
+
+func remove(document any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("the whole document cannot be removed")
+	}
+	return edit(document, path, func(parent any, token string) (any, any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, nil, fmt.Errorf("member %s not found", token)
+			}
+			delete(node, token)
+			return node, value, nil
+		case []any:
+			i, err := index(token, len(node), false)
+			if err != nil {
+				return nil, nil, err
+			}
+			value := node[i]
+			return append(node[:i], node[i+1:]...), value, nil
+		}
+		return nil, nil, fmt.Errorf("%s is not inside an object or array", token)
+	})
+}
@@ -0,0 +1,96 @@
+// Package patch applies the body of a PATCH request to the current state of a
+// resource: a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902).
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"havamal-api/internal/apierror"
+	"io"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	MergePatch = "application/merge-patch+json"
+	JSONPatch  = "application/json-patch+json"
+)
+
+var (
+	ErrUnsupportedMediaType = apierror.New(apierror.PatchUnsupportedMediaType)
+	ErrInvalid              = apierror.New(apierror.PatchInvalid)
+	ErrTestFailed           = apierror.New(apierror.PatchTestFailed)
+)
+
+// Apply patches target, the current resource in the form of its full update
+// request, with the request body and validates the result like a full update.
+// A body sent as plain JSON is read as a merge patch.
+func Apply(c *gin.Context, target any) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var document any
+	if err := json.Unmarshal(current, &document); err != nil {
+		return err
+	}
+
+	switch c.ContentType() {
+	case MergePatch, binding.MIMEJSON, "":
+		var patch any
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return err
+		}
+		document = merge(document, patch)
+	case JSONPatch:
+		var operations []operation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return err
+		}
+		if document, err = apply(document, operations); err != nil {
+			return err
+		}
+	default:
+		c.Header("Accept-Patch", MergePatch+", "+JSONPatch)
+		return ErrUnsupportedMediaType
+	}
+
+	patched, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	// Decode into a zero value so that removed members end up empty
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.NewDecoder(bytes.NewReader(patched)).Decode(target); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(target)
+}
+
+// merge applies a merge patch: objects are merged member by member, null
+// removes a member and any other value replaces the target
+func merge(target any, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]any)
+	if !ok {
+		object = make(map[string]any)
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(object, name)
+		} else {
+			object[name] = merge(object[name], value)
+		}
+	}
+	return object
+}
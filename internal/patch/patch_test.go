@@ -0,0 +1,163 @@
+package patch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type resource struct {
+	Title  string            `json:"title" binding:"required"`
+	Tags   []string          `json:"tags"`
+	Author *author           `json:"author,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+type author struct {
+	Name string `json:"name"`
+	Mail string `json:"mail,omitempty"`
+}
+
+func current() resource {
+	return resource{
+		Title:  "Hávamál",
+		Tags:   []string{"poetry", "norse"},
+		Author: &author{Name: "Odin", Mail: "odin@example.com"},
+	}
+}
+
+func request(contentType string, body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	if contentType != "" {
+		c.Request.Header.Set("Content-Type", contentType)
+	}
+	return c
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        resource
+	}{
+		{"replace member", MergePatch, `{"title":"Völuspá"}`,
+			resource{Title: "Völuspá", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}},
+		{"null removes", MergePatch, `{"tags":null}`,
+			resource{Title: "Hávamál", Author: &author{Name: "Odin", Mail: "odin@example.com"}}},
+		{"nested object merges", MergePatch, `{"author":{"mail":null,"name":"Óðinn"}}`,
+			resource{Title: "Hávamál", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Óðinn"}}},
+		{"arrays are replaced", MergePatch, `{"tags":["wisdom"]}`,
+			resource{Title: "Hávamál", Tags: []string{"wisdom"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}},
+		{"new object member", MergePatch, `{"meta":{"lang":"non"}}`,
+			resource{Title: "Hávamál", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}, Meta: map[string]string{"lang": "non"}}},
+		{"plain json", "application/json", `{"title":"Völuspá"}`,
+			resource{Title: "Völuspá", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}},
+		{"empty patch", MergePatch, `{}`, current()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := current()
+			if err := Apply(request(tt.contentType, tt.body), &target); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !reflect.DeepEqual(target, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", target, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    resource
+		wantErr error
+	}{
+		{"add to array", `[{"op":"add","path":"/tags/1","value":"edda"}]`,
+			resource{Title: "Hávamál", Tags: []string{"poetry", "edda", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}, nil},
+		{"append to array", `[{"op":"add","path":"/tags/-","value":"edda"}]`,
+			resource{Title: "Hávamál", Tags: []string{"poetry", "norse", "edda"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}, nil},
+		{"remove", `[{"op":"remove","path":"/author/mail"}]`,
+			resource{Title: "Hávamál", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin"}}, nil},
+		{"replace", `[{"op":"replace","path":"/title","value":"Völuspá"}]`,
+			resource{Title: "Völuspá", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}, nil},
+		{"move", `[{"op":"move","from":"/tags/1","path":"/tags/0"}]`,
+			resource{Title: "Hávamál", Tags: []string{"norse", "poetry"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}, nil},
+		{"copy", `[{"op":"copy","from":"/author/name","path":"/title"}]`,
+			resource{Title: "Odin", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}, nil},
+		{"escaped pointer", `[{"op":"add","path":"/meta","value":{}},{"op":"add","path":"/meta/a~1b~0c","value":"x"}]`,
+			resource{Title: "Hávamál", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}, Meta: map[string]string{"a/b~c": "x"}}, nil},
+		{"test passes", `[{"op":"test","path":"/title","value":"Hávamál"},{"op":"replace","path":"/title","value":"Völuspá"}]`,
+			resource{Title: "Völuspá", Tags: []string{"poetry", "norse"}, Author: &author{Name: "Odin", Mail: "odin@example.com"}}, nil},
+		{"test fails", `[{"op":"test","path":"/title","value":"Völuspá"}]`, resource{}, ErrTestFailed},
+		{"missing member", `[{"op":"remove","path":"/nothing"}]`, resource{}, ErrInvalid},
+		{"index out of range", `[{"op":"add","path":"/tags/5","value":"x"}]`, resource{}, ErrInvalid},
+		{"leading zero index", `[{"op":"replace","path":"/tags/01","value":"x"}]`, resource{}, ErrInvalid},
+		{"move into itself", `[{"op":"move","from":"/author","path":"/author/name"}]`, resource{}, ErrInvalid},
+		{"missing value", `[{"op":"add","path":"/title"}]`, resource{}, ErrInvalid},
+		{"unknown operation", `[{"op":"swap","path":"/title"}]`, resource{}, ErrInvalid},
+		{"relative path", `[{"op":"remove","path":"title"}]`, resource{}, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := current()
+			err := Apply(request(JSONPatch, tt.body), &target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(target, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", target, tt.want)
+			}
+		})
+	}
+}
+
+// A failed operation leaves nothing half applied
+func TestApplyJSONPatchIsAtomic(t *testing.T) {
+	target := current()
+	body := `[{"op":"replace","path":"/title","value":"Völuspá"},{"op":"remove","path":"/nothing"}]`
+	if err := Apply(request(JSONPatch, body), &target); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Apply() error = %v, want %v", err, ErrInvalid)
+	}
+	if !reflect.DeepEqual(target, current()) {
+		t.Errorf("Apply() changed the target to %+v", target)
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{"unsupported media type", "text/plain", `title=x`, ErrUnsupportedMediaType},
+		{"invalid result", MergePatch, `{"title":null}`, nil},
+		{"malformed body", MergePatch, `{"title":`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := current()
+			c := request(tt.contentType, tt.body)
+			err := Apply(c, &target)
+			if err == nil {
+				t.Fatal("Apply() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrUnsupportedMediaType && c.Writer.Header().Get("Accept-Patch") == "" {
+				t.Error("Apply() did not set Accept-Patch")
+			}
+		})
+	}
+}
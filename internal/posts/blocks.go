@@ -0,0 +1,309 @@
+package posts
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"havamal-api/internal/apierror"
+	gohtml "html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// BlocksVersion is the current version of the block schema. Documents must
+// declare the version they were written against.
+const BlocksVersion = 1
+
+type BlockType string
+
+const (
+	BlockParagraph BlockType = "paragraph"
+	BlockHeading   BlockType = "heading"
+	BlockImage     BlockType = "image"
+	BlockQuote     BlockType = "quote"
+	BlockCode      BlockType = "code"
+	BlockEmbed     BlockType = "embed"
+	BlockStanza    BlockType = "stanza"
+	BlockGallery   BlockType = "gallery"
+	BlockCallout   BlockType = "callout"
+)
+
+var ErrInvalidBlocks = apierror.New(apierror.PostInvalidBlocks)
+
+// Document is the structured representation of a post's content
+type Document struct {
+	Version int     `json:"version"`
+	Blocks  []Block `json:"blocks"`
+}
+
+// Block is a typed unit of content. Only the fields of its type are used:
+// text for paragraph, heading (with level), quote (with citation), code (with
+// language) and callout (with variant); lines for stanza; url, alt and caption
+// for image and embed; images for gallery.
+type Block struct {
+	Type     BlockType      `json:"type"`
+	Text     string         `json:"text,omitempty"`
+	Level    int            `json:"level,omitempty"`
+	Citation string         `json:"citation,omitempty"`
+	Language string         `json:"language,omitempty"`
+	Variant  string         `json:"variant,omitempty"`
+	Lines    []string       `json:"lines,omitempty"`
+	URL      string         `json:"url,omitempty"`
+	Alt      string         `json:"alt,omitempty"`
+	Caption  string         `json:"caption,omitempty"`
+	Images   []GalleryImage `json:"images,omitempty"`
+}
+
+type GalleryImage struct {
+	URL     string `json:"url"`
+	Alt     string `json:"alt,omitempty"`
+	Caption string `json:"caption,omitempty"`
+}
+
+var calloutVariants = map[string]bool{"info": true, "note": true, "tip": true, "warning": true}
+
+var languagePattern = regexp.MustCompile(`^[a-zA-Z0-9_+\-]*$`)
+
+// embedPatterns turn supported provider URLs into their embeddable form
+var embedPatterns = []struct {
+	pattern *regexp.Regexp
+	embed   string
+}{
+	{regexp.MustCompile(`^https://(?:www\.)?youtube\.com/watch\?(?:.*&)?v=([\w-]{11})`), "https://www.youtube-nocookie.com/embed/$1"},
+	{regexp.MustCompile(`^https://youtu\.be/([\w-]{11})`), "https://www.youtube-nocookie.com/embed/$1"},
+	{regexp.MustCompile(`^https://(?:www\.)?youtube(?:-nocookie)?\.com/embed/([\w-]{11})`), "https://www.youtube-nocookie.com/embed/$1"},
+	{regexp.MustCompile(`^https://(?:www\.)?vimeo\.com/(\d+)`), "https://player.vimeo.com/video/$1"},
+	{regexp.MustCompile(`^https://player\.vimeo\.com/video/(\d+)`), "https://player.vimeo.com/video/$1"},
+	{regexp.MustCompile(`^https://open\.spotify\.com/(?:embed/)?(track|episode|show|album|playlist)/(\w+)`), "https://open.spotify.com/embed/$1/$2"},
+}
+
+// EmbedURL returns the embeddable URL for a supported provider
+func EmbedURL(raw string) (string, bool) {
+	for _, p := range embedPatterns {
+		if match := p.pattern.FindStringSubmatchIndex(raw); match != nil {
+			return string(p.pattern.ExpandString(nil, p.embed, raw, match)), true
+		}
+	}
+	return "", false
+}
+
+// Validate checks the document against the schema of its version and reports
+// every problem found.
+func (d *Document) Validate() error {
+	problems := make([]string, 0)
+	if d.Version != BlocksVersion {
+		problems = append(problems, fmt.Sprintf("unsupported version %d (current is %d)", d.Version, BlocksVersion))
+	}
+	if len(d.Blocks) == 0 {
+		problems = append(problems, "at least one block is required")
+	}
+	for i, block := range d.Blocks {
+		for _, problem := range block.validate() {
+			problems = append(problems, fmt.Sprintf("block %d (%s): %s", i, block.Type, problem))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidBlocks, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (b *Block) validate() []string {
+	problems := make([]string, 0)
+	requireText := func() {
+		if strings.TrimSpace(b.Text) == "" {
+			problems = append(problems, "text is required")
+		}
+	}
+	switch b.Type {
+	case BlockParagraph:
+		requireText()
+	case BlockHeading:
+		requireText()
+		if b.Level < 1 || b.Level > 6 {
+			problems = append(problems, "level must be between 1 and 6")
+		}
+	case BlockQuote:
+		requireText()
+	case BlockCode:
+		if b.Text == "" {
+			problems = append(problems, "text is required")
+		}
+		if !languagePattern.MatchString(b.Language) {
+			problems = append(problems, "language may only contain letters, digits, '_', '+' and '-'")
+		}
+	case BlockCallout:
+		requireText()
+		if b.Variant != "" && !calloutVariants[b.Variant] {
+			problems = append(problems, "variant must be info, note, tip or warning")
+		}
+	case BlockStanza:
+		if len(b.Lines) == 0 {
+			problems = append(problems, "lines are required")
+		}
+	case BlockImage:
+		if !validMediaURL(b.URL) {
+			problems = append(problems, "url must be an http(s) or relative URL")
+		}
+	case BlockGallery:
+		if len(b.Images) == 0 {
+			problems = append(problems, "images are required")
+		}
+		for i, image := range b.Images {
+			if !validMediaURL(image.URL) {
+				problems = append(problems, fmt.Sprintf("image %d: url must be an http(s) or relative URL", i))
+			}
+		}
+	case BlockEmbed:
+		if _, ok := EmbedURL(b.URL); !ok {
+			problems = append(problems, "url must be a YouTube, Vimeo or Spotify link")
+		}
+	default:
+		problems = append(problems, "unknown block type")
+	}
+	return problems
+}
+
+func validMediaURL(raw string) bool {
+	if raw == "" {
+		return false
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "" || parsed.Scheme == "http" || parsed.Scheme == "https"
+}
+
+// HTML renders the document. Text is escaped; the result still goes through
+// Render like any other HTML content.
+func (d *Document) HTML() string {
+	var b strings.Builder
+	esc := gohtml.EscapeString
+	for _, block := range d.Blocks {
+		switch block.Type {
+		case BlockParagraph:
+			b.WriteString("<p>" + esc(block.Text) + "</p>\n")
+		case BlockHeading:
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", block.Level, esc(block.Text), block.Level)
+		case BlockQuote:
+			b.WriteString("<blockquote><p>" + esc(block.Text) + "</p>")
+			if block.Citation != "" {
+				b.WriteString("<cite>" + esc(block.Citation) + "</cite>")
+			}
+			b.WriteString("</blockquote>\n")
+		case BlockCode:
+			class := ""
+			if block.Language != "" {
+				class = ` class="language-` + block.Language + `"`
+			}
+			b.WriteString("<pre><code" + class + ">" + esc(block.Text) + "</code></pre>\n")
+		case BlockCallout:
+			variant := block.Variant
+			if variant == "" {
+				variant = "info"
+			}
+			b.WriteString(`<aside class="callout callout-` + variant + `"><p>` + esc(block.Text) + "</p></aside>\n")
+		case BlockStanza:
+			lines := make([]string, len(block.Lines))
+			for i, line := range block.Lines {
+				lines[i] = esc(line)
+			}
+			b.WriteString(`<p class="stanza">` + strings.Join(lines, "<br>") + "</p>\n")
+		case BlockImage:
+			b.WriteString(figure(block.URL, block.Alt, block.Caption) + "\n")
+		case BlockGallery:
+			b.WriteString(`<div class="gallery">`)
+			for _, image := range block.Images {
+				b.WriteString(figure(image.URL, image.Alt, image.Caption))
+			}
+			b.WriteString("</div>\n")
+		case BlockEmbed:
+			src, _ := EmbedURL(block.URL)
+			b.WriteString(`<figure class="embed"><iframe src="` + esc(src) + `" title="` + esc(block.Caption) + `" loading="lazy" allowfullscreen></iframe>`)
+			if block.Caption != "" {
+				b.WriteString("<figcaption>" + esc(block.Caption) + "</figcaption>")
+			}
+			b.WriteString("</figure>\n")
+		}
+	}
+	return b.String()
+}
+
+func figure(src, alt, caption string) string {
+	esc := gohtml.EscapeString
+	out := `<figure><img src="` + esc(src) + `" alt="` + esc(alt) + `">`
+	if caption != "" {
+		out += "<figcaption>" + esc(caption) + "</figcaption>"
+	}
+	return out + "</figure>"
+}
+
+// Text renders the document as plain text, one block per paragraph
+func (d *Document) Text() string {
+	parts := make([]string, 0, len(d.Blocks))
+	for _, block := range d.Blocks {
+		switch block.Type {
+		case BlockParagraph, BlockHeading, BlockCode, BlockCallout:
+			parts = append(parts, block.Text)
+		case BlockQuote:
+			text := block.Text
+			if block.Citation != "" {
+				text += "\n— " + block.Citation
+			}
+			parts = append(parts, text)
+		case BlockStanza:
+			parts = append(parts, strings.Join(block.Lines, "\n"))
+		case BlockImage, BlockEmbed:
+			if block.Caption != "" {
+				parts = append(parts, block.Caption)
+			}
+		case BlockGallery:
+			for _, image := range block.Images {
+				if image.Caption != "" {
+					parts = append(parts, image.Caption)
+				}
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Value stores the document as JSON
+func (d *Document) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// blocksScanner reads a nullable JSON blocks column into a document pointer
+type blocksScanner struct {
+	dest **Document
+}
+
+func (s blocksScanner) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*s.dest = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported blocks column type %T", src)
+	}
+	var document Document
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	*s.dest = &document
+	return nil
+}
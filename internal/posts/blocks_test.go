@@ -0,0 +1,117 @@
+package posts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDocumentValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		document Document
+		problems []string
+	}{
+		{"valid", Document{Version: BlocksVersion, Blocks: []Block{
+			{Type: BlockHeading, Text: "Hávamál", Level: 1},
+			{Type: BlockParagraph, Text: "Gáttir allar"},
+			{Type: BlockStanza, Lines: []string{"Gáttir allar,", "áðr gangi fram"}},
+			{Type: BlockCode, Text: "go test", Language: "shell"},
+			{Type: BlockCallout, Text: "Note", Variant: "tip"},
+			{Type: BlockImage, URL: "/images/a.png"},
+			{Type: BlockGallery, Images: []GalleryImage{{URL: "https://example.com/b.jpg"}}},
+			{Type: BlockEmbed, URL: "https://youtu.be/dQw4w9WgXcQ"},
+		}}, nil},
+		{"unsupported version", Document{Version: 2, Blocks: []Block{{Type: BlockParagraph, Text: "x"}}}, []string{"unsupported version 2"}},
+		{"no blocks", Document{Version: BlocksVersion}, []string{"at least one block is required"}},
+		{"blank text", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockParagraph, Text: "  "}}}, []string{"block 0 (paragraph): text is required"}},
+		{"heading level", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockHeading, Text: "x", Level: 7}}}, []string{"level must be between 1 and 6"}},
+		{"code language", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockCode, Text: "x", Language: `go"><script>`}}}, []string{"language may only contain"}},
+		{"callout variant", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockCallout, Text: "x", Variant: "danger"}}}, []string{"variant must be"}},
+		{"empty stanza", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockStanza}}}, []string{"lines are required"}},
+		{"script url", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockImage, URL: "javascript:alert(1)"}}}, []string{"url must be an http(s) or relative URL"}},
+		{"gallery image", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockGallery, Images: []GalleryImage{{URL: "/a.png"}, {URL: ""}}}}}, []string{"image 1: url"}},
+		{"unsupported embed", Document{Version: BlocksVersion, Blocks: []Block{{Type: BlockEmbed, URL: "https://example.com/video"}}}, []string{"YouTube, Vimeo or Spotify"}},
+		{"unknown type", Document{Version: BlocksVersion, Blocks: []Block{{Type: "table"}}}, []string{"block 0 (table): unknown block type"}},
+		{"every problem is reported", Document{Version: 0, Blocks: []Block{{Type: BlockParagraph}, {Type: BlockStanza}}},
+			[]string{"unsupported version 0", "block 0 (paragraph): text is required", "block 1 (stanza): lines are required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.document.Validate()
+			if tt.problems == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidBlocks) {
+				t.Fatalf("Validate() error = %v, want %v", err, ErrInvalidBlocks)
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("Validate() error = %q, want it to report %q", err, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+		ok   bool
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", true},
+		{"https://www.youtube.com/watch?list=x&v=dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", true},
+		{"https://youtu.be/dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", true},
+		{"https://vimeo.com/76979871", "https://player.vimeo.com/video/76979871", true},
+		{"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", "https://open.spotify.com/embed/track/4uLU6hMCjMI75M1A2tKUQC", true},
+		{"http://youtu.be/dQw4w9WgXcQ", "", false},
+		{"https://example.com/watch?v=dQw4w9WgXcQ", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, ok := EmbedURL(tt.url)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("EmbedURL() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// Block text is escaped, so the document cannot smuggle markup into Render
+func TestDocumentHTMLEscapes(t *testing.T) {
+	document := Document{Version: BlocksVersion, Blocks: []Block{
+		{Type: BlockParagraph, Text: "<script>alert(1)</script>"},
+		{Type: BlockImage, URL: `/a.png" onerror="alert(1)`, Alt: "x"},
+		{Type: BlockQuote, Text: "Deyr fé", Citation: "<b>Hávamál</b>"},
+	}}
+	rendered, err := Render(FormatHTML, document.HTML())
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, refused := range []string{"<script", "onerror", "<b>"} {
+		if strings.Contains(rendered, refused) {
+			t.Errorf("rendered document %q contains %q", rendered, refused)
+		}
+	}
+	if !strings.Contains(rendered, "<cite>&lt;b&gt;Hávamál&lt;/b&gt;</cite>") {
+		t.Errorf("rendered document %q lost the citation", rendered)
+	}
+}
+
+func TestDocumentText(t *testing.T) {
+	document := Document{Version: BlocksVersion, Blocks: []Block{
+		{Type: BlockHeading, Text: "Hávamál", Level: 1},
+		{Type: BlockStanza, Lines: []string{"Deyr fé,", "deyja frændr"}},
+		{Type: BlockQuote, Text: "Deyr fé", Citation: "Hávamál 76"},
+		{Type: BlockImage, URL: "/a.png"},
+		{Type: BlockGallery, Images: []GalleryImage{{URL: "/b.png", Caption: "Runes"}}},
+	}}
+	want := "Hávamál\n\nDeyr fé,\ndeyja frændr\n\nDeyr fé\n— Hávamál 76\n\nRunes"
+	if got := document.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,12 @@
+package posts
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound         = apierror.New(apierror.PostNotFound)
+	ErrAutosaveNotFound = apierror.New(apierror.AutosaveNotFound)
+	// ErrNoLock means nobody holds a live lock on the post
+	ErrNoLock            = apierror.New(apierror.NotFound)
+	ErrLocked            = apierror.New(apierror.PostLocked)
+	ErrTranslationExists = apierror.New(apierror.PostTranslationExists)
+)
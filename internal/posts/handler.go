@@ -1,7 +1,15 @@
 package posts
 
 import (
+	"errors"
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/patch"
+	"havamal-api/middleware"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,105 +25,272 @@ func NewHandler(service Service) Handler {
 func (h *Handler) Create(c *gin.Context) {
 	var post Request
 	if err := c.ShouldBindJSON(&post); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.CreatePost(&post); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Post created successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Post created successfully", "slug": post.Slug})
 }
 
 func (h *Handler) GetPost(c *gin.Context) {
 	id := c.Param("id")
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
 	post, err := h.service.GetPost(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, post)
+	applyRender(mode, post)
+	etag.JSON(c, post.Revision, post)
 }
 
 func (h *Handler) GetPosts(c *gin.Context) {
-	posts, err := h.service.GetPosts()
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+	locale := i18n.FromRequest(c)
+	relations := h.service.Relations(locale)
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	posts, err := h.service.GetPosts(selection)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	for i := range posts {
+		applyRender(mode, &posts[i])
+	}
+	fields.JSON(c, selection, relations, posts)
 }
 
 func (h *Handler) GetPublishedPosts(c *gin.Context) {
-	posts, err := h.service.GetPublishedPosts()
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+	locale := i18n.FromRequest(c)
+	relations := h.service.Relations(locale)
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	posts, err := h.service.GetPublishedPosts(locale, selection)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	for i := range posts {
+		applyRender(mode, &posts[i])
+	}
+	fields.JSON(c, selection, relations, posts)
+}
+
+func (h *Handler) GetPostsByTag(c *gin.Context) {
+	slug := c.Param("slug")
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+	locale := i18n.FromRequest(c)
+	relations := h.service.Relations(locale)
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	posts, err := h.service.GetPublishedByTag(slug, locale, selection)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	for i := range posts {
+		applyRender(mode, &posts[i])
+	}
+	fields.JSON(c, selection, relations, posts)
 }
 
 func (h *Handler) GetPostsByAuthor(c *gin.Context) {
 	authorId := c.Param("author_id")
-	posts, err := h.service.GetPostsByAuthor(authorId)
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+	locale := i18n.FromRequest(c)
+	relations := h.service.Relations(locale)
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	posts, err := h.service.GetPostsByAuthor(authorId, locale, selection)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	for i := range posts {
+		applyRender(mode, &posts[i])
+	}
+	fields.JSON(c, selection, relations, posts)
 }
 
 func (h *Handler) GetPostBySlug(c *gin.Context) {
 	slug := c.Param("slug")
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
 	post, err := h.service.GetPostBySlug(slug)
+	if errors.Is(err, ErrNotFound) {
+		h.redirectSlug(c, slug)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.Header("Content-Language", post.Locale)
+	applyRender(mode, post)
+	etag.JSON(c, post.Revision, post)
+}
+
+// redirectSlug points an old slug at the post's current one
+func (h *Handler) redirectSlug(c *gin.Context, oldSlug string) {
+	slug, err := h.service.GetRedirect(oldSlug)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, post)
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, oldSlug)+slug)
+	c.JSON(http.StatusMovedPermanently, gin.H{"slug": slug})
 }
 
 func (h *Handler) GetSummariesByCategory(c *gin.Context) {
 	category := c.Param("category")
-	posts, err := h.service.GetSummariesByCategory(category)
+	includeDescendants := c.Query("include_descendants") == "true"
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+	locale := i18n.FromRequest(c)
+	relations := h.service.Relations(locale)
+	selection, ok := fields.FromRequest(c, Fields, relations)
+	if !ok {
+		return
+	}
+	posts, err := h.service.GetSummariesByCategory(category, includeDescendants, locale, selection)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	for i := range posts {
+		applyRender(mode, &posts[i])
+	}
+	fields.JSON(c, selection, relations, posts)
 }
 
 func (h *Handler) UpdatePost(c *gin.Context) {
 	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
 	var post Request
 	if err := c.ShouldBindJSON(&post); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
-	if err := h.service.UpdatePost(id, &post); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.UpdatePost(id, &post, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Post updated successfully"})
+	h.updated(c, id)
 }
 
 func (h *Handler) DeletePost(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.service.DeletePost(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeletePost(id, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
 }
 
+// PatchPost applies a merge patch or JSON Patch to the post and answers with
+// the updated post. Members the patch leaves out keep their current value.
+func (h *Handler) PatchPost(c *gin.Context) {
+	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	current, err := h.service.GetPost(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	request := current.request()
+	if err := patch.Apply(c, &request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.UpdatePost(id, &request, revision); err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			h.conflict(c, id)
+			return
+		}
+		apierror.Respond(c, err)
+		return
+	}
+	h.updated(c, id)
+}
+
+// updated answers a write with the post as stored, with its new ETag
+func (h *Handler) updated(c *gin.Context, id string) {
+	post, err := h.service.GetPost(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Written(c, post.Revision, post)
+}
+
+// conflict answers a stale write with the post as it is now
+func (h *Handler) conflict(c *gin.Context, id string) {
+	post, err := h.service.GetPost(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Conflict(c, post.Revision, post)
+}
+
 func (h *Handler) AddCategory(c *gin.Context) {
 	var request PostCategories
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.AddCategory(request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Category added successfully"})
@@ -124,11 +299,11 @@ func (h *Handler) AddCategory(c *gin.Context) {
 func (h *Handler) DeleteCategory(c *gin.Context) {
 	var request PostCategories
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.DeleteCategory(request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
@@ -137,11 +312,11 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 func (h *Handler) AddVersion(c *gin.Context) {
 	var request PostVersion
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.AddVersion(request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Version added successfully"})
@@ -150,12 +325,134 @@ func (h *Handler) AddVersion(c *gin.Context) {
 func (h *Handler) DeleteVersion(c *gin.Context) {
 	var request PostVersion
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.DeleteVersion(request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Version deleted successfully"})
 }
+
+// renderMode reads ?render=html|raw. Without it both the source content and
+// the rendered HTML are returned.
+func renderMode(c *gin.Context) (string, bool) {
+	mode := c.Query("render")
+	if mode != "" && mode != "html" && mode != "raw" {
+		apierror.Write(c, apierror.InvalidRender)
+		return "", false
+	}
+	return mode, true
+}
+
+func applyRender(mode string, post *Response) {
+	switch mode {
+	case "html":
+		post.Content = post.ContentHTML
+	case "raw":
+		post.ContentHTML = ""
+		post.TOC = nil
+	}
+}
+
+// currentUser returns the id of the authenticated editor, answering 401 when
+// the token carries none
+func currentUser(c *gin.Context) (string, bool) {
+	userId := middleware.GetUserID(c)
+	if userId == "" {
+		apierror.Write(c, apierror.AuthUnauthorized)
+		return "", false
+	}
+	return userId, true
+}
+
+// forceRelease reads the force flag of an unlock. Any editor may take over a
+// lock, which the previous holder sees on their next heartbeat, but only admins
+// may drop someone else's lock outright; anyone else gets a 403.
+func forceRelease(c *gin.Context) (bool, bool) {
+	force := c.Query("force") == "true"
+	if force && !middleware.IsAdmin(c) {
+		apierror.Write(c, apierror.AuthForbidden)
+		return false, false
+	}
+	return force, true
+}
+
+func (h *Handler) Lock(c *gin.Context) {
+	userId, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	force := c.Query("force") == "true"
+	lock, err := h.service.LockPost(c.Param("id"), userId, force)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrLocked):
+			apierror.Write(c, apierror.PostLocked, gin.H{"lock": lock})
+		default:
+			apierror.Respond(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, lock)
+}
+
+func (h *Handler) Unlock(c *gin.Context) {
+	userId, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	force, ok := forceRelease(c)
+	if !ok {
+		return
+	}
+	if err := h.service.UnlockPost(c.Param("id"), userId, force); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lock released successfully"})
+}
+
+func (h *Handler) GetAutosave(c *gin.Context) {
+	userId, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	autosave, err := h.service.GetAutosave(c.Param("id"), userId)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, autosave)
+}
+
+func (h *Handler) SaveAutosave(c *gin.Context) {
+	userId, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var request AutosaveRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	autosave, err := h.service.SaveAutosave(c.Param("id"), userId, &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, autosave)
+}
+
+func (h *Handler) DiscardAutosave(c *gin.Context) {
+	userId, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DiscardAutosave(c.Param("id"), userId); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Autosave discarded successfully"})
+}
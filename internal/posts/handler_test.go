@@ -0,0 +1,41 @@
+package posts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"havamal-api/internal/apierror"
+	"havamal-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestForceRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		admin     bool
+		wantForce bool
+		wantOk    bool
+	}{
+		{"own lock", "", false, false, true},
+		{"editor forcing", "force=true", false, false, false},
+		{"admin forcing", "force=true", true, true, true},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodDelete, "/?"+tt.query, nil)
+			c.Set("id", &middleware.AuthUser{ID: "editor", IsAdmin: tt.admin})
+			force, ok := forceRelease(c)
+			if force != tt.wantForce || ok != tt.wantOk {
+				t.Fatalf("forceRelease() = %v, %v, want %v, %v", force, ok, tt.wantForce, tt.wantOk)
+			}
+			if !ok && (len(c.Errors) != 1 || apierror.Lookup(c.Errors[0]) != apierror.AuthForbidden) {
+				t.Errorf("forceRelease() errors = %v, want %s", c.Errors, apierror.AuthForbidden)
+			}
+		})
+	}
+}
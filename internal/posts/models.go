@@ -1,6 +1,7 @@
 package posts
 
 import (
+	"havamal-api/internal/tags"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,17 +16,22 @@ const (
 )
 
 type Request struct {
-	Title       string    `json:"title"`
-	Slug        string    `json:"slug"`
-	Summary     string    `json:"summary"`
-	Content     string    `json:"content"`
-	Status      Status    `json:"status"`
+	Title       string    `json:"title" binding:"required,max=200"`
+	Slug        string    `json:"slug" binding:"omitempty,slug,max=200"`
+	Summary     string    `json:"summary" binding:"max=500"`
+	Content     string    `json:"content" binding:"required_without=Blocks"`
+	Status      Status    `json:"status" binding:"required,oneof=draft published archived"`
 	PublishedAt time.Time `json:"published_at"`	
 	UpdatedAt   time.Time `json:"updated_at"`
-	AuthorId 	string `json:"author_id"`
-	Author      string `json:"author"`
-	CategoryId  string `json:"categoryId"`
-	Columns int	`json:"columns"`
+	AuthorId 	string `json:"author_id" binding:"omitempty,uuid"`
+	Author      string `json:"author" binding:"omitempty,email"`
+	CategoryId  string `json:"categoryId" binding:"omitempty,uuid"`
+	Columns int	`json:"columns" binding:"omitempty,min=1,max=4"`
+	Format      Format `json:"format" binding:"omitempty,oneof=markdown html plaintext"`
+	Blocks      *Document `json:"blocks"`
+	Tags        []string `json:"tags" binding:"max=20,dive,required,max=50"`
+	Locale      string `json:"locale" binding:"omitempty,locale"`
+	TranslationOf string `json:"translation_of" binding:"omitempty,uuid"`
 }
 
 type Post struct {
@@ -39,6 +45,12 @@ type Post struct {
 	UpdatedAt 	time.Time `json:"updated_at"`
 	AuthorId 	uuid.UUID `json:"author_id"`
 	Columns int	`json:"columns"`
+	Format 		Format `json:"format"`
+	ContentHTML string `json:"content_html"`
+	Blocks 		*Document `json:"blocks"`
+	Revision int `json:"revision"`
+	Locale string `json:"locale"`
+	TranslationGroup uuid.UUID `json:"translation_group"`
 }
 
 type Response struct{
@@ -57,14 +69,109 @@ type Response struct{
 	CategorySlug string `json:"category_slug"`
 	AuthorName string `json:"author_name"`
 	Columns int	`json:"columns"`
+	Format Format `json:"format"`
+	ContentHTML string `json:"content_html,omitempty"`
+	ContentText string `json:"content_text,omitempty"`
+	TOC []TOCEntry `json:"toc,omitempty"`
+	Blocks *Document `json:"blocks,omitempty"`
+	Tags []tags.Tag `json:"tags,omitempty"`
+	Revision int `json:"revision"`
+	Lock *Lock `json:"lock,omitempty"`
+	Locale string `json:"locale"`
+	TranslationGroup uuid.UUID `json:"translation_group"`
+	Alternates []Alternate `json:"alternates,omitempty"`
+}
+
+// Alternate is a published translation of a post, for hreflang links. The
+// x-default alternate points at the translation in the default locale.
+type Alternate struct {
+	Hreflang string `json:"hreflang"`
+	Slug     string `json:"slug"`
+	Href     string `json:"href"`
+}
+
+// Author is the public part of the user who wrote a post, for ?include=author
+type Author struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+// change is what post events tell about a post: where it lives, and where it
+// lived when its slug changed
+type change struct {
+	ID               uuid.UUID `json:"id"`
+	Slug             string    `json:"slug"`
+	Path             string    `json:"path"`
+	PreviousSlug     string    `json:"previous_slug,omitempty"`
+	Status           Status    `json:"status"`
+	Locale           string    `json:"locale"`
+	TranslationGroup uuid.UUID `json:"translation_group"`
+}
+
+// request returns the post as the body of a full update, for patches to apply to
+func (r *Response) request() Request {
+	tagNames := make([]string, len(r.Tags))
+	for i, tag := range r.Tags {
+		tagNames[i] = tag.Name
+	}
+	request := Request{
+		Title:    r.Title,
+		Slug:     r.Slug,
+		Summary:  r.Summary,
+		Content:  r.Content,
+		Status:   r.Status,
+		AuthorId: r.AuthorId.String(),
+		Columns:  r.Columns,
+		Format:   r.Format,
+		Blocks:   r.Blocks,
+		Tags:     tagNames,
+		Locale:   r.Locale,
+	}
+	if r.CategoryId != uuid.Nil {
+		request.CategoryId = r.CategoryId.String()
+	}
+	return request
 }
 
 type PostCategories struct {
-	PostId 	uuid.UUID `json:"post_id"`
-	CategoryId 	uuid.UUID `json:"category_id"`
+	PostId 	uuid.UUID `json:"post_id" binding:"required"`
+	CategoryId 	uuid.UUID `json:"category_id" binding:"required"`
 }
 
 type PostVersion struct {	
-	VersionId 	uuid.UUID `json:"version"`
-	PostId 	uuid.UUID `json:"post_id"`
-}
\ No newline at end of file
+	VersionId 	uuid.UUID `json:"version" binding:"required"`
+	PostId 	uuid.UUID `json:"post_id" binding:"required"`
+}
+// Lock is a soft edit lock: it tells other editors who is working on a post
+// but does not block their writes
+type Lock struct {
+	PostId     uuid.UUID `json:"post_id"`
+	UserId     uuid.UUID `json:"user_id"`
+	Username   string    `json:"username"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type AutosaveRequest struct {
+	Title        string    `json:"title" binding:"max=200"`
+	Summary      string    `json:"summary" binding:"max=500"`
+	Content      string    `json:"content"`
+	Format       Format    `json:"format" binding:"omitempty,oneof=markdown html plaintext"`
+	Blocks       *Document `json:"blocks"`
+	BaseRevision int       `json:"base_revision" binding:"min=0"`
+}
+
+// Autosave is an editor's in-progress working copy of a post. Stale means the
+// live post has moved past the revision the copy started from.
+type Autosave struct {
+	PostId       uuid.UUID `json:"post_id"`
+	UserId       uuid.UUID `json:"user_id"`
+	Title        string    `json:"title"`
+	Summary      string    `json:"summary"`
+	Content      string    `json:"content"`
+	Format       Format    `json:"format"`
+	Blocks       *Document `json:"blocks"`
+	BaseRevision int       `json:"base_revision"`
+	SavedAt      time.Time `json:"saved_at"`
+	Stale        bool      `json:"stale"`
+}
@@ -0,0 +1,202 @@
+package posts
+
+import (
+	"bytes"
+	"havamal-api/internal/apierror"
+	gohtml "html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type Format string
+
+const (
+	FormatMarkdown  Format = "markdown"
+	FormatHTML      Format = "html"
+	FormatPlaintext Format = "plaintext"
+)
+
+var ErrInvalidFormat = apierror.New(apierror.PostInvalidFormat)
+
+// TOCEntry is a heading of the rendered content, linked by its anchor
+type TOCEntry struct {
+	Level  int    `json:"level"`
+	Anchor string `json:"anchor"`
+	Text   string `json:"text"`
+}
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		extension.Footnote,
+	),
+	// Raw HTML is kept here and cleaned by the sanitiser afterwards
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	// Code highlighting and footnote classes
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).OnElements("code", "pre", "div", "a", "span", "sup", "li", "p", "aside", "figure")
+	policy.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-[a-z]+$`)).OnElements("a", "div", "sup", "li")
+	// Task list checkboxes
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	// Embeds from the providers supported by embed blocks
+	policy.AllowAttrs("src").Matching(regexp.MustCompile(`^https://(www\.youtube-nocookie\.com/embed/|player\.vimeo\.com/video/|open\.spotify\.com/embed/)`)).OnElements("iframe")
+	policy.AllowAttrs("title", "loading", "allowfullscreen").OnElements("iframe")
+	return policy
+}
+
+// ValidFormat reports whether f is a known content format
+func ValidFormat(f Format) bool {
+	return f == FormatMarkdown || f == FormatHTML || f == FormatPlaintext
+}
+
+// Render turns content of the given format into sanitised HTML with an anchor
+// on every heading.
+func Render(format Format, content string) (string, error) {
+	var raw string
+	switch format {
+	case FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(content), &buf); err != nil {
+			return "", err
+		}
+		raw = buf.String()
+	case FormatHTML:
+		raw = content
+	case FormatPlaintext:
+		raw = plaintextToHTML(content)
+	default:
+		return "", ErrInvalidFormat
+	}
+	rendered, _, err := anchorHeadings(sanitizer.Sanitize(raw))
+	return rendered, err
+}
+
+// TableOfContents lists the headings of rendered HTML
+func TableOfContents(rendered string) []TOCEntry {
+	_, toc, err := anchorHeadings(rendered)
+	if err != nil {
+		return nil
+	}
+	return toc
+}
+
+// plaintextToHTML keeps the line structure of plain text, which matters for
+// verse: blank lines separate paragraphs and single newlines become breaks.
+func plaintextToHTML(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var b strings.Builder
+	for _, paragraph := range regexp.MustCompile(`\n{2,}`).Split(strings.TrimSpace(content), -1) {
+		if paragraph == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = gohtml.EscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return b.String()
+}
+
+// anchorHeadings gives every heading a unique id, keeping existing ones, and
+// collects them in document order.
+func anchorHeadings(fragment string) (string, []TOCEntry, error) {
+	context := &nethtml.Node{Type: nethtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := nethtml.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return "", nil, err
+	}
+
+	toc := make([]TOCEntry, 0)
+	used := make(map[string]int)
+	var walk func(n *nethtml.Node)
+	walk = func(n *nethtml.Node) {
+		if n.Type == nethtml.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6' {
+			text := strings.TrimSpace(nodeText(n))
+			id := ""
+			for _, attr := range n.Attr {
+				if attr.Key == "id" {
+					id = attr.Val
+				}
+			}
+			if id == "" {
+				id = uniqueAnchor(Anchor(text), used)
+				n.Attr = append(n.Attr, nethtml.Attribute{Key: "id", Val: id})
+			} else {
+				used[id]++
+			}
+			toc = append(toc, TOCEntry{Level: int(n.Data[1] - '0'), Anchor: id, Text: text})
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		walk(n)
+		if err := nethtml.Render(&b, n); err != nil {
+			return "", nil, err
+		}
+	}
+	return b.String(), toc, nil
+}
+
+func nodeText(n *nethtml.Node) string {
+	if n.Type == nethtml.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
+
+// Anchor derives a URL fragment from heading text
+func Anchor(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	anchor := strings.TrimSuffix(b.String(), "-")
+	if anchor == "" {
+		anchor = "section"
+	}
+	return anchor
+}
+
+func uniqueAnchor(anchor string, used map[string]int) string {
+	candidate := anchor
+	for n := 1; used[candidate] > 0; n++ {
+		candidate = anchor + "-" + strconv.Itoa(n)
+	}
+	used[candidate]++
+	return candidate
+}
@@ -0,0 +1,108 @@
+package posts
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderSanitises(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		content string
+		want    []string
+		refused []string
+	}{
+		{"script in html", FormatHTML, `<p>Hi</p><script>alert(1)</script>`, []string{"<p>Hi</p>"}, []string{"<script", "alert(1)"}},
+		{"event handler", FormatHTML, `<img src="/a.png" onerror="alert(1)">`, []string{`src="/a.png"`}, []string{"onerror"}},
+		{"javascript link", FormatHTML, `<a href="javascript:alert(1)">x</a>`, []string{"x"}, []string{"javascript:"}},
+		{"style element", FormatHTML, `<style>body{display:none}</style><p>x</p>`, []string{"<p>x</p>"}, []string{"<style", "display:none"}},
+		{"raw html in markdown", FormatMarkdown, "# Title\n\n<iframe src=\"https://evil.example/\"></iframe>", []string{"Title</h1>"}, []string{"evil.example"}},
+		{"supported embed", FormatHTML, `<iframe src="https://player.vimeo.com/video/1"></iframe>`, []string{`src="https://player.vimeo.com/video/1"`}, nil},
+		{"task list", FormatMarkdown, "- [x] done", []string{`type="checkbox"`, "checked"}, nil},
+		{"foreign class", FormatHTML, `<p class="a;b">x</p>`, []string{"<p>x</p>"}, []string{"class="}},
+		{"plaintext is escaped", FormatPlaintext, "<b>bold</b>", []string{"&lt;b&gt;bold&lt;/b&gt;"}, []string{"<b>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.format, tt.content)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Render() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, refused := range tt.refused {
+				if strings.Contains(got, refused) {
+					t.Errorf("Render() = %q, want it without %q", got, refused)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		content string
+		want    string
+		wantErr error
+	}{
+		{"markdown", FormatMarkdown, "Some *text*", "<p>Some <em>text</em></p>\n", nil},
+		{"verse", FormatPlaintext, "line one\nline two\n\n\nnext", "<p>line one<br/>\nline two</p>\n<p>next</p>\n", nil},
+		{"headings get anchors", FormatHTML, "<h2>Què és?</h2><h2>Què és?</h2>", `<h2 id="què-és">Què és?</h2><h2 id="què-és-1">Què és?</h2>`, nil},
+		{"existing ids are kept", FormatHTML, `<h2 id="intro">Intro</h2>`, `<h2 id="intro">Intro</h2>`, nil},
+		{"unknown format", Format("rtf"), "x", "", ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.format, tt.content)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Render() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	rendered, err := Render(FormatMarkdown, "# Hávamál\n\n## Gestaþáttr\n\n### 1\n\n## Gestaþáttr")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := []TOCEntry{
+		{Level: 1, Anchor: "hávamál", Text: "Hávamál"},
+		{Level: 2, Anchor: "gestaþáttr", Text: "Gestaþáttr"},
+		{Level: 3, Anchor: "1", Text: "1"},
+		{Level: 2, Anchor: "gestaþáttr-1", Text: "Gestaþáttr"},
+	}
+	if got := TableOfContents(rendered); !reflect.DeepEqual(got, want) {
+		t.Errorf("TableOfContents() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Spaced   out  ", "spaced-out"},
+		{"Rúnatal", "rúnatal"},
+		{"!!!", "section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Anchor(tt.text); got != tt.want {
+				t.Errorf("Anchor(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,24 +2,47 @@ package posts
 
 import (
 	"database/sql"
+	"havamal-api/internal/categories"
+	"havamal-api/internal/db"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/versions"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Repository interface {
-	CreatePost(post *Post) error
+	CreatePost(post *Post, steps ...db.Step) error
 	GetPost(id uuid.UUID) (*Response, error)	
-	GetPosts() ([]Response, error)
-	GetPublishedPosts() ([]Response, error) 
-	GetPostsByAuthor(authorId uuid.UUID) ([]Response, error)
+	GetPosts(selection fields.Selection) ([]Response, error)
+	GetPublishedPosts(selection fields.Selection) ([]Response, error)
+	GetPublishedEntries() ([]Response, error)
+	GetPublishedByTag(tagId uuid.UUID, selection fields.Selection) ([]Response, error)
+	GetPostsByAuthor(authorId uuid.UUID, selection fields.Selection) ([]Response, error)
 	GetPostBySlug(slug string) (*Response, error)
-	GetSummariesByCategory(category string) ([]Response, error)
-	UpdatePost(post *Post) error
-	DeletePost(id uuid.UUID) error
+	SlugTaken(slug string, excludeId uuid.UUID) (bool, error)
+	GetRedirect(oldSlug string) (string, error)
+	TranslationTaken(group uuid.UUID, locale string, excludeId uuid.UUID) (bool, error)
+	GetTranslations(group uuid.UUID) ([]Alternate, error)
+	GetSummariesByCategory(category string, includeDescendants bool, selection fields.Selection) ([]Response, error)
+	GetAuthors(ids []uuid.UUID) (map[uuid.UUID]Author, error)
+	GetCategoriesByPosts(postIds []uuid.UUID, locale string) (map[uuid.UUID][]categories.Category, error)
+	GetVersionsByPosts(postIds []uuid.UUID) (map[uuid.UUID][]versions.Version, error)
+	UpdatePost(post *Post, steps ...db.Step) error
+	DeletePost(id uuid.UUID, revision int, steps ...db.Step) error
 	AddCategory(request PostCategories) error
 	DeleteCategory(request PostCategories) error
 	AddVersion(request PostVersion) error
 	DeleteVersion(request PostVersion) error
+	AcquireLock(postId uuid.UUID, userId uuid.UUID, expiresAt time.Time, force bool) (*Lock, error)
+	GetLock(postId uuid.UUID) (*Lock, error)
+	GetActiveLocks() (map[uuid.UUID]Lock, error)
+	ReleaseLock(postId uuid.UUID, userId uuid.UUID, force bool) error
+	SaveAutosave(autosave *Autosave) error
+	GetAutosave(postId uuid.UUID, userId uuid.UUID) (*Autosave, error)
+	DeleteAutosave(postId uuid.UUID, userId uuid.UUID) error
 }
 
 type repository struct {
@@ -30,44 +53,81 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) CreatePost(post *Post) error {
-	query := `INSERT INTO posts (id, title, slug, summary, content, status, published_at, updated_at, author_id, columns)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`	
-	_, err := r.db.Exec(query, post.ID, post.Title, post.Slug, post.Summary, post.Content, post.Status, post.PublishedAt, post.UpdatedAt, post.AuthorId, post.Columns)
+// CreatePost inserts a post and runs steps in the same transaction
+func (r *repository) CreatePost(post *Post, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
-}
+	defer tx.Rollback()
 
-func (r *repository) GetPost(id uuid.UUID) (*Response, error) {
-	query := `SELECT p.id, p.title, p.slug, p.summary, p.content, p.status, p.published_at, 
-					p.updated_at, p.author_id, pc.category_id, c.name as category_name, c.description as category_description, 
-					c.slug as category_slug, u.username as author_name, p.columns
-	FROM posts p
-		INNER JOIN post_categories pc ON p.Id = pc.post_id
-		INNER JOIN categories c ON pc.category_id = c.id
-		INNER JOIN users u ON p.author_id = u.id
-	WHERE p.id = $1`
-	row := r.db.QueryRow(query, id)
-	var post Response
-	if err := row.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt, 
-						&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription, 
-						&post.CategorySlug, &post.AuthorName, &post.Columns); err != nil {
-		return nil, err
+	query := `INSERT INTO posts (id, title, slug, summary, content, status, published_at, updated_at, author_id, columns, format, content_html, blocks, locale, translation_group)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`	
+	if _, err := tx.Exec(query, post.ID, post.Title, post.Slug, post.Summary, post.Content, post.Status, post.PublishedAt, post.UpdatedAt, post.AuthorId, post.Columns, post.Format, post.ContentHTML, post.Blocks, post.Locale, post.TranslationGroup); err != nil {
+		return translate(err)
 	}
-	return &post, nil
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *repository) GetPosts() ([]Response, error) {
-	query := `SELECT p.id, p.title, p.slug, p.summary, p.content, p.status, p.published_at, 
-					p.updated_at, p.author_id, pc.category_id, c.name as category_name, c.description as category_description, 
-					c.slug as category_slug, u.username as author_name, p.columns
-	FROM posts p
+// Fields are the members of a post, in the order scanPost reads them. Text
+// and blocks are left out of the query unless asked for. content_html is
+// rendered from content when it was never stored, and ?render=html answers it
+// as content, so each needs the other.
+var Fields = fields.NewSet(
+	fields.Column{Name: "id", SQL: "p.id"},
+	fields.Column{Name: "title", SQL: "p.title", Empty: "''"},
+	fields.Column{Name: "slug", SQL: "p.slug", Empty: "''"},
+	fields.Column{Name: "summary", SQL: "p.summary", Empty: "''"},
+	fields.Column{Name: "content", SQL: "p.content", Empty: "''", Needs: []string{"content_html"}},
+	fields.Column{Name: "status", SQL: "p.status"},
+	fields.Column{Name: "published_at", SQL: "p.published_at"},
+	fields.Column{Name: "updated_at", SQL: "p.updated_at"},
+	fields.Column{Name: "author_id", SQL: "p.author_id"},
+	fields.Column{Name: "category_id", SQL: "pc.category_id"},
+	fields.Column{Name: "category_name", SQL: "COALESCE(ct.name, c.name)", Empty: "''"},
+	fields.Column{Name: "category_description", SQL: "COALESCE(NULLIF(ct.description, ''), c.description)", Empty: "''"},
+	fields.Column{Name: "category_slug", SQL: "c.slug", Empty: "''"},
+	fields.Column{Name: "author_name", SQL: "u.username", Empty: "''"},
+	fields.Column{Name: "columns", SQL: "p.columns"},
+	fields.Column{Name: "format", SQL: "p.format"},
+	fields.Column{Name: "content_html", SQL: "COALESCE(p.content_html, '')", Empty: "''", Needs: []string{"content", "format"}},
+	fields.Column{Name: "blocks", SQL: "p.blocks", Empty: "NULL"},
+	fields.Column{Name: "revision", SQL: "p.revision"},
+	fields.Column{Name: "locale", SQL: "p.locale"},
+	fields.Column{Name: "translation_group", SQL: "p.translation_group"},
+	fields.Column{Name: "lock"},
+)
+
+// postsFrom joins a post with each of its categories, in the post's locale,
+// and its author
+const postsFrom = `FROM posts p
 		INNER JOIN post_categories pc ON p.Id = pc.post_id
 		INNER JOIN categories c ON pc.category_id = c.id
+		LEFT JOIN category_translations ct ON ct.category_id = c.id AND ct.locale = p.locale
 		INNER JOIN users u ON p.author_id = u.id`
-	rows, err := r.db.Query(query)
+
+// translate returns the domain error of a failed post query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row scanner, post *Response) error {
+	return row.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt,
+		&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription,
+		&post.CategorySlug, &post.AuthorName, &post.Columns, &post.Format, &post.ContentHTML, blocksScanner{&post.Blocks}, &post.Revision, &post.Locale, &post.TranslationGroup)
+}
+
+// queryPosts lists the posts of a query selecting Fields, one row per post
+// and category
+func (r *repository) queryPosts(query string, args ...any) ([]Response, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -75,9 +135,7 @@ func (r *repository) GetPosts() ([]Response, error) {
 	var posts []Response
 	for rows.Next() {
 		var post Response
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt, 
-							&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription, 
-							&post.CategorySlug, &post.AuthorName, &post.Columns); err != nil {
+		if err := scanPost(rows, &post); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -85,15 +143,38 @@ func (r *repository) GetPosts() ([]Response, error) {
 	return posts, nil
 }
 
-func (r *repository) GetPublishedPosts() ([]Response, error) {
-	query := `SELECT p.id, p.title, p.slug, p.summary, p.content, p.status, p.published_at, 
-					p.updated_at, p.author_id, pc.category_id, c.name as category_name, c.description as category_description, 
-					c.slug as category_slug, u.username as author_name, p.columns
-	FROM posts p
-		INNER JOIN post_categories pc ON p.Id = pc.post_id
-		INNER JOIN categories c ON pc.category_id = c.id
-		INNER JOIN users u ON p.author_id = u.id
+func (r *repository) GetPost(id uuid.UUID) (*Response, error) {
+	query := `SELECT ` + Fields.All().SQL() + `
+	` + postsFrom + `
+	WHERE p.id = $1`
+	var post Response
+	if err := scanPost(r.db.QueryRow(query, id), &post); err != nil {
+		return nil, translate(err)
+	}
+	return &post, nil
+}
+
+func (r *repository) GetPosts(selection fields.Selection) ([]Response, error) {
+	query := `SELECT ` + selection.SQL() + `
+	` + postsFrom
+	return r.queryPosts(query)
+}
+
+func (r *repository) GetPublishedPosts(selection fields.Selection) ([]Response, error) {
+	query := `SELECT ` + selection.SQL() + `
+	` + postsFrom + `
 	WHERE p.status = 'published'`
+	return r.queryPosts(query)
+}
+
+// GetPublishedEntries lists every published post once, whether or not it is
+// filed under a category, with only what locates it: id, slug, locale,
+// translation group and update time
+func (r *repository) GetPublishedEntries() ([]Response, error) {
+	query := `SELECT p.id, p.slug, p.locale, p.translation_group, p.updated_at
+	FROM posts p
+	WHERE p.status = 'published'
+	ORDER BY p.published_at DESC`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -102,9 +183,7 @@ func (r *repository) GetPublishedPosts() ([]Response, error) {
 	var posts []Response
 	for rows.Next() {
 		var post Response
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt, 
-							&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription, 
-							&post.CategorySlug, &post.AuthorName, &post.Columns); err != nil {
+		if err := rows.Scan(&post.ID, &post.Slug, &post.Locale, &post.TranslationGroup, &post.UpdatedAt); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -112,136 +191,392 @@ func (r *repository) GetPublishedPosts() ([]Response, error) {
 	return posts, nil
 }
 
-func (r *repository) GetPostsByAuthor(authorId uuid.UUID) ([]Response, error) {
-	query := `SELECT p.id, p.title, p.slug, p.summary, p.content, p.status, p.published_at, 
-					p.updated_at, p.author_id, pc.category_id, c.name as category_name, c.description as category_description, c.slug as category_slug,
-					u.username, p.columns
-	FROM posts p
-		INNER JOIN post_categories pc ON p.Id = pc.post_id
-		INNER JOIN categories c ON pc.category_id = c.id
-		INNER JOIN users u ON p.author_id = u.id
+func (r *repository) GetPublishedByTag(tagId uuid.UUID, selection fields.Selection) ([]Response, error) {
+	query := `SELECT ` + selection.SQL() + `
+	` + postsFrom + `
+		INNER JOIN post_tags pt ON p.id = pt.post_id
+	WHERE p.status = 'published' AND pt.tag_id = $1`
+	return r.queryPosts(query, tagId)
+}
+
+func (r *repository) GetPostsByAuthor(authorId uuid.UUID, selection fields.Selection) ([]Response, error) {
+	query := `SELECT ` + selection.SQL() + `
+	` + postsFrom + `
 	WHERE p.author_id = $1`
-	rows, err := r.db.Query(query, authorId)
+	return r.queryPosts(query, authorId)
+}
+
+func (r *repository) GetPostBySlug(slug string) (*Response, error) {
+	query := `SELECT ` + Fields.All().SQL() + `
+	` + postsFrom + `
+	WHERE p.slug = $1`
+	var post Response
+	if err := scanPost(r.db.QueryRow(query, slug), &post); err != nil {
+		return nil, translate(err)
+	}
+	return &post, nil
+}
+
+func (r *repository) GetSummariesByCategory(category string, includeDescendants bool, selection fields.Selection) ([]Response, error) {
+	// The category argument may be either the category id or its slug.
+	// With includeDescendants, posts of every sub-category are included too.
+	where := `WHERE c.id::text = $1 OR c.slug = $1`
+	if includeDescendants {
+		where = `WHERE c.id IN (
+		WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id::text = $1 OR slug = $1
+			UNION
+			SELECT child.id FROM categories child
+				INNER JOIN tree t ON child.parent_id = t.id
+		)
+		SELECT id FROM tree)`
+	}
+	query := `SELECT ` + selection.SQL() + `
+	` + postsFrom + `
+	` + where
+	rows, err := r.queryPosts(query, category)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	// A post filed under several categories of the tree is listed once
 	var posts []Response
-	for rows.Next() {
-		var post Response
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt, 
-							&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription, &post.CategorySlug,
-							&post.AuthorName, &post.Columns); err != nil {
-			return nil, err
+	seen := make(map[uuid.UUID]bool)
+	for _, post := range rows {
+		if seen[post.ID] {
+			continue
 		}
+		seen[post.ID] = true
 		posts = append(posts, post)
 	}
 	return posts, nil
 }
 
-func (r *repository) GetPostBySlug(slug string) (*Response, error) {
-	query := `SELECT p.id, p.title, p.slug, p.summary, p.content, p.status, p.published_at, 
-					p.updated_at, p.author_id, pc.category_id, c.name as category_name, c.description as category_description, c.slug as category_slug,
-					u.username, p.columns
-	FROM posts p
-		INNER JOIN post_categories pc ON p.Id = pc.post_id
-		INNER JOIN categories c ON pc.category_id = c.id
-		INNER JOIN users u ON p.author_id = u.id
-	WHERE p.slug = $1`
-	row := r.db.QueryRow(query, slug)
-	var post Response
-	if err := row.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt, 
-						&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription, &post.CategorySlug,
-						&post.AuthorName, &post.Columns); err != nil {
+// GetAuthors returns the public part of the users given, by id
+func (r *repository) GetAuthors(ids []uuid.UUID) (map[uuid.UUID]Author, error) {
+	query := `SELECT id, username FROM users WHERE id::text = ANY($1)`
+	rows, err := r.db.Query(query, pq.Array(uuidStrings(ids)))
+	if err != nil {
 		return nil, err
 	}
-	return &post, nil
+	defer rows.Close()
+	authors := make(map[uuid.UUID]Author, len(ids))
+	for rows.Next() {
+		var author Author
+		if err := rows.Scan(&author.ID, &author.Username); err != nil {
+			return nil, err
+		}
+		authors[author.ID] = author
+	}
+	return authors, nil
 }
 
-func (r *repository) GetSummariesByCategory(category string) ([]Response, error) {
-	// Assuming category argument is likely a UUID string or slug. 
-    // Try to match id first, but since the arg is string, standard implementation usually expects ID or uses separate queries.
-    // Given the previous broken query 'WHERE category = $1', assuming ID matching on join for now.
-	query := `SELECT p.id, p.title, p.slug, p.summary, p.content, p.status, p.published_at, 
-					p.updated_at, p.author_id, pc.category_id, c.name as category_name, c.description as category_description, c.slug as category_slug,
-					u.username, p.columns
-	FROM posts p
-		INNER JOIN post_categories pc ON p.Id = pc.post_id
+// GetCategoriesByPosts returns the categories of several posts, named in
+// locale, by post id
+func (r *repository) GetCategoriesByPosts(postIds []uuid.UUID, locale string) (map[uuid.UUID][]categories.Category, error) {
+	query := `SELECT pc.post_id, c.id, COALESCE(ct.name, c.name), c.slug, COALESCE(NULLIF(ct.description, ''), c.description),
+		c."order", c.parent_id, c.created_at, c.updated_at, c.revision
+	FROM post_categories pc
 		INNER JOIN categories c ON pc.category_id = c.id
-		INNER JOIN users u ON p.author_id = u.id
-	WHERE c.id::text = $1 OR c.slug = $1` 
-	rows, err := r.db.Query(query, category)
+		LEFT JOIN category_translations ct ON ct.category_id = c.id AND ct.locale = $2
+	WHERE pc.post_id::text = ANY($1)
+	ORDER BY c."order", c.name`
+	rows, err := r.db.Query(query, pq.Array(uuidStrings(postIds)), locale)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var posts []Response
+	filed := make(map[uuid.UUID][]categories.Category)
 	for rows.Next() {
-		var post Response
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Summary, &post.Content, &post.Status, &post.PublishedAt, 
-							&post.UpdatedAt, &post.AuthorId, &post.CategoryId, &post.CategoryName, &post.CategoryDescription, &post.CategorySlug,
-							&post.AuthorName, &post.Columns); err != nil {
+		var postId uuid.UUID
+		var category categories.Category
+		var parentID uuid.NullUUID
+		if err := rows.Scan(&postId, &category.ID, &category.Name, &category.Slug, &category.Description,
+			&category.Order, &parentID, &category.CreatedAt, &category.UpdatedAt, &category.Revision); err != nil {
 			return nil, err
 		}
-		posts = append(posts, post)
+		if parentID.Valid {
+			category.ParentId = &parentID.UUID
+		}
+		filed[postId] = append(filed[postId], category)
 	}
-	return posts, nil
+	return filed, nil
+}
+
+// GetVersionsByPosts returns the versions attached to several posts, by post id
+func (r *repository) GetVersionsByPosts(postIds []uuid.UUID) (map[uuid.UUID][]versions.Version, error) {
+	query := `SELECT pv.post_id, v.id, v.version, v.post_id, v.version_number, v.content, v.format, v.created_at, v.revision
+	FROM post_versions pv
+		INNER JOIN versions v ON pv.version_id = v.id
+	WHERE pv.post_id::text = ANY($1)
+	ORDER BY v.version_number`
+	rows, err := r.db.Query(query, pq.Array(uuidStrings(postIds)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attached := make(map[uuid.UUID][]versions.Version)
+	for rows.Next() {
+		var postId uuid.UUID
+		var version versions.Version
+		if err := rows.Scan(&postId, &version.ID, &version.Version, &version.PostId, &version.VersionNumber, &version.Content, &version.Format, &version.CreatedAt, &version.Revision); err != nil {
+			return nil, err
+		}
+		attached[postId] = append(attached[postId], version)
+	}
+	return attached, nil
 }
 
-func (r *repository) UpdatePost(post *Post) error {
+func uuidStrings(ids []uuid.UUID) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	return keys
+}
+
+// UpdatePost only applies when post.Revision is still the current revision or
+// etag.Any, and keeps the previous slug as a redirect when it changes. Steps
+// run in the same transaction.
+func (r *repository) UpdatePost(post *Post, steps ...db.Step) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	redirect := `INSERT INTO slug_redirects (entity_type, old_slug, entity_id)
+	SELECT 'post', slug, id FROM posts WHERE id = $1 AND slug <> $2
+	ON CONFLICT (entity_type, old_slug) DO UPDATE SET entity_id = EXCLUDED.entity_id, created_at = NOW()`
+	if _, err := tx.Exec(redirect, post.ID, post.Slug); err != nil {
+		return translate(err)
+	}
+	// A post taking back one of its old slugs no longer needs the redirect
+	if _, err := tx.Exec(`DELETE FROM slug_redirects WHERE entity_type = 'post' AND old_slug = $1`, post.Slug); err != nil {
+		return err
+	}
+
 	query := `UPDATE posts
-	SET title = $2, slug = $3, summary = $4, content = $5, status = $6, published_at = $7, updated_at = $8, author_id = $9, columns = $10
-	WHERE id = $1`
-	_, err := r.db.Exec(query, post.ID, post.Title, post.Slug, post.Summary, post.Content, post.Status, post.PublishedAt, post.UpdatedAt, post.AuthorId, post.Columns)
+	SET title = $2, slug = $3, summary = $4, content = $5, status = $6, published_at = $7, updated_at = $8, author_id = $9, columns = $10,
+		format = $11, content_html = $12, blocks = $13, locale = $15, translation_group = $16, revision = revision + 1
+	WHERE id = $1 AND ($14 = 0 OR revision = $14)`
+	result, err := tx.Exec(query, post.ID, post.Title, post.Slug, post.Summary, post.Content, post.Status, post.PublishedAt, post.UpdatedAt, post.AuthorId, post.Columns, post.Format, post.ContentHTML, post.Blocks, post.Revision, post.Locale, post.TranslationGroup)
 	if err != nil {
+		return translate(err)
+	}
+	if err := etag.Check(result, tx, "posts", post.ID); err != nil {
+		return translate(err)
+	}
+	if err := db.Run(tx, steps...); err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
-func (r *repository) DeletePost(id uuid.UUID) error {
-	query := `DELETE FROM posts WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+// SlugTaken reports whether another post uses slug, either as its current slug
+// or as a previous one that still redirects to it
+func (r *repository) SlugTaken(slug string, excludeId uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM posts WHERE slug = $1 AND id <> $2)
+		OR EXISTS (SELECT 1 FROM slug_redirects WHERE entity_type = 'post' AND old_slug = $1 AND entity_id <> $2)`
+	var taken bool
+	err := r.db.QueryRow(query, slug, excludeId).Scan(&taken)
+	return taken, err
+}
+
+// TranslationTaken reports whether another post of the translation group is
+// already written in locale
+func (r *repository) TranslationTaken(group uuid.UUID, locale string, excludeId uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM posts WHERE translation_group = $1 AND locale = $2 AND id <> $3)`
+	var taken bool
+	err := r.db.QueryRow(query, group, locale, excludeId).Scan(&taken)
+	return taken, err
+}
+
+// GetTranslations returns the locale and slug of every published post of a
+// translation group
+func (r *repository) GetTranslations(group uuid.UUID) ([]Alternate, error) {
+	query := `SELECT locale, slug FROM posts WHERE translation_group = $1 AND status = 'published' ORDER BY locale`
+	rows, err := r.db.Query(query, group)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	alternates := make([]Alternate, 0)
+	for rows.Next() {
+		var alternate Alternate
+		if err := rows.Scan(&alternate.Hreflang, &alternate.Slug); err != nil {
+			return nil, err
+		}
+		alternates = append(alternates, alternate)
+	}
+	return alternates, nil
+}
+
+// GetRedirect returns the current slug of the post that used to be oldSlug
+func (r *repository) GetRedirect(oldSlug string) (string, error) {
+	query := `SELECT p.slug FROM slug_redirects sr
+		INNER JOIN posts p ON sr.entity_id = p.id
+	WHERE sr.entity_type = 'post' AND sr.old_slug = $1`
+	var slug string
+	err := r.db.QueryRow(query, oldSlug).Scan(&slug)
+	return slug, translate(err)
+}
+
+// DeletePost removes a post at its revision together with the redirects from
+// its old slugs, which would otherwise keep those slugs taken. Steps run in
+// the same transaction.
+func (r *repository) DeletePost(id uuid.UUID, revision int, steps ...db.Step) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `DELETE FROM posts WHERE id = $1 AND ($2 = 0 OR revision = $2)`
+	result, err := tx.Exec(query, id, revision)
+	if err != nil {
+		return err
+	}
+	if err := etag.Check(result, tx, "posts", id); err != nil {
+		return translate(err)
+	}
+	if _, err := tx.Exec(`DELETE FROM slug_redirects WHERE entity_type = 'post' AND entity_id = $1`, id); err != nil {
+		return err
+	}
+	if err := db.Run(tx, steps...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *repository) AddCategory(request PostCategories) error {
 	query := `INSERT INTO post_categories (post_id, category_id)
 	VALUES ($1, $2)`
 	_, err := r.db.Exec(query, request.PostId, request.CategoryId)
-	if err != nil {
-		return err
-	}
-	return nil
+	return translate(err)
 }
 
 func (r *repository) DeleteCategory(request PostCategories) error {
 	query := `DELETE FROM post_categories WHERE post_id = $1 AND category_id = $2`
 	_, err := r.db.Exec(query, request.PostId, request.CategoryId)
-	if err != nil {
-		return err
-	}
-	return nil
+	return translate(err)
 }
 
 func (r *repository) AddVersion(request PostVersion) error {
 	query := `INSERT INTO post_versions (version_id, post_id)
 	VALUES ($1, $2)`
 	_, err := r.db.Exec(query, request.VersionId, request.PostId)
-	if err != nil {
-		return err
-	}
-	return nil
+	return translate(err)
 }
 
 func (r *repository) DeleteVersion(request PostVersion) error {
 	query := `DELETE FROM post_versions WHERE version_id = $1 AND post_id = $2`
 	_, err := r.db.Exec(query, request.VersionId, request.PostId)
+	return translate(err)
+}
+
+const lockColumns = `l.post_id, l.user_id, u.username, l.acquired_at, l.expires_at`
+
+// AcquireLock takes the lock on a post, or renews it for its holder. An expired
+// lock is free to take, a live one only with force. When another editor holds
+// it, their lock is returned along with ErrLocked.
+func (r *repository) AcquireLock(postId uuid.UUID, userId uuid.UUID, expiresAt time.Time, force bool) (*Lock, error) {
+	query := `INSERT INTO post_locks (post_id, user_id, acquired_at, expires_at)
+	VALUES ($1, $2, NOW(), $3)
+	ON CONFLICT (post_id) DO UPDATE SET
+		user_id = EXCLUDED.user_id,
+		acquired_at = CASE WHEN post_locks.user_id = EXCLUDED.user_id THEN post_locks.acquired_at ELSE EXCLUDED.acquired_at END,
+		expires_at = EXCLUDED.expires_at
+	WHERE post_locks.user_id = EXCLUDED.user_id OR post_locks.expires_at <= NOW() OR $4`
+	result, err := r.db.Exec(query, postId, userId, expiresAt, force)
+	if err != nil {
+		return nil, translate(err)
+	}
+	lock, err := r.GetLock(postId)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return lock, ErrLocked
+	}
+	return lock, nil
+}
+
+// GetLock returns the live lock on a post, or ErrNoLock
+func (r *repository) GetLock(postId uuid.UUID) (*Lock, error) {
+	query := `SELECT ` + lockColumns + `
+	FROM post_locks l
+		INNER JOIN users u ON l.user_id = u.id
+	WHERE l.post_id = $1 AND l.expires_at > NOW()`
+	var lock Lock
+	if err := r.db.QueryRow(query, postId).Scan(&lock.PostId, &lock.UserId, &lock.Username, &lock.AcquiredAt, &lock.ExpiresAt); err != nil {
+		return nil, db.Translate(err, ErrNoLock, nil)
+	}
+	return &lock, nil
+}
+
+func (r *repository) GetActiveLocks() (map[uuid.UUID]Lock, error) {
+	query := `SELECT ` + lockColumns + `
+	FROM post_locks l
+		INNER JOIN users u ON l.user_id = u.id
+	WHERE l.expires_at > NOW()`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	locks := make(map[uuid.UUID]Lock)
+	for rows.Next() {
+		var lock Lock
+		if err := rows.Scan(&lock.PostId, &lock.UserId, &lock.Username, &lock.AcquiredAt, &lock.ExpiresAt); err != nil {
+			return nil, err
+		}
+		locks[lock.PostId] = lock
+	}
+	return locks, nil
+}
+
+// ReleaseLock drops the caller's lock. Someone else's live lock is only
+// dropped with force; releasing a lock nobody holds is not an error.
+func (r *repository) ReleaseLock(postId uuid.UUID, userId uuid.UUID, force bool) error {
+	query := `DELETE FROM post_locks WHERE post_id = $1 AND (user_id = $2 OR expires_at <= NOW() OR $3)`
+	result, err := r.db.Exec(query, postId, userId, force)
 	if err != nil {
 		return err
 	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if _, err := r.GetLock(postId); err == nil {
+			return ErrLocked
+		}
+	}
 	return nil
 }
+
+func (r *repository) SaveAutosave(autosave *Autosave) error {
+	query := `INSERT INTO post_autosaves (post_id, user_id, title, summary, content, format, blocks, base_revision, saved_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (post_id, user_id) DO UPDATE SET
+		title = EXCLUDED.title, summary = EXCLUDED.summary, content = EXCLUDED.content, format = EXCLUDED.format,
+		blocks = EXCLUDED.blocks, base_revision = EXCLUDED.base_revision, saved_at = EXCLUDED.saved_at`
+	_, err := r.db.Exec(query, autosave.PostId, autosave.UserId, autosave.Title, autosave.Summary, autosave.Content, autosave.Format, autosave.Blocks, autosave.BaseRevision, autosave.SavedAt)
+	return translate(err)
+}
+
+func (r *repository) GetAutosave(postId uuid.UUID, userId uuid.UUID) (*Autosave, error) {
+	query := `SELECT a.post_id, a.user_id, a.title, a.summary, a.content, a.format, a.blocks, a.base_revision, a.saved_at,
+		a.base_revision <> p.revision
+	FROM post_autosaves a
+		INNER JOIN posts p ON a.post_id = p.id
+	WHERE a.post_id = $1 AND a.user_id = $2`
+	var autosave Autosave
+	if err := r.db.QueryRow(query, postId, userId).Scan(&autosave.PostId, &autosave.UserId, &autosave.Title, &autosave.Summary, &autosave.Content,
+		&autosave.Format, blocksScanner{&autosave.Blocks}, &autosave.BaseRevision, &autosave.SavedAt, &autosave.Stale); err != nil {
+		return nil, db.Translate(err, ErrAutosaveNotFound, nil)
+	}
+	return &autosave, nil
+}
+
+func (r *repository) DeleteAutosave(postId uuid.UUID, userId uuid.UUID) error {
+	query := `DELETE FROM post_autosaves WHERE post_id = $1 AND user_id = $2`
+	_, err := r.db.Exec(query, postId, userId)
+	return err
+}
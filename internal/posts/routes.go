@@ -1,17 +1,28 @@
 package posts
 
-import "github.com/gin-gonic/gin"
+import (
+	"havamal-api/internal/fields"
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/posts", handler.Create)
 	router.GET("/posts/:id", handler.GetPost)
 	router.GET("/posts", handler.GetPosts)
 	router.PUT("/posts/:id", handler.UpdatePost)
+	router.PATCH("/posts/:id", handler.PatchPost)
 	router.DELETE("/posts/:id", handler.DeletePost)
 	router.POST("/posts/category", handler.AddCategory)
 	router.DELETE("/posts/category", handler.DeleteCategory)
 	router.POST("/posts/version", handler.AddVersion)
 	router.DELETE("/posts/version", handler.DeleteVersion)
+	router.POST("/posts/:id/lock", handler.Lock)
+	router.DELETE("/posts/:id/lock", handler.Unlock)
+	router.GET("/posts/:id/autosave", handler.GetAutosave)
+	router.PUT("/posts/:id/autosave", handler.SaveAutosave)
+	router.DELETE("/posts/:id/autosave", handler.DiscardAutosave)
 }
 
 func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
@@ -19,4 +30,54 @@ func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.GET("/slug/:slug", handler.GetPostBySlug)
 	router.GET("/category/:category", handler.GetSummariesByCategory)
 	router.GET("/posts/published", handler.GetPublishedPosts)
-}
\ No newline at end of file
+	router.GET("/tags/:slug", handler.GetPostsByTag)
+}
+
+// render selects the content representation of the post reads
+var render = openapi.Enum("render", "html replaces content with its HTML, raw leaves the HTML out; both by default", "html", "raw")
+
+// listQuery adds ?fields= and ?include= to the parameters of a list of posts
+func listQuery(params ...openapi.Param) []openapi.Param {
+	return append(params, fields.Params(Fields, "author", "categories", "tags", "versions")...)
+}
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/posts", Tag: "posts", Summary: "Create a post", Body: Request{},
+			Response: struct {
+				Message string `json:"message"`
+				Slug    string `json:"slug"`
+			}{}},
+		{Method: "GET", Path: "/posts/:id", Tag: "posts", Summary: "Get a post", Query: []openapi.Param{render}, Response: Response{}, Cached: true},
+		{Method: "GET", Path: "/posts", Tag: "posts", Summary: "List every post with its edit lock", Query: listQuery(render, openapi.Lang), Response: []Response{}},
+		{Method: "PUT", Path: "/posts/:id", Tag: "posts", Summary: "Replace a post", Body: Request{}, Response: Response{}, IfMatch: true},
+		{Method: "PATCH", Path: "/posts/:id", Tag: "posts", Summary: "Patch a post", Body: Request{}, Response: Response{}, IfMatch: true},
+		{Method: "DELETE", Path: "/posts/:id", Tag: "posts", Summary: "Delete a post", IfMatch: true},
+		{Method: "POST", Path: "/posts/category", Tag: "posts", Summary: "Add a post to a category", Body: PostCategories{}},
+		{Method: "DELETE", Path: "/posts/category", Tag: "posts", Summary: "Remove a post from a category", Body: PostCategories{}},
+		{Method: "POST", Path: "/posts/version", Tag: "posts", Summary: "Attach a version to a post", Body: PostVersion{}},
+		{Method: "DELETE", Path: "/posts/version", Tag: "posts", Summary: "Detach a version from a post", Body: PostVersion{}},
+		{Method: "POST", Path: "/posts/:id/lock", Tag: "posts", Summary: "Take or renew the edit lock",
+			Query: []openapi.Param{openapi.Flag("force", "Take the lock from another editor")}, Response: Lock{}},
+		{Method: "DELETE", Path: "/posts/:id/lock", Tag: "posts", Summary: "Release the edit lock",
+			Query: []openapi.Param{openapi.Flag("force", "Release another editor's lock (admins only)")}},
+		{Method: "GET", Path: "/posts/:id/autosave", Tag: "posts", Summary: "Get the caller's autosaved draft", Response: Autosave{}},
+		{Method: "PUT", Path: "/posts/:id/autosave", Tag: "posts", Summary: "Autosave a draft", Body: AutosaveRequest{}, Response: Autosave{}},
+		{Method: "DELETE", Path: "/posts/:id/autosave", Tag: "posts", Summary: "Discard the caller's autosaved draft"},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/author/:author_id", Tag: "posts", Summary: "List an author's published posts", Query: listQuery(render, openapi.Lang), Response: []Response{}},
+		{Method: "GET", Path: "/slug/:slug", Tag: "posts", Summary: "Get a published post by slug",
+			Description: "An old slug answers 301 with the current one in Location and the body.",
+			Query:       []openapi.Param{render}, Response: Response{}, Cached: true},
+		{Method: "GET", Path: "/category/:category", Tag: "posts", Summary: "List the published posts of a category",
+			Query: listQuery(openapi.Flag("include_descendants", "Include posts of subcategories"), render, openapi.Lang), Response: []Response{}},
+		{Method: "GET", Path: "/posts/published", Tag: "posts", Summary: "List published posts", Query: listQuery(render, openapi.Lang), Response: []Response{}},
+		{Method: "GET", Path: "/tags/:slug", Tag: "tags", Summary: "List the published posts of a tag", Query: listQuery(render, openapi.Lang), Response: []Response{}},
+	}
+}
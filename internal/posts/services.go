@@ -2,7 +2,17 @@ package posts
 
 import (
 	"context"
+	"havamal-api/internal/db"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/events"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/media"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/slugs"
+	"havamal-api/internal/tags"
 	"havamal-api/internal/users"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,28 +21,47 @@ import (
 type Service interface {
 	CreatePost(post *Request) error
 	GetPost(id string) (*Response, error)
-	GetPosts() ([]Response, error)
-	GetPublishedPosts() ([]Response, error) 
-	GetPostsByAuthor(authorId string) ([]Response, error)
+	GetPosts(selection fields.Selection) ([]Response, error)
+	GetPublishedPosts(locale string, selection fields.Selection) ([]Response, error)
+	GetPublishedEntries() ([]Response, error)
+	GetPublishedByTag(slug string, locale string, selection fields.Selection) ([]Response, error)
+	GetRedirect(oldSlug string) (string, error)
+	LockPost(id string, userId string, force bool) (*Lock, error)
+	UnlockPost(id string, userId string, force bool) error
+	SaveAutosave(id string, userId string, request *AutosaveRequest) (*Autosave, error)
+	GetAutosave(id string, userId string) (*Autosave, error)
+	DiscardAutosave(id string, userId string) error
+	GetPostsByAuthor(authorId string, locale string, selection fields.Selection) ([]Response, error)
 	GetPostBySlug(slug string) (*Response, error)
-	GetSummariesByCategory(category string) ([]Response, error)
-	UpdatePost(id string, post *Request) error
-	DeletePost(id string) error
+	GetSummariesByCategory(category string, includeDescendants bool, locale string, selection fields.Selection) ([]Response, error)
+	// Relations are what lists of posts in locale can embed with ?include=
+	Relations(locale string) fields.Relations
+	UpdatePost(id string, post *Request, revision int) error
+	DeletePost(id string, revision int) error
 	AddCategory(request PostCategories) error
 	DeleteCategory(request PostCategories) error
 	AddVersion(request PostVersion) error
 	DeleteVersion(request PostVersion) error
 }
 
+// lockTTL is how long an edit lock lasts without a heartbeat
+const lockTTL = 2 * time.Minute
+
 type service struct {
-	repo        Repository
-	userService users.Service
+	repo         Repository
+	userService  users.Service
+	mediaService media.Service
+	tagService   tags.Service
+	publisher    events.Publisher
 }
 
-func NewService(repo Repository, userService users.Service) Service {
+func NewService(repo Repository, userService users.Service, mediaService media.Service, tagService tags.Service, publisher events.Publisher) Service {
 	return &service{
-		repo:        repo,
-		userService: userService,
+		repo:         repo,
+		userService:  userService,
+		mediaService: mediaService,
+		tagService:   tagService,
+		publisher:    publisher,
 	}
 }
 
@@ -54,6 +83,21 @@ func (service *service) CreatePost(post *Request) error {
 		}
 	}
 
+	content, format, err := resolveContent(post, FormatHTML)
+	if err != nil {
+		return err
+	}
+
+	newPostId := uuid.New()
+	locale, group, err := service.translation(post, newPostId, newPostId, i18n.Default)
+	if err != nil {
+		return err
+	}
+	contentHTML, err := Render(format, content)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now()
 	var publishedAt time.Time
 	
@@ -62,23 +106,39 @@ func (service *service) CreatePost(post *Request) error {
 		publishedAt = now
 	}
 
-	newPostId := uuid.New()
+	slug, err := service.uniqueSlug(post.Slug, post.Title, newPostId)
+	if err != nil {
+		return err
+	}
+	post.Slug = slug
+	created := change{ID: newPostId, Slug: slug, Path: navigation.PostPath + slug, Status: post.Status, Locale: locale, TranslationGroup: group}
+	steps := append(service.tagSteps(newPostId, post.Tags), service.publisher.Publish(events.PostCreated, created))
+	if post.Status == Published {
+		steps = append(steps, service.publisher.Publish(events.PostPublished, created))
+	}
 	err = service.repo.CreatePost(&Post{
 		ID:          newPostId,
 		Title:       post.Title,
-		Slug:        post.Slug,
+		Slug:        slug,
 		Summary:     post.Summary,
-		Content:     post.Content,
+		Content:     content,
 		Status:      post.Status,
 		PublishedAt: publishedAt,
 		UpdatedAt:   now,
 		AuthorId:    authorId,
 		Columns:     post.Columns,
-	})
+		Format:      format,
+		ContentHTML: contentHTML,
+		Blocks:      post.Blocks,
+		Locale:      locale,
+		TranslationGroup: group,
+	}, steps...)
 	if err != nil {
 		return err
 	}
 
+	service.syncMedia(newPostId, content)
+
 	if post.CategoryId != "" {
 		categoryId, err := uuid.Parse(post.CategoryId)
 		if err == nil {
@@ -88,42 +148,298 @@ func (service *service) CreatePost(post *Request) error {
 			})
 		}
 	}
+
 	return nil
 }
 
+// tagSteps are the writes replacing the tags of a post, saved in the same
+// transaction as the post. Tags are left untouched when the request does not
+// list them.
+func (service *service) tagSteps(postId uuid.UUID, names []string) []db.Step {
+	if names == nil {
+		return nil
+	}
+	return []db.Step{service.tagService.PostTags(postId, names)}
+}
+
+// syncMedia records the media a saved post links to. The post is already
+// stored by then, so a failure is logged instead of failing the request: the
+// links are recomputed on the next save and the garbage collector also looks
+// for references in the content itself.
+func (service *service) syncMedia(postId uuid.UUID, content string) {
+	if err := service.mediaService.SyncPost(postId, content); err != nil {
+		slog.Error("Failed to sync post media", slog.String("post_id", postId.String()), slog.Any("error", err))
+	}
+}
+
 func (service *service) GetPost(id string) (*Response, error) {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return nil, err
 	}
-	return service.repo.GetPost(parsedId)
+	post, err := service.repo.GetPost(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	return service.withDetails(post)
+}
+
+// GetPosts lists every post with the live edit lock of each, if any
+func (service *service) GetPosts(selection fields.Selection) ([]Response, error) {
+	posts, err := withRenderedAll(service.repo.GetPosts(selection))
+	if err != nil {
+		return nil, err
+	}
+	locks, err := service.repo.GetActiveLocks()
+	if err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		if lock, ok := locks[posts[i].ID]; ok && selection.Has("lock") {
+			posts[i].Lock = &lock
+		}
+	}
+	return posts, nil
 }
 
-func (service *service) GetPosts() ([]Response, error) {
-	return service.repo.GetPosts()
+// GetPublishedPosts lists published posts in locale, or in every locale when
+// locale is empty
+func (service *service) GetPublishedPosts(locale string, selection fields.Selection) ([]Response, error) {
+	return inLocale(locale)(withRenderedAll(service.repo.GetPublishedPosts(selection)))
 }
 
-func (service *service) GetPublishedPosts() ([]Response, error) {
-	return service.repo.GetPublishedPosts()
+// GetPublishedEntries lists every published post once, in every locale, with
+// only its id, slug, locale, translation group and update time
+func (service *service) GetPublishedEntries() ([]Response, error) {
+	return service.repo.GetPublishedEntries()
 }
 
-func (service *service) GetPostsByAuthor(authorId string) ([]Response, error) {
+func (service *service) GetPostsByAuthor(authorId string, locale string, selection fields.Selection) ([]Response, error) {
 	parsedAuthorId, err := uuid.Parse(authorId)
 	if err != nil {
 		return nil, err
 	}
-	return service.repo.GetPostsByAuthor(parsedAuthorId)
+	return inLocale(locale)(withRenderedAll(service.repo.GetPostsByAuthor(parsedAuthorId, selection)))
+}
+
+// inLocale keeps one post per translation group: the one written in locale,
+// else the one in the default locale, else the first listed. An empty locale
+// keeps every post.
+func inLocale(locale string) func(posts []Response, err error) ([]Response, error) {
+	return func(posts []Response, err error) ([]Response, error) {
+		if err != nil || locale == "" {
+			return posts, err
+		}
+		chosen := make(map[uuid.UUID]int)
+		for i, post := range posts {
+			current, ok := chosen[post.TranslationGroup]
+			if !ok || rankLocale(post.Locale, locale) < rankLocale(posts[current].Locale, locale) {
+				chosen[post.TranslationGroup] = i
+			}
+		}
+		filtered := make([]Response, 0, len(chosen))
+		for i, post := range posts {
+			if chosen[post.TranslationGroup] == i {
+				filtered = append(filtered, post)
+			}
+		}
+		return filtered, nil
+	}
+}
+
+// InLocale keeps one post per translation group like the localised lists do
+func InLocale(posts []Response, locale string) []Response {
+	filtered, _ := inLocale(locale)(posts, nil)
+	return filtered
+}
+
+func rankLocale(postLocale string, locale string) int {
+	switch postLocale {
+	case locale:
+		return 0
+	case i18n.Default:
+		return 1
+	}
+	return 2
 }
 
 func (service *service) GetPostBySlug(slug string) (*Response, error) {
-	return service.repo.GetPostBySlug(slug)
+	post, err := service.repo.GetPostBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return service.withDetails(post)
+}
+
+// GetRedirect returns the current slug of a post from one of its old slugs
+func (service *service) GetRedirect(oldSlug string) (string, error) {
+	return service.repo.GetRedirect(oldSlug)
+}
+
+// uniqueSlug normalises the requested slug, or the title when none is given,
+// and adds a numeric suffix if another post already uses it
+func (service *service) uniqueSlug(requested string, title string, id uuid.UUID) (string, error) {
+	base := requested
+	if base == "" {
+		base = title
+	}
+	return slugs.Unique(slugs.Make(base), func(slug string) (bool, error) {
+		return service.repo.SlugTaken(slug, id)
+	})
+}
+
+func (service *service) GetPublishedByTag(slug string, locale string, selection fields.Selection) ([]Response, error) {
+	tag, err := service.tagService.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return inLocale(locale)(withRenderedAll(service.repo.GetPublishedByTag(tag.ID, selection)))
+}
+
+// withDetails completes a single post response with its table of contents,
+// tags and translations
+func (service *service) withDetails(post *Response) (*Response, error) {
+	withTOC(withRendered(post))
+	postTags, err := service.tagService.GetByPost(post.ID)
+	if err != nil {
+		return nil, err
+	}
+	post.Tags = postTags
+	translations, err := service.repo.GetTranslations(post.TranslationGroup)
+	if err != nil {
+		return nil, err
+	}
+	post.Alternates = Alternates(translations)
+	return post, nil
+}
+
+// Alternates fills the href of the published translations of a post and adds
+// the x-default one. A post without translations has no alternates.
+func Alternates(translations []Alternate) []Alternate {
+	if len(translations) < 2 {
+		return nil
+	}
+	alternates := make([]Alternate, 0, len(translations)+1)
+	for _, translation := range translations {
+		translation.Href = navigation.PostPath + translation.Slug
+		alternates = append(alternates, translation)
+		if translation.Hreflang == i18n.Default {
+			alternates = append(alternates, Alternate{Hreflang: "x-default", Slug: translation.Slug, Href: translation.Href})
+		}
+	}
+	return alternates
+}
+
+func (service *service) GetSummariesByCategory(category string, includeDescendants bool, locale string, selection fields.Selection) ([]Response, error) {
+	return inLocale(locale)(withRenderedAll(service.repo.GetSummariesByCategory(category, includeDescendants, selection)))
+}
+
+func (service *service) Relations(locale string) fields.Relations {
+	return fields.Relations{
+		"author": {Key: "author_id", Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			return loaded(service.repo.GetAuthors(ids))
+		}},
+		"categories": {Key: "id", Many: true, Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			return loaded(service.repo.GetCategoriesByPosts(ids, locale))
+		}},
+		"tags": {Key: "id", Many: true, Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			return loaded(service.tagService.GetByPosts(ids))
+		}},
+		"versions": {Key: "id", Many: true, Load: func(ids []uuid.UUID) (map[uuid.UUID]any, error) {
+			return loaded(service.repo.GetVersionsByPosts(ids))
+		}},
+	}
+}
+
+// loaded adapts the result of a batch query to a relation loader
+func loaded[V any](values map[uuid.UUID]V, err error) (map[uuid.UUID]any, error) {
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uuid.UUID]any, len(values))
+	for id, value := range values {
+		result[id] = value
+	}
+	return result, nil
+}
+
+// translation returns the locale and translation group of a post. Joining
+// another post's group requires that no post of the group uses the locale yet.
+func (service *service) translation(post *Request, id uuid.UUID, group uuid.UUID, locale string) (string, uuid.UUID, error) {
+	if post.Locale != "" {
+		normalized, err := i18n.Normalize(post.Locale)
+		if err != nil {
+			return "", uuid.Nil, err
+		}
+		locale = normalized
+	}
+	if post.TranslationOf != "" {
+		originalId, err := uuid.Parse(post.TranslationOf)
+		if err != nil {
+			return "", uuid.Nil, err
+		}
+		original, err := service.repo.GetPost(originalId)
+		if err != nil {
+			return "", uuid.Nil, err
+		}
+		group = original.TranslationGroup
+	}
+	taken, err := service.repo.TranslationTaken(group, locale, id)
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+	if taken {
+		return "", uuid.Nil, ErrTranslationExists
+	}
+	return locale, group, nil
+}
+
+// withRendered fills the HTML of posts saved before rendering was cached
+func withRendered(post *Response) *Response {
+	if post.ContentHTML == "" && post.Content != "" {
+		if rendered, err := Render(post.Format, post.Content); err == nil {
+			post.ContentHTML = rendered
+		}
+	}
+	return post
+}
+
+func withRenderedAll(posts []Response, err error) ([]Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		withRendered(&posts[i])
+	}
+	return posts, nil
+}
+
+func withTOC(post *Response) *Response {
+	post.TOC = TableOfContents(post.ContentHTML)
+	if post.Blocks != nil {
+		post.ContentText = post.Blocks.Text()
+	}
+	return post
 }
 
-func (service *service) GetSummariesByCategory(category string) ([]Response, error) {
-	return service.repo.GetSummariesByCategory(category)
+// resolveContent returns the content to store and its format. Block documents
+// are validated and their HTML becomes the content, kept for clients that do
+// not read blocks.
+func resolveContent(post *Request, defaultFormat Format) (string, Format, error) {
+	if post.Blocks != nil {
+		if err := post.Blocks.Validate(); err != nil {
+			return "", "", err
+		}
+		return post.Blocks.HTML(), FormatHTML, nil
+	}
+	format := post.Format
+	if format == "" {
+		format = defaultFormat
+	}
+	return post.Content, format, nil
 }
 
-func (service *service) UpdatePost(id string, post *Request) error {
+func (service *service) UpdatePost(id string, post *Request, revision int) error {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return err
@@ -143,6 +459,19 @@ func (service *service) UpdatePost(id string, post *Request) error {
 		}
 	}
 
+	content, format, err := resolveContent(post, existingPost.Format)
+	if err != nil {
+		return err
+	}
+	locale, group, err := service.translation(post, parsedId, existingPost.TranslationGroup, existingPost.Locale)
+	if err != nil {
+		return err
+	}
+	contentHTML, err := Render(format, content)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now()
 	publishedAt := existingPost.PublishedAt
 	
@@ -151,26 +480,75 @@ func (service *service) UpdatePost(id string, post *Request) error {
 		publishedAt = now
 	}
 
-	return service.repo.UpdatePost(&Post{
+	// An omitted slug keeps the current one rather than regenerating it
+	slug := existingPost.Slug
+	if post.Slug != "" {
+		slug, err = service.uniqueSlug(post.Slug, post.Title, parsedId)
+		if err != nil {
+			return err
+		}
+	}
+
+	updated := change{ID: parsedId, Slug: slug, Path: navigation.PostPath + slug, Status: post.Status, Locale: locale, TranslationGroup: group}
+	if slug != existingPost.Slug {
+		updated.PreviousSlug = existingPost.Slug
+	}
+	steps := append(service.tagSteps(parsedId, post.Tags), service.publisher.Publish(events.PostUpdated, updated))
+	switch {
+	case post.Status == Published && existingPost.Status != Published:
+		steps = append(steps, service.publisher.Publish(events.PostPublished, updated))
+	case post.Status != Published && existingPost.Status == Published:
+		steps = append(steps, service.publisher.Publish(events.PostUnpublished, updated))
+	}
+	err = service.repo.UpdatePost(&Post{
 		ID:          parsedId,
 		Title:       post.Title,
-		Slug:        post.Slug,
+		Slug:        slug,
 		Summary:     post.Summary,
-		Content:     post.Content,
+		Content:     content,
 		Status:      post.Status,
 		PublishedAt: publishedAt,
 		UpdatedAt:   now,
 		AuthorId:    authorId,
 		Columns:     post.Columns,
-	})
+		Format:      format,
+		ContentHTML: contentHTML,
+		Blocks:      post.Blocks,
+		Revision:    revision,
+		Locale:      locale,
+		TranslationGroup: group,
+	}, steps...)
+	if err != nil {
+		return err
+	}
+	service.syncMedia(parsedId, content)
+	return nil
 }
 
-func (service *service) DeletePost(id string) error {
+func (service *service) DeletePost(id string, revision int) error {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return err
 	}
-	return service.repo.DeletePost(parsedId)
+	existingPost, err := service.repo.GetPost(parsedId)
+	if err != nil {
+		return err
+	}
+	if revision != etag.Any && existingPost.Revision != revision {
+		return etag.ErrPreconditionFailed
+	}
+	// Release media first so the garbage collector counts from the deletion
+	if err := service.mediaService.ReleasePost(parsedId); err != nil {
+		return err
+	}
+	return service.repo.DeletePost(parsedId, revision, service.publisher.Publish(events.PostDeleted, change{
+		ID:               parsedId,
+		Slug:             existingPost.Slug,
+		Path:             navigation.PostPath + existingPost.Slug,
+		Status:           existingPost.Status,
+		Locale:           existingPost.Locale,
+		TranslationGroup: existingPost.TranslationGroup,
+	}))
 }
 
 func (service *service) AddCategory(request PostCategories) error {
@@ -188,3 +566,93 @@ func (service *service) AddVersion(request PostVersion) error {
 func (service *service) DeleteVersion(request PostVersion) error {
 	return service.repo.DeleteVersion(request)
 }
+
+// LockPost takes or renews the edit lock for lockTTL. Editors call it again as
+// a heartbeat; force takes over a lock held by someone else.
+func (service *service) LockPost(id string, userId string, force bool) (*Lock, error) {
+	parsedId, parsedUserId, err := parseIds(id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := service.repo.GetPost(parsedId); err != nil {
+		return nil, err
+	}
+	return service.repo.AcquireLock(parsedId, parsedUserId, time.Now().Add(lockTTL), force)
+}
+
+func (service *service) UnlockPost(id string, userId string, force bool) error {
+	parsedId, parsedUserId, err := parseIds(id, userId)
+	if err != nil {
+		return err
+	}
+	return service.repo.ReleaseLock(parsedId, parsedUserId, force)
+}
+
+// SaveAutosave stores the editor's working copy without touching the live post
+// or its revision
+func (service *service) SaveAutosave(id string, userId string, request *AutosaveRequest) (*Autosave, error) {
+	parsedId, parsedUserId, err := parseIds(id, userId)
+	if err != nil {
+		return nil, err
+	}
+	post, err := service.repo.GetPost(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	format := request.Format
+	if format == "" {
+		format = post.Format
+	}
+	if !ValidFormat(format) {
+		return nil, ErrInvalidFormat
+	}
+	baseRevision := request.BaseRevision
+	if baseRevision == 0 {
+		baseRevision = post.Revision
+	}
+
+	autosave := &Autosave{
+		PostId:       parsedId,
+		UserId:       parsedUserId,
+		Title:        request.Title,
+		Summary:      request.Summary,
+		Content:      request.Content,
+		Format:       format,
+		Blocks:       request.Blocks,
+		BaseRevision: baseRevision,
+		SavedAt:      time.Now(),
+		Stale:        baseRevision != post.Revision,
+	}
+	if err := service.repo.SaveAutosave(autosave); err != nil {
+		return nil, err
+	}
+	return autosave, nil
+}
+
+func (service *service) GetAutosave(id string, userId string) (*Autosave, error) {
+	parsedId, parsedUserId, err := parseIds(id, userId)
+	if err != nil {
+		return nil, err
+	}
+	return service.repo.GetAutosave(parsedId, parsedUserId)
+}
+
+func (service *service) DiscardAutosave(id string, userId string) error {
+	parsedId, parsedUserId, err := parseIds(id, userId)
+	if err != nil {
+		return err
+	}
+	return service.repo.DeleteAutosave(parsedId, parsedUserId)
+}
+
+func parseIds(id string, userId string) (uuid.UUID, uuid.UUID, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	parsedUserId, err := uuid.Parse(userId)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return parsedId, parsedUserId, nil
+}
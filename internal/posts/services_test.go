@@ -0,0 +1,206 @@
+package posts
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"havamal-api/internal/i18n"
+
+	"github.com/google/uuid"
+)
+
+// errMissing is what the fake answers for a post it does not have
+var errMissing = errors.New("missing")
+
+type fakeRepository struct {
+	Repository
+	posts     []Response
+	expiresAt time.Time
+	force     bool
+	autosave  *Autosave
+	// taken is the locale already written in each translation group
+	taken map[uuid.UUID]string
+}
+
+func (f *fakeRepository) GetPost(id uuid.UUID) (*Response, error) {
+	for _, post := range f.posts {
+		if post.ID == id {
+			return &post, nil
+		}
+	}
+	return nil, errMissing
+}
+
+func (f *fakeRepository) TranslationTaken(group uuid.UUID, locale string, excludeId uuid.UUID) (bool, error) {
+	return f.taken[group] == locale, nil
+}
+
+func (f *fakeRepository) AcquireLock(postId uuid.UUID, userId uuid.UUID, expiresAt time.Time, force bool) (*Lock, error) {
+	f.expiresAt, f.force = expiresAt, force
+	return &Lock{PostId: postId, UserId: userId, ExpiresAt: expiresAt}, nil
+}
+
+func (f *fakeRepository) ReleaseLock(postId uuid.UUID, userId uuid.UUID, force bool) error {
+	f.force = force
+	return nil
+}
+
+func (f *fakeRepository) SaveAutosave(autosave *Autosave) error {
+	f.autosave = autosave
+	return nil
+}
+
+func TestLockPost(t *testing.T) {
+	post := Response{ID: uuid.New(), Revision: 3, Format: FormatMarkdown}
+	tests := []struct {
+		name    string
+		id      string
+		force   bool
+		wantErr error
+	}{
+		{"take", post.ID.String(), false, nil},
+		{"take over", post.ID.String(), true, nil},
+		{"missing post", uuid.NewString(), false, errMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{posts: []Response{post}}
+			before := time.Now()
+			lock, err := (&service{repo: repository}).LockPost(tt.id, uuid.NewString(), tt.force)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LockPost() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			// Every call, heartbeats included, pushes the expiry lockTTL ahead
+			if lock.ExpiresAt.Before(before.Add(lockTTL)) || lock.ExpiresAt.After(time.Now().Add(lockTTL)) {
+				t.Errorf("LockPost() expires at %v, want %v from now", lock.ExpiresAt, lockTTL)
+			}
+			if repository.force != tt.force {
+				t.Errorf("LockPost() force = %v, want %v", repository.force, tt.force)
+			}
+		})
+	}
+	if _, err := (&service{repo: &fakeRepository{}}).LockPost("first", uuid.NewString(), false); err == nil {
+		t.Error("LockPost() accepted a malformed id")
+	}
+}
+
+func TestSaveAutosave(t *testing.T) {
+	post := Response{ID: uuid.New(), Revision: 3, Format: FormatMarkdown}
+	tests := []struct {
+		name       string
+		request    AutosaveRequest
+		wantFormat Format
+		wantStale  bool
+		wantErr    error
+	}{
+		{"on the current revision", AutosaveRequest{Content: "Deyr fé"}, FormatMarkdown, false, nil},
+		{"started from it", AutosaveRequest{Content: "Deyr fé", BaseRevision: 3}, FormatMarkdown, false, nil},
+		{"started before a change", AutosaveRequest{Content: "Deyr fé", BaseRevision: 2}, FormatMarkdown, true, nil},
+		{"in another format", AutosaveRequest{Content: "<p>Deyr fé</p>", Format: FormatHTML}, FormatHTML, false, nil},
+		{"unknown format", AutosaveRequest{Content: "Deyr fé", Format: "rtf"}, "", false, ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{posts: []Response{post}}
+			autosave, err := (&service{repo: repository}).SaveAutosave(post.ID.String(), uuid.NewString(), &tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveAutosave() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if repository.autosave != nil {
+					t.Error("SaveAutosave() stored a refused working copy")
+				}
+				return
+			}
+			if autosave.Format != tt.wantFormat || autosave.Stale != tt.wantStale || repository.autosave != autosave {
+				t.Errorf("SaveAutosave() = %+v, want format %s and stale %v", autosave, tt.wantFormat, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestTranslation(t *testing.T) {
+	original := Response{ID: uuid.New(), TranslationGroup: uuid.New(), Locale: i18n.Default}
+	id := uuid.New()
+	tests := []struct {
+		name       string
+		request    Request
+		wantLocale string
+		wantGroup  uuid.UUID
+		wantErr    error
+	}{
+		{"default locale", Request{}, i18n.Default, id, nil},
+		{"its own group", Request{Locale: "en"}, "en", id, nil},
+		{"translation of a post", Request{Locale: "en", TranslationOf: original.ID.String()}, "en", original.TranslationGroup, nil},
+		{"locale already written", Request{TranslationOf: original.ID.String()}, "", uuid.Nil, ErrTranslationExists},
+		{"unsupported locale", Request{Locale: "de"}, "", uuid.Nil, i18n.ErrUnsupportedLocale},
+		{"translation of a missing post", Request{Locale: "en", TranslationOf: uuid.NewString()}, "", uuid.Nil, errMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{
+				posts: []Response{original},
+				taken: map[uuid.UUID]string{original.TranslationGroup: i18n.Default},
+			}
+			locale, group, err := (&service{repo: repository}).translation(&tt.request, id, id, i18n.Default)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("translation() error = %v, want %v", err, tt.wantErr)
+			}
+			if locale != tt.wantLocale || group != tt.wantGroup {
+				t.Errorf("translation() = %s %s, want %s %s", locale, group, tt.wantLocale, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestInLocale(t *testing.T) {
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	posts := []Response{
+		{Slug: "havamal-es", Locale: "es", TranslationGroup: first},
+		{Slug: "havamal", Locale: i18n.Default, TranslationGroup: first},
+		{Slug: "havamal-en", Locale: "en", TranslationGroup: first},
+		{Slug: "voluspa", Locale: i18n.Default, TranslationGroup: second},
+		{Slug: "rigsthula-es", Locale: "es", TranslationGroup: third},
+	}
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"en", []string{"havamal-en", "voluspa", "rigsthula-es"}},
+		{"es", []string{"havamal-es", "voluspa", "rigsthula-es"}},
+		{i18n.Default, []string{"havamal", "voluspa", "rigsthula-es"}},
+		{"", []string{"havamal-es", "havamal", "havamal-en", "voluspa", "rigsthula-es"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			filtered, _ := inLocale(tt.locale)(posts, nil)
+			slugs := make([]string, 0)
+			for _, post := range filtered {
+				slugs = append(slugs, post.Slug)
+			}
+			if !reflect.DeepEqual(slugs, tt.want) {
+				t.Errorf("inLocale() = %v, want %v", slugs, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlternates(t *testing.T) {
+	if got := Alternates([]Alternate{{Hreflang: "en", Slug: "havamal"}}); len(got) != 0 {
+		t.Errorf("Alternates() of a single post = %+v, want none", got)
+	}
+	got := Alternates([]Alternate{{Hreflang: i18n.Default, Slug: "havamal"}, {Hreflang: "en", Slug: "havamal-en"}})
+	want := []Alternate{
+		{Hreflang: i18n.Default, Slug: "havamal", Href: "/post/havamal"},
+		{Hreflang: "x-default", Slug: "havamal", Href: "/post/havamal"},
+		{Hreflang: "en", Slug: "havamal-en", Href: "/post/havamal-en"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Alternates() = %+v, want %+v", got, want)
+	}
+}
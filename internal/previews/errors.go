@@ -0,0 +1,10 @@
+package previews
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound        = apierror.New(apierror.PreviewNotFound)
+	ErrLinkExpired     = apierror.New(apierror.PreviewExpired)
+	ErrLinkRevoked     = apierror.New(apierror.PreviewRevoked)
+	ErrVersionMismatch = apierror.New(apierror.PreviewVersionMismatch)
+)
@@ -0,0 +1,65 @@
+package previews
+
+import (
+	"havamal-api/internal/apierror"
+	"net/http"
+
+	"havamal-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	var request Request
+	// The body is optional: without it the link shows the current draft
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+	}
+	link, err := h.service.Create(c.Param("id"), middleware.GetUserID(c), &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, link)
+}
+
+func (h *Handler) GetByPost(c *gin.Context) {
+	links, err := h.service.GetByPost(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+func (h *Handler) Revoke(c *gin.Context) {
+	if err := h.service.Revoke(c.Param("id"), c.Param("link_id")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Preview link revoked successfully"})
+}
+
+// View serves a preview to anyone holding the token. Previews must never be
+// indexed or cached by shared caches.
+func (h *Handler) View(c *gin.Context) {
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Cache-Control", "private, no-store")
+	post, err := h.service.View(c.Param("token"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, post)
+}
@@ -0,0 +1,27 @@
+package previews
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	VersionId string `json:"version_id"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// Link is a shareable token giving read access to a post that may not be
+// published, optionally pinned to one of its versions
+type Link struct {
+	ID           uuid.UUID  `json:"id"`
+	Token        string     `json:"token"`
+	PostId       uuid.UUID  `json:"post_id"`
+	VersionId    *uuid.UUID `json:"version_id"`
+	CreatedBy    *uuid.UUID `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ViewCount    int        `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+}
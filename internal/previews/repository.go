@@ -0,0 +1,114 @@
+package previews
+
+import (
+	"database/sql"
+
+	"havamal-api/internal/db"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(link *Link) error
+	GetByToken(token string) (*Link, error)
+	GetByPost(postId uuid.UUID) ([]Link, error)
+	RecordView(id uuid.UUID) error
+	Revoke(postId uuid.UUID, id uuid.UUID) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// translate returns the domain error of a failed preview link query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
+const linkColumns = `id, token, post_id, version_id, created_by, created_at, expires_at, revoked_at, view_count, last_viewed_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row scanner) (*Link, error) {
+	var link Link
+	var versionID, createdBy uuid.NullUUID
+	var revokedAt, lastViewedAt sql.NullTime
+	if err := row.Scan(&link.ID, &link.Token, &link.PostId, &versionID, &createdBy, &link.CreatedAt, &link.ExpiresAt, &revokedAt, &link.ViewCount, &lastViewedAt); err != nil {
+		return nil, translate(err)
+	}
+	if versionID.Valid {
+		link.VersionId = &versionID.UUID
+	}
+	if createdBy.Valid {
+		link.CreatedBy = &createdBy.UUID
+	}
+	if revokedAt.Valid {
+		link.RevokedAt = &revokedAt.Time
+	}
+	if lastViewedAt.Valid {
+		link.LastViewedAt = &lastViewedAt.Time
+	}
+	return &link, nil
+}
+
+func nullableId(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+func (r *repository) Create(link *Link) error {
+	query := `INSERT INTO preview_links (id, token, post_id, version_id, created_by, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.Exec(query, link.ID, link.Token, link.PostId, nullableId(link.VersionId), nullableId(link.CreatedBy), link.CreatedAt, link.ExpiresAt)
+	return translate(err)
+}
+
+func (r *repository) GetByToken(token string) (*Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM preview_links WHERE token = $1`
+	return scanLink(r.db.QueryRow(query, token))
+}
+
+func (r *repository) GetByPost(postId uuid.UUID) ([]Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM preview_links WHERE post_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	links := make([]Link, 0)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *link)
+	}
+	return links, nil
+}
+
+func (r *repository) RecordView(id uuid.UUID) error {
+	query := `UPDATE preview_links SET view_count = view_count + 1, last_viewed_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// Revoke returns ErrNotFound when the post has no such link
+func (r *repository) Revoke(postId uuid.UUID, id uuid.UUID) error {
+	query := `UPDATE preview_links SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 AND post_id = $2`
+	result, err := r.db.Exec(query, id, postId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
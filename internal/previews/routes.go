@@ -0,0 +1,37 @@
+package previews
+
+import (
+	"havamal-api/internal/openapi"
+	"havamal-api/internal/posts"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/posts/:id/preview-links", handler.Create)
+	router.GET("/posts/:id/preview-links", handler.GetByPost)
+	router.DELETE("/posts/:id/preview-links/:link_id", handler.Revoke)
+}
+
+func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/preview/:token", handler.View)
+}
+
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/posts/:id/preview-links", Tag: "previews", Summary: "Create a preview link",
+			Description: "Without a body the link shows the current draft.", Body: Request{}, OptionalBody: true, Response: Link{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/posts/:id/preview-links", Tag: "previews", Summary: "List a post's preview links", Response: []Link{}},
+		{Method: "DELETE", Path: "/posts/:id/preview-links/:link_id", Tag: "previews", Summary: "Revoke a preview link"},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/preview/:token", Tag: "previews", Summary: "View a preview", Response: posts.Response{}},
+	}
+}
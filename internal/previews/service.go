@@ -0,0 +1,160 @@
+package previews
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"havamal-api/internal/media"
+	"havamal-api/internal/posts"
+	"havamal-api/internal/versions"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTTL = 7 * 24 * time.Hour
+	maxTTL     = 30 * 24 * time.Hour
+)
+
+type Service interface {
+	Create(postId string, createdBy string, request *Request) (*Link, error)
+	GetByPost(postId string) ([]Link, error)
+	View(token string) (*posts.Response, error)
+	Revoke(postId string, id string) error
+}
+
+type service struct {
+	repo           Repository
+	postService    posts.Service
+	versionService versions.Service
+	mediaService   media.Service
+}
+
+func NewService(repo Repository, postService posts.Service, versionService versions.Service, mediaService media.Service) Service {
+	return &service{
+		repo:           repo,
+		postService:    postService,
+		versionService: versionService,
+		mediaService:   mediaService,
+	}
+}
+
+func (s *service) Create(postId string, createdBy string, request *Request) (*Link, error) {
+	post, err := s.postService.GetPost(postId)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &Link{
+		ID:        uuid.New(),
+		PostId:    post.ID,
+		CreatedAt: time.Now(),
+	}
+	if request.VersionId != "" {
+		version, err := s.versionService.GetById(request.VersionId)
+		if err != nil {
+			return nil, err
+		}
+		if version.PostId != post.ID {
+			return nil, ErrVersionMismatch
+		}
+		link.VersionId = &version.ID
+	}
+	if userId, err := uuid.Parse(createdBy); err == nil {
+		link.CreatedBy = &userId
+	}
+
+	ttl := defaultTTL
+	if request.ExpiresIn > 0 {
+		ttl = min(time.Duration(request.ExpiresIn)*time.Second, maxTTL)
+	}
+	link.ExpiresAt = link.CreatedAt.Add(ttl)
+
+	if link.Token, err = newToken(); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// newToken returns 256 random bits, URL-safe encoded
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (s *service) GetByPost(postId string) ([]Link, error) {
+	parsedId, err := uuid.Parse(postId)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByPost(parsedId)
+}
+
+// View returns the post behind a valid token in its current state, or with the
+// content of the pinned version rendered in the format it was written in, and
+// counts the view
+func (s *service) View(token string) (*posts.Response, error) {
+	link, err := s.repo.GetByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt != nil {
+		return nil, ErrLinkRevoked
+	}
+	if time.Now().After(link.ExpiresAt) {
+		return nil, ErrLinkExpired
+	}
+
+	post, err := s.postService.GetPost(link.PostId.String())
+	if err != nil {
+		return nil, err
+	}
+	if link.VersionId != nil {
+		version, err := s.versionService.GetById(link.VersionId.String())
+		if err != nil {
+			return nil, err
+		}
+		format := posts.Format(version.Format)
+		rendered, err := posts.Render(format, version.Content)
+		if err != nil {
+			return nil, err
+		}
+		post.Format = format
+		post.Content = version.Content
+		post.ContentHTML = rendered
+		post.ContentText = ""
+		post.Blocks = nil
+		post.TOC = posts.TableOfContents(rendered)
+	}
+	// Media of a draft is private, so the reviewer gets signed URLs
+	if post.Content, err = s.mediaService.SignContent(post.Content); err != nil {
+		return nil, err
+	}
+	if post.ContentHTML, err = s.mediaService.SignContent(post.ContentHTML); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RecordView(link.ID); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *service) Revoke(postId string, id string) error {
+	parsedPostId, err := uuid.Parse(postId)
+	if err != nil {
+		return err
+	}
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Revoke(parsedPostId, parsedId)
+}
@@ -0,0 +1,193 @@
+package previews
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"havamal-api/internal/media"
+	"havamal-api/internal/posts"
+	"havamal-api/internal/versions"
+
+	"github.com/google/uuid"
+)
+
+type fakeRepository struct {
+	Repository
+	links map[string]*Link
+	views int
+}
+
+func (f *fakeRepository) Create(link *Link) error {
+	f.links[link.Token] = link
+	return nil
+}
+
+func (f *fakeRepository) GetByToken(token string) (*Link, error) {
+	if link, ok := f.links[token]; ok {
+		return link, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeRepository) RecordView(id uuid.UUID) error {
+	f.views++
+	return nil
+}
+
+type fakePosts struct {
+	posts.Service
+	post posts.Response
+}
+
+func (f fakePosts) GetPost(id string) (*posts.Response, error) {
+	if id != f.post.ID.String() {
+		return nil, posts.ErrNotFound
+	}
+	post := f.post
+	return &post, nil
+}
+
+type fakeVersions struct {
+	versions.Service
+	versions []versions.Version
+}
+
+func (f fakeVersions) GetById(id string) (*versions.Version, error) {
+	for _, version := range f.versions {
+		if version.ID.String() == id {
+			return &version, nil
+		}
+	}
+	return nil, versions.ErrNotFound
+}
+
+// fakeMedia marks the content it signs
+type fakeMedia struct {
+	media.Service
+}
+
+func (fakeMedia) SignContent(content string) (string, error) {
+	return strings.ReplaceAll(content, "/media/1", "/media/1?signature=s"), nil
+}
+
+type fixture struct {
+	service    *service
+	repository *fakeRepository
+	post       posts.Response
+	version    versions.Version
+	plaintext  versions.Version
+	other      versions.Version
+}
+
+func newFixture() fixture {
+	post := posts.Response{
+		ID:          uuid.New(),
+		Title:       "Hávamál",
+		Status:      posts.Draft,
+		Format:      posts.FormatMarkdown,
+		Content:     "Gáttir allar ![](/media/1)",
+		ContentHTML: `<p>Gáttir allar <img src="/media/1" alt=""></p>`,
+	}
+	version := versions.Version{ID: uuid.New(), PostId: post.ID, Content: "# Deyr fé", Format: string(posts.FormatMarkdown)}
+	// Written before the post moved to markdown
+	plaintext := versions.Version{ID: uuid.New(), PostId: post.ID, Content: "# Deyr fé", Format: string(posts.FormatPlaintext)}
+	other := versions.Version{ID: uuid.New(), PostId: uuid.New(), Content: "Other", Format: string(posts.FormatMarkdown)}
+	repository := &fakeRepository{links: make(map[string]*Link)}
+	return fixture{
+		service: &service{
+			repo:           repository,
+			postService:    fakePosts{post: post},
+			versionService: fakeVersions{versions: []versions.Version{version, plaintext, other}},
+			mediaService:   fakeMedia{},
+		},
+		repository: repository,
+		post:       post,
+		version:    version,
+		plaintext:  plaintext,
+		other:      other,
+	}
+}
+
+func TestCreate(t *testing.T) {
+	f := newFixture()
+	tests := []struct {
+		name    string
+		request Request
+		ttl     time.Duration
+		wantErr error
+	}{
+		{"default expiry", Request{}, defaultTTL, nil},
+		{"given expiry", Request{ExpiresIn: 3600}, time.Hour, nil},
+		{"expiry is capped", Request{ExpiresIn: 365 * 24 * 3600}, maxTTL, nil},
+		{"pinned version", Request{VersionId: f.version.ID.String()}, defaultTTL, nil},
+		{"version of another post", Request{VersionId: f.other.ID.String()}, 0, ErrVersionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := f.service.Create(f.post.ID.String(), uuid.NewString(), &tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got := link.ExpiresAt.Sub(link.CreatedAt); got != tt.ttl {
+				t.Errorf("Create() expires after %v, want %v", got, tt.ttl)
+			}
+			if len(link.Token) != 43 || f.repository.links[link.Token] != link {
+				t.Errorf("Create() token %q was not stored", link.Token)
+			}
+			if (link.VersionId != nil) != (tt.request.VersionId != "") {
+				t.Errorf("Create() version = %v", link.VersionId)
+			}
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	f := newFixture()
+	now := time.Now()
+	revoked := now.Add(-time.Minute)
+	f.repository.links = map[string]*Link{
+		"live":                     {ID: uuid.New(), PostId: f.post.ID, ExpiresAt: now.Add(time.Hour)},
+		"pinned":                   {ID: uuid.New(), PostId: f.post.ID, VersionId: &f.version.ID, ExpiresAt: now.Add(time.Hour)},
+		"pinned in another format": {ID: uuid.New(), PostId: f.post.ID, VersionId: &f.plaintext.ID, ExpiresAt: now.Add(time.Hour)},
+		"expired":                  {ID: uuid.New(), PostId: f.post.ID, ExpiresAt: now.Add(-time.Second)},
+		"revoked":                  {ID: uuid.New(), PostId: f.post.ID, ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked},
+	}
+	tests := []struct {
+		token   string
+		html    string
+		wantErr error
+	}{
+		{"live", `<p>Gáttir allar <img src="/media/1?signature=s" alt=""></p>`, nil},
+		{"pinned", `<h1 id="deyr-fé">Deyr fé</h1>`, nil},
+		{"pinned in another format", `<p># Deyr fé</p>`, nil},
+		{"expired", "", ErrLinkExpired},
+		{"revoked", "", ErrLinkRevoked},
+		{"unknown", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			views := f.repository.views
+			post, err := f.service.View(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("View() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if f.repository.views != views {
+					t.Error("View() counted a refused view")
+				}
+				return
+			}
+			if strings.TrimSpace(post.ContentHTML) != tt.html {
+				t.Errorf("View() html = %q, want %q", post.ContentHTML, tt.html)
+			}
+			if f.repository.views != views+1 {
+				t.Error("View() did not count the view")
+			}
+		})
+	}
+}
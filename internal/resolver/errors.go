@@ -0,0 +1,7 @@
+package resolver
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound = apierror.New(apierror.PathNotFound)
+)
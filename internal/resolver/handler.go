@@ -0,0 +1,34 @@
+package resolver
+
+import (
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/i18n"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// Resolve answers a known path with the routing decision in the body rather
+// than as the response status, so that clients do not follow the redirect
+// themselves. A path that matches nothing is a 404 problem.
+func (h *Handler) Resolve(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		apierror.Write(c, apierror.PathRequired)
+		return
+	}
+	resolution, err := h.service.Resolve(path, i18n.FromRequest(c))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resolution)
+}
@@ -0,0 +1,28 @@
+package resolver
+
+import "github.com/google/uuid"
+
+type EntityType string
+
+const (
+	EntityPost     EntityType = "post"
+	EntityCategory EntityType = "category"
+)
+
+type Crumb struct {
+	Label string `json:"label"`
+	Path  string `json:"path"`
+}
+
+// Resolution tells the frontend router what an incoming path points to.
+// Status is 200 when the path is already canonical and 301 when the client
+// should redirect to Canonical.
+type Resolution struct {
+	Status      int        `json:"status"`
+	Type        EntityType `json:"type"`
+	ID          uuid.UUID  `json:"id"`
+	Slug        string     `json:"slug"`
+	Canonical   string     `json:"canonical"`
+	Breadcrumbs []Crumb    `json:"breadcrumbs"`
+	Alternates  []string   `json:"alternates"`
+}
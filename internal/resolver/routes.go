@@ -0,0 +1,21 @@
+package resolver
+
+import (
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/resolve", handler.Resolve)
+}
+
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/resolve", Tag: "resolver", Summary: "Resolve a site path to the entity it shows",
+			Query:    []openapi.Param{{Name: "path", Description: "Site path", Schema: &openapi.Schema{Type: "string"}, Required: true}, openapi.Lang},
+			Response: Resolution{}},
+	}
+}
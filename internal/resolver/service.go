@@ -0,0 +1,239 @@
+package resolver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"havamal-api/internal/categories"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/posts"
+
+	"github.com/google/uuid"
+)
+
+const homePath = "/"
+
+type Service interface {
+	Resolve(path string, locale string) (*Resolution, error)
+}
+
+type service struct {
+	postService       posts.Service
+	categoryService   categories.Service
+	navigationService navigation.Service
+}
+
+func NewService(postService posts.Service, categoryService categories.Service, navigationService navigation.Service) Service {
+	return &service{
+		postService:       postService,
+		categoryService:   categoryService,
+		navigationService: navigationService,
+	}
+}
+
+// Resolve maps an incoming path to a published post or a category. Canonical
+// paths are /post/<slug> and /category/<slug>; /<slug>, nested category paths
+// and /category/<category>/<post> resolve too but ask for a redirect.
+// Breadcrumb labels are translated into locale.
+func (s *service) Resolve(path string, locale string) (*Resolution, error) {
+	normalized := normalize(path)
+	segments := strings.Split(strings.Trim(normalized, "/"), "/")
+
+	var resolution *Resolution
+	var err error
+	switch {
+	case normalized == homePath:
+		return nil, ErrNotFound
+	case segments[0] == "post" && len(segments) == 2:
+		resolution, err = s.resolvePost(segments[1], locale)
+	case segments[0] == "category" && len(segments) >= 2:
+		last := segments[len(segments)-1]
+		resolution, err = s.resolveCategory(last, locale)
+		if errors.Is(err, ErrNotFound) && len(segments) >= 3 {
+			resolution, err = s.resolvePost(last, locale)
+		}
+	case len(segments) == 1:
+		resolution, err = s.resolvePost(segments[0], locale)
+		if errors.Is(err, ErrNotFound) {
+			resolution, err = s.resolveCategory(segments[0], locale)
+		}
+	default:
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolution.Status = http.StatusOK
+	if stripQuery(strings.TrimSpace(path)) != resolution.Canonical {
+		resolution.Status = http.StatusMovedPermanently
+	}
+	return resolution, nil
+}
+
+// normalize strips the query string and trailing slashes and lowercases the
+// path, since slugs are always lowercase
+func normalize(path string) string {
+	path = "/" + strings.Trim(stripQuery(strings.TrimSpace(path)), "/")
+	return strings.ToLower(path)
+}
+
+func stripQuery(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func (s *service) resolvePost(slug string, locale string) (*Resolution, error) {
+	post, err := s.postService.GetPostBySlug(slug)
+	if errors.Is(err, posts.ErrNotFound) {
+		// Old slugs resolve to the post, and so redirect to its canonical path
+		if slug, err = s.postService.GetRedirect(slug); err == nil {
+			post, err = s.postService.GetPostBySlug(slug)
+		}
+	}
+	if errors.Is(err, posts.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if post.Status != posts.Published {
+		return nil, ErrNotFound
+	}
+
+	canonical := navigation.PostPath + post.Slug
+	categoryPath := navigation.CategoryPath + post.CategorySlug
+	alternates := []string{homePath + post.Slug, categoryPath + "/" + post.Slug}
+
+	crumbs, err := s.trail(canonical, locale)
+	if err != nil {
+		return nil, err
+	}
+	if crumbs == nil {
+		crumbs, err = s.categoryTrail(post.CategoryId, locale)
+		if err != nil {
+			return nil, err
+		}
+		crumbs = append(crumbs, Crumb{Label: post.Title, Path: canonical})
+	}
+
+	return &Resolution{
+		Type:        EntityPost,
+		ID:          post.ID,
+		Slug:        post.Slug,
+		Canonical:   canonical,
+		Breadcrumbs: crumbs,
+		Alternates:  alternates,
+	}, nil
+}
+
+func (s *service) resolveCategory(slug string, locale string) (*Resolution, error) {
+	category, err := s.categoryService.GetBySlug(slug, locale)
+	if errors.Is(err, categories.ErrNotFound) {
+		if slug, err = s.categoryService.GetRedirect(slug); err == nil {
+			category, err = s.categoryService.GetBySlug(slug, locale)
+		}
+	}
+	if errors.Is(err, categories.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	canonical := navigation.CategoryPath + category.Slug
+	crumbs, err := s.categoryTrail(category.ID, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	// The nested path mirrors the category hierarchy, e.g. /category/a/b/c
+	nested := make([]string, 0, len(crumbs))
+	for _, crumb := range crumbs[1:] {
+		nested = append(nested, strings.TrimPrefix(crumb.Path, navigation.CategoryPath))
+	}
+	alternates := []string{homePath + category.Slug}
+	if len(nested) > 1 {
+		alternates = append(alternates, navigation.CategoryPath+strings.Join(nested, "/"))
+	}
+
+	if navCrumbs, err := s.trail(canonical, locale); err != nil {
+		return nil, err
+	} else if navCrumbs != nil {
+		crumbs = navCrumbs
+	}
+
+	return &Resolution{
+		Type:        EntityCategory,
+		ID:          category.ID,
+		Slug:        category.Slug,
+		Canonical:   canonical,
+		Breadcrumbs: crumbs,
+		Alternates:  alternates,
+	}, nil
+}
+
+// trail returns the breadcrumbs of the global navigation item linking to path,
+// or nil when no item does
+func (s *service) trail(path string, locale string) ([]Crumb, error) {
+	tree, err := s.navigationService.GetTree(locale)
+	if err != nil {
+		return nil, err
+	}
+	chain := findHref(tree, path)
+	if chain == nil {
+		return nil, nil
+	}
+	crumbs := []Crumb{{Label: i18n.Label(i18n.LabelHome, locale), Path: homePath}}
+	for _, node := range chain {
+		crumbs = append(crumbs, Crumb{Label: node.Label, Path: node.Href})
+	}
+	return crumbs, nil
+}
+
+// findHref returns the nodes from a root down to the one pointing at href
+func findHref(nodes []navigation.TreeNode, href string) []navigation.TreeNode {
+	for _, node := range nodes {
+		if node.Href == href {
+			return []navigation.TreeNode{node}
+		}
+		if chain := findHref(node.Children, href); chain != nil {
+			return append([]navigation.TreeNode{node}, chain...)
+		}
+	}
+	return nil
+}
+
+// categoryTrail returns the breadcrumbs from the home page down to a category
+// through its ancestors
+func (s *service) categoryTrail(id uuid.UUID, locale string) ([]Crumb, error) {
+	all, err := s.categoryService.GetAll(locale, categories.Fields.All())
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uuid.UUID]categories.Category, len(all))
+	for _, category := range all {
+		byId[category.ID] = category
+	}
+
+	chain := make([]Crumb, 0)
+	seen := make(map[uuid.UUID]bool)
+	for current, ok := byId[id]; ok && !seen[current.ID]; {
+		seen[current.ID] = true
+		chain = append(chain, Crumb{Label: current.Name, Path: navigation.CategoryPath + current.Slug})
+		if current.ParentId == nil {
+			break
+		}
+		current, ok = byId[*current.ParentId]
+	}
+
+	crumbs := []Crumb{{Label: i18n.Label(i18n.LabelHome, locale), Path: homePath}}
+	for i := len(chain) - 1; i >= 0; i-- {
+		crumbs = append(crumbs, chain[i])
+	}
+	return crumbs, nil
+}
@@ -0,0 +1,180 @@
+package resolver
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"havamal-api/internal/categories"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/posts"
+
+	"github.com/google/uuid"
+)
+
+type fakePosts struct {
+	posts.Service
+	posts     []posts.Response
+	redirects map[string]string
+}
+
+func (f fakePosts) GetPostBySlug(slug string) (*posts.Response, error) {
+	for _, post := range f.posts {
+		if post.Slug == slug {
+			return &post, nil
+		}
+	}
+	return nil, posts.ErrNotFound
+}
+
+func (f fakePosts) GetRedirect(oldSlug string) (string, error) {
+	if slug, ok := f.redirects[oldSlug]; ok {
+		return slug, nil
+	}
+	return "", posts.ErrNotFound
+}
+
+type fakeCategories struct {
+	categories.Service
+	categories []categories.Category
+	redirects  map[string]string
+}
+
+func (f fakeCategories) GetBySlug(slug string, locale string) (*categories.Category, error) {
+	for _, category := range f.categories {
+		if category.Slug == slug {
+			return &category, nil
+		}
+	}
+	return nil, categories.ErrNotFound
+}
+
+func (f fakeCategories) GetRedirect(oldSlug string) (string, error) {
+	if slug, ok := f.redirects[oldSlug]; ok {
+		return slug, nil
+	}
+	return "", categories.ErrNotFound
+}
+
+func (f fakeCategories) GetAll(locale string, selection fields.Selection) ([]categories.Category, error) {
+	return f.categories, nil
+}
+
+type fakeNavigation struct {
+	navigation.Service
+	tree []navigation.TreeNode
+}
+
+func (f fakeNavigation) GetTree(locale string) ([]navigation.TreeNode, error) {
+	return f.tree, nil
+}
+
+func newTestService(tree ...navigation.TreeNode) *service {
+	eddas := categories.Category{ID: uuid.New(), Name: "Eddas", Slug: "eddas"}
+	poetic := categories.Category{ID: uuid.New(), Name: "Poetic Edda", Slug: "poetic", ParentId: &eddas.ID}
+	return &service{
+		postService: fakePosts{
+			posts: []posts.Response{
+				{ID: uuid.New(), Title: "Hávamál", Slug: "havamal", Status: posts.Published, CategoryId: poetic.ID, CategorySlug: "poetic"},
+				{ID: uuid.New(), Title: "Draft", Slug: "draft", Status: posts.Draft, CategoryId: poetic.ID, CategorySlug: "poetic"},
+			},
+			redirects: map[string]string{"sayings-of-the-high-one": "havamal"},
+		},
+		categoryService: fakeCategories{
+			categories: []categories.Category{eddas, poetic},
+			redirects:  map[string]string{"edda": "eddas"},
+		},
+		navigationService: fakeNavigation{tree: tree},
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := newTestService()
+	tests := []struct {
+		path      string
+		status    int
+		entity    EntityType
+		canonical string
+	}{
+		{"/post/havamal", http.StatusOK, EntityPost, "/post/havamal"},
+		{"/post/havamal?ref=feed", http.StatusOK, EntityPost, "/post/havamal"},
+		{"/post/havamal/", http.StatusMovedPermanently, EntityPost, "/post/havamal"},
+		{"/Post/Havamal", http.StatusMovedPermanently, EntityPost, "/post/havamal"},
+		{"/havamal", http.StatusMovedPermanently, EntityPost, "/post/havamal"},
+		{"/post/sayings-of-the-high-one", http.StatusMovedPermanently, EntityPost, "/post/havamal"},
+		{"/category/poetic/havamal", http.StatusMovedPermanently, EntityPost, "/post/havamal"},
+		{"/category/eddas", http.StatusOK, EntityCategory, "/category/eddas"},
+		{"/category/eddas/poetic", http.StatusMovedPermanently, EntityCategory, "/category/poetic"},
+		{"/category/edda", http.StatusMovedPermanently, EntityCategory, "/category/eddas"},
+		{"/eddas", http.StatusMovedPermanently, EntityCategory, "/category/eddas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resolution, err := s.Resolve(tt.path, "en")
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if resolution.Status != tt.status || resolution.Type != tt.entity || resolution.Canonical != tt.canonical {
+				t.Errorf("Resolve() = %d %s %s, want %d %s %s", resolution.Status, resolution.Type, resolution.Canonical, tt.status, tt.entity, tt.canonical)
+			}
+		})
+	}
+}
+
+func TestResolveNotFound(t *testing.T) {
+	s := newTestService()
+	for _, path := range []string{"/", "/post/draft", "/post/loki", "/category/loki", "/loki", "/a/b", "/post/a/b"} {
+		t.Run(path, func(t *testing.T) {
+			if _, err := s.Resolve(path, "en"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Resolve() error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	// Without a navigation item, the trail follows the categories
+	resolution := mustResolve(t, newTestService(), "/post/havamal", "es")
+	want := []Crumb{
+		{Label: "Inicio", Path: "/"},
+		{Label: "Eddas", Path: "/category/eddas"},
+		{Label: "Poetic Edda", Path: "/category/poetic"},
+		{Label: "Hávamál", Path: "/post/havamal"},
+	}
+	if !reflect.DeepEqual(resolution.Breadcrumbs, want) {
+		t.Errorf("breadcrumbs = %+v, want %+v", resolution.Breadcrumbs, want)
+	}
+	if alternates := mustResolve(t, newTestService(), "/category/poetic", "en").Alternates; !reflect.DeepEqual(alternates, []string{"/poetic", "/category/eddas/poetic"}) {
+		t.Errorf("alternates = %v, want the short and the nested path", alternates)
+	}
+
+	// A navigation item linking to the page gives the trail instead
+	tree := []navigation.TreeNode{{
+		Navigation: navigation.Navigation{Label: "Poems"},
+		Href:       "/category/eddas",
+		Children: []navigation.TreeNode{{
+			Navigation: navigation.Navigation{Label: "The High One"},
+			Href:       "/post/havamal",
+		}},
+	}}
+	resolution = mustResolve(t, newTestService(tree...), "/post/havamal", "en")
+	want = []Crumb{
+		{Label: "Home", Path: "/"},
+		{Label: "Poems", Path: "/category/eddas"},
+		{Label: "The High One", Path: "/post/havamal"},
+	}
+	if !reflect.DeepEqual(resolution.Breadcrumbs, want) {
+		t.Errorf("breadcrumbs = %+v, want %+v", resolution.Breadcrumbs, want)
+	}
+}
+
+func mustResolve(t *testing.T, s *service, path string, locale string) *Resolution {
+	t.Helper()
+	resolution, err := s.Resolve(path, locale)
+	if err != nil {
+		t.Fatalf("Resolve(%s) error = %v", path, err)
+	}
+	return resolution
+}
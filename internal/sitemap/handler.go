@@ -0,0 +1,25 @@
+package sitemap
+
+import (
+	"havamal-api/internal/apierror"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func (h *Handler) GetSitemap(c *gin.Context) {
+	set, err := h.service.Build()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.XML(http.StatusOK, set)
+}
@@ -0,0 +1,20 @@
+package sitemap
+
+import (
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/sitemap.xml", handler.GetSitemap)
+}
+
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/sitemap.xml", Tag: "sitemap", Summary: "Get the sitemap with the translations of each page",
+			Response: &openapi.Schema{Type: "string"}, Media: "application/xml"},
+	}
+}
@@ -0,0 +1,78 @@
+package sitemap
+
+import (
+	"havamal-api/internal/categories"
+	"havamal-api/internal/i18n"
+	"havamal-api/internal/navigation"
+	"havamal-api/internal/posts"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Build() (*URLSet, error)
+}
+
+type service struct {
+	postService     posts.Service
+	categoryService categories.Service
+	siteURL         string
+}
+
+func NewService(postService posts.Service, categoryService categories.Service, siteURL string) Service {
+	return &service{
+		postService:     postService,
+		categoryService: categoryService,
+		siteURL:         siteURL,
+	}
+}
+
+// Build lists every published post once, in every locale and with its
+// translations as hreflang alternates, followed by every category
+func (s *service) Build() (*URLSet, error) {
+	published, err := s.postService.GetPublishedEntries()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]posts.Response, 0, len(published))
+	groups := make(map[uuid.UUID][]posts.Alternate)
+	seen := make(map[uuid.UUID]bool, len(published))
+	for _, post := range published {
+		if seen[post.ID] {
+			continue
+		}
+		seen[post.ID] = true
+		entries = append(entries, post)
+		groups[post.TranslationGroup] = append(groups[post.TranslationGroup], posts.Alternate{Hreflang: post.Locale, Slug: post.Slug})
+	}
+
+	set := &URLSet{Xmlns: sitemapNamespace, Xhtml: xhtmlNamespace, URLs: make([]URL, 0, len(entries))}
+	for _, post := range entries {
+		translations := groups[post.TranslationGroup]
+		sort.Slice(translations, func(i, j int) bool {
+			return translations[i].Hreflang < translations[j].Hreflang
+		})
+		url := URL{
+			Loc:     s.siteURL + navigation.PostPath + post.Slug,
+			LastMod: post.UpdatedAt.Format(time.RFC3339),
+		}
+		for _, alternate := range posts.Alternates(translations) {
+			url.Alternates = append(url.Alternates, Link{Rel: "alternate", Hreflang: alternate.Hreflang, Href: s.siteURL + alternate.Href})
+		}
+		set.URLs = append(set.URLs, url)
+	}
+
+	all, err := s.categoryService.GetAll(i18n.Default, categories.Fields.All())
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range all {
+		set.URLs = append(set.URLs, URL{
+			Loc:     s.siteURL + navigation.CategoryPath + category.Slug,
+			LastMod: category.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	return set, nil
+}
@@ -0,0 +1,82 @@
+package sitemap
+
+import (
+	"testing"
+	"time"
+
+	"havamal-api/internal/categories"
+	"havamal-api/internal/fields"
+	"havamal-api/internal/posts"
+
+	"github.com/google/uuid"
+)
+
+type fakePosts struct {
+	posts.Service
+	entries []posts.Response
+}
+
+func (f fakePosts) GetPublishedEntries() ([]posts.Response, error) {
+	return f.entries, nil
+}
+
+type fakeCategories struct {
+	categories.Service
+	all []categories.Category
+}
+
+func (f fakeCategories) GetAll(locale string, selection fields.Selection) ([]categories.Category, error) {
+	return f.all, nil
+}
+
+func TestBuild(t *testing.T) {
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	group, single := uuid.New(), uuid.New()
+	catalan := posts.Response{ID: uuid.New(), Slug: "hola", Locale: "ca", TranslationGroup: group, UpdatedAt: updated}
+	english := posts.Response{ID: uuid.New(), Slug: "hello", Locale: "en", TranslationGroup: group, UpdatedAt: updated}
+	alone := posts.Response{ID: uuid.New(), Slug: "sol", Locale: "ca", TranslationGroup: single, UpdatedAt: updated}
+
+	tests := []struct {
+		name       string
+		entries    []posts.Response
+		wantLocs   []string
+		alternates map[string]int
+	}{
+		{
+			name:       "one url per post",
+			entries:    []posts.Response{catalan, english, alone},
+			wantLocs:   []string{"https://blog.test/post/hola", "https://blog.test/post/hello", "https://blog.test/post/sol", "https://blog.test/category/news"},
+			alternates: map[string]int{"https://blog.test/post/hola": 3, "https://blog.test/post/hello": 3, "https://blog.test/post/sol": 0},
+		},
+		{
+			name:       "repeated rows are listed once",
+			entries:    []posts.Response{catalan, catalan, english, catalan, alone, alone},
+			wantLocs:   []string{"https://blog.test/post/hola", "https://blog.test/post/hello", "https://blog.test/post/sol", "https://blog.test/category/news"},
+			alternates: map[string]int{"https://blog.test/post/hola": 3, "https://blog.test/post/hello": 3, "https://blog.test/post/sol": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(
+				fakePosts{entries: tt.entries},
+				fakeCategories{all: []categories.Category{{Slug: "news", UpdatedAt: updated}}},
+				"https://blog.test",
+			)
+			set, err := s.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if len(set.URLs) != len(tt.wantLocs) {
+				t.Fatalf("Build() listed %d urls, want %d: %+v", len(set.URLs), len(tt.wantLocs), set.URLs)
+			}
+			for i, url := range set.URLs {
+				if url.Loc != tt.wantLocs[i] {
+					t.Errorf("url %d = %s, want %s", i, url.Loc, tt.wantLocs[i])
+				}
+				if want, ok := tt.alternates[url.Loc]; ok && len(url.Alternates) != want {
+					t.Errorf("%s has %d alternates, want %d: %+v", url.Loc, len(url.Alternates), want, url.Alternates)
+				}
+			}
+		})
+	}
+}
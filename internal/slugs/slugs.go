@@ -0,0 +1,69 @@
+package slugs
+
+import (
+	"havamal-api/internal/apierror"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrEmpty = apierror.New(apierror.SlugEmpty)
+	// ErrTaken means another resource already uses the slug
+	ErrTaken = apierror.New(apierror.SlugConflict)
+)
+
+// replacements are applied before accents are stripped, for letters that do
+// not decompose into an ASCII base
+var replacements = strings.NewReplacer(
+	// Catalan geminated l
+	"l·l", "ll", "l.l", "ll", "ŀl", "ll",
+	// Old Norse and Nordic letters
+	"þ", "th", "ð", "d", "æ", "ae", "ø", "o", "œ", "oe", "ß", "ss",
+)
+
+// Make turns text into a URL slug: lowercase ASCII letters and digits
+// separated by single dashes, with accents stripped.
+func Make(text string) string {
+	text = replacements.Replace(strings.ToLower(text))
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err == nil {
+		text = stripped
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range text {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Unique returns base, or base with the first numeric suffix (base-2,
+// base-3, ...) for which taken reports false
+func Unique(base string, taken func(slug string) (bool, error)) (string, error) {
+	if base == "" {
+		return "", ErrEmpty
+	}
+	candidate := base
+	for n := 2; ; n++ {
+		exists, err := taken(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = base + "-" + strconv.Itoa(n)
+	}
+}
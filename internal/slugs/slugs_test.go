@@ -0,0 +1,62 @@
+package slugs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"words", "Hello World", "hello-world"},
+		{"accents", "Què és això?", "que-es-aixo"},
+		{"geminated l", "Col·lecció paral.lela", "colleccio-parallela"},
+		{"nordic letters", "Þórr og Ægir", "thorr-og-aegir"},
+		{"german sharp s", "Straße", "strasse"},
+		{"repeated separators", "  a -- b__c  ", "a-b-c"},
+		{"digits", "Hávamál 2026", "havamal-2026"},
+		{"only symbols", "¡¿!?", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Make(tt.text); got != tt.want {
+				t.Errorf("Make(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnique(t *testing.T) {
+	failure := errors.New("lookup failed")
+	tests := []struct {
+		name    string
+		base    string
+		taken   map[string]bool
+		err     error
+		want    string
+		wantErr error
+	}{
+		{"free", "post", nil, nil, "post", nil},
+		{"first suffix", "post", map[string]bool{"post": true}, nil, "post-2", nil},
+		{"next free suffix", "post", map[string]bool{"post": true, "post-2": true, "post-3": true}, nil, "post-4", nil},
+		{"empty base", "", nil, nil, "", ErrEmpty},
+		{"lookup error", "post", nil, failure, "", failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unique(tt.base, func(slug string) (bool, error) {
+				return tt.taken[slug], tt.err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Unique() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unique() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
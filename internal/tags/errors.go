@@ -0,0 +1,11 @@
+package tags
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound      = apierror.New(apierror.TagNotFound)
+	// ErrTagExists means another tag already uses the slug; merge the tags instead
+	ErrTagExists     = apierror.New(apierror.SlugConflict)
+	ErrMergeIntoSelf = apierror.New(apierror.TagMergeIntoSelf)
+	ErrInvalidName   = apierror.New(apierror.TagInvalidName)
+)
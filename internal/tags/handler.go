@@ -0,0 +1,54 @@
+package tags
+
+import (
+	"havamal-api/internal/apierror"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func (h *Handler) GetAll(c *gin.Context) {
+	tags, err := h.service.GetAll()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+func (h *Handler) Rename(c *gin.Context) {
+	id := c.Param("id")
+	var request RenameRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	tag, err := h.service.Rename(id, &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+func (h *Handler) Merge(c *gin.Context) {
+	id := c.Param("id")
+	var request MergeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.Merge(id, request.TargetId); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag merged successfully"})
+}
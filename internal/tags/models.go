@@ -0,0 +1,29 @@
+package tags
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagCount is a tag with the number of published posts using it
+type TagCount struct {
+	Tag
+	Count int `json:"count"`
+}
+
+type RenameRequest struct {
+	Name string `json:"name" binding:"required"`
+	Slug string `json:"slug"`
+}
+
+type MergeRequest struct {
+	TargetId string `json:"target_id" binding:"required"`
+}
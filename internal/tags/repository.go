@@ -0,0 +1,190 @@
+package tags
+
+import (
+	"database/sql"
+
+	"havamal-api/internal/db"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	FindOrCreate(tx *sql.Tx, name string, slug string) (*Tag, error)
+	GetAll() ([]TagCount, error)
+	GetById(id uuid.UUID) (*Tag, error)
+	GetBySlug(slug string) (*Tag, error)
+	GetByPost(postId uuid.UUID) ([]Tag, error)
+	GetByPosts(postIds []uuid.UUID) (map[uuid.UUID][]Tag, error)
+	SetPostTags(tx *sql.Tx, postId uuid.UUID, tagIds []uuid.UUID) error
+	Update(tag *Tag) error
+	Merge(sourceId uuid.UUID, targetId uuid.UUID) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// translate returns the domain error of a failed tag query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, ErrTagExists)
+}
+
+// FindOrCreate returns the tag with the slug, creating it with the name if
+// it does not exist yet, within the transaction saving a post
+func (r *repository) FindOrCreate(tx *sql.Tx, name string, slug string) (*Tag, error) {
+	query := `INSERT INTO tags (id, name, slug, created_at)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+	RETURNING id, name, slug, created_at`
+	var tag Tag
+	if err := tx.QueryRow(query, uuid.New(), name, slug).Scan(&tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt); err != nil {
+		return nil, translate(err)
+	}
+	return &tag, nil
+}
+
+func (r *repository) GetAll() ([]TagCount, error) {
+	query := `SELECT t.id, t.name, t.slug, t.created_at, COUNT(p.id)
+	FROM tags t
+		LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		LEFT JOIN posts p ON pt.post_id = p.id AND p.status = 'published'
+	GROUP BY t.id
+	ORDER BY t.name`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (r *repository) GetById(id uuid.UUID) (*Tag, error) {
+	query := `SELECT id, name, slug, created_at FROM tags WHERE id = $1`
+	var tag Tag
+	if err := r.db.QueryRow(query, id).Scan(&tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt); err != nil {
+		return nil, translate(err)
+	}
+	return &tag, nil
+}
+
+func (r *repository) GetBySlug(slug string) (*Tag, error) {
+	query := `SELECT id, name, slug, created_at FROM tags WHERE slug = $1`
+	var tag Tag
+	if err := r.db.QueryRow(query, slug).Scan(&tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt); err != nil {
+		return nil, translate(err)
+	}
+	return &tag, nil
+}
+
+func (r *repository) GetByPost(postId uuid.UUID) ([]Tag, error) {
+	query := `SELECT t.id, t.name, t.slug, t.created_at
+	FROM post_tags pt
+		INNER JOIN tags t ON pt.tag_id = t.id
+	WHERE pt.post_id = $1
+	ORDER BY t.name`
+	rows, err := r.db.Query(query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make([]Tag, 0)
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// GetByPosts returns the tags of several posts in one query, by post id
+func (r *repository) GetByPosts(postIds []uuid.UUID) (map[uuid.UUID][]Tag, error) {
+	keys := make([]string, len(postIds))
+	for i, id := range postIds {
+		keys[i] = id.String()
+	}
+	query := `SELECT pt.post_id, t.id, t.name, t.slug, t.created_at
+	FROM post_tags pt
+		INNER JOIN tags t ON pt.tag_id = t.id
+	WHERE pt.post_id::text = ANY($1)
+	ORDER BY t.name`
+	rows, err := r.db.Query(query, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make(map[uuid.UUID][]Tag, len(postIds))
+	for rows.Next() {
+		var postId uuid.UUID
+		var tag Tag
+		if err := rows.Scan(&postId, &tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags[postId] = append(tags[postId], tag)
+	}
+	return tags, nil
+}
+
+// SetPostTags replaces the tags of a post within the transaction saving it
+func (r *repository) SetPostTags(tx *sql.Tx, postId uuid.UUID, tagIds []uuid.UUID) error {
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id = $1`, postId); err != nil {
+		return err
+	}
+	for _, tagId := range tagIds {
+		if _, err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, postId, tagId); err != nil {
+			return translate(err)
+		}
+	}
+	return nil
+}
+
+func (r *repository) Update(tag *Tag) error {
+	query := `UPDATE tags SET name = $2, slug = $3 WHERE id = $1`
+	result, err := r.db.Exec(query, tag.ID, tag.Name, tag.Slug)
+	if err != nil {
+		return translate(err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Merge moves every post of the source tag to the target and deletes the
+// source, in one transaction. ErrNotFound means the source does not exist.
+func (r *repository) Merge(sourceId uuid.UUID, targetId uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	move := `INSERT INTO post_tags (post_id, tag_id)
+	SELECT post_id, $2 FROM post_tags WHERE tag_id = $1
+	ON CONFLICT DO NOTHING`
+	if _, err := tx.Exec(move, sourceId, targetId); err != nil {
+		return translate(err)
+	}
+	result, err := tx.Exec(`DELETE FROM tags WHERE id = $1`, sourceId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,32 @@
+package tags
+
+import (
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.PUT("/tags/:id", handler.Rename)
+	router.POST("/tags/:id/merge", handler.Merge)
+}
+
+func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/tags", handler.GetAll)
+}
+
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "PUT", Path: "/tags/:id", Tag: "tags", Summary: "Rename a tag", Body: RenameRequest{}, Response: Tag{}},
+		{Method: "POST", Path: "/tags/:id/merge", Tag: "tags", Summary: "Merge a tag into another", Body: MergeRequest{}},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/tags", Tag: "tags", Summary: "List tags with their published post counts", Response: []TagCount{}},
+	}
+}
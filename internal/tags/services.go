@@ -0,0 +1,125 @@
+package tags
+
+import (
+	"database/sql"
+	"errors"
+	"havamal-api/internal/db"
+	"havamal-api/internal/slugs"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	GetAll() ([]TagCount, error)
+	GetBySlug(slug string) (*Tag, error)
+	GetByPost(postId uuid.UUID) ([]Tag, error)
+	GetByPosts(postIds []uuid.UUID) (map[uuid.UUID][]Tag, error)
+	// PostTags is the step replacing the tags of a post, which the posts
+	// repository runs in the transaction saving the post
+	PostTags(postId uuid.UUID, names []string) db.Step
+	Rename(id string, request *RenameRequest) (*Tag, error)
+	Merge(id string, targetId string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) GetAll() ([]TagCount, error) {
+	return s.repo.GetAll()
+}
+
+func (s *service) GetBySlug(slug string) (*Tag, error) {
+	return s.repo.GetBySlug(slug)
+}
+
+func (s *service) GetByPost(postId uuid.UUID) ([]Tag, error) {
+	return s.repo.GetByPost(postId)
+}
+
+func (s *service) GetByPosts(postIds []uuid.UUID) (map[uuid.UUID][]Tag, error) {
+	return s.repo.GetByPosts(postIds)
+}
+
+// PostTags replaces the tags of a post, creating missing tags. Names are
+// normalised right away, and names that normalise to the same slug are the
+// same tag.
+func (s *service) PostTags(postId uuid.UUID, names []string) db.Step {
+	wanted := make([]Tag, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := slugs.Make(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		wanted = append(wanted, Tag{Name: name, Slug: slug})
+	}
+	return func(tx *sql.Tx) error {
+		tagIds := make([]uuid.UUID, 0, len(wanted))
+		for _, tag := range wanted {
+			found, err := s.repo.FindOrCreate(tx, tag.Name, tag.Slug)
+			if err != nil {
+				return err
+			}
+			tagIds = append(tagIds, found.ID)
+		}
+		return s.repo.SetPostTags(tx, postId, tagIds)
+	}
+}
+
+func (s *service) Rename(id string, request *RenameRequest) (*Tag, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	slug := slugs.Make(request.Slug)
+	if slug == "" {
+		slug = slugs.Make(request.Name)
+	}
+	if slug == "" {
+		return nil, ErrInvalidName
+	}
+	existing, err := s.repo.GetBySlug(slug)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if existing != nil && existing.ID != parsedId {
+		return nil, ErrTagExists
+	}
+
+	tag, err := s.repo.GetById(parsedId)
+	if err != nil {
+		return nil, err
+	}
+	tag.Name = strings.TrimSpace(request.Name)
+	tag.Slug = slug
+	if err := s.repo.Update(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *service) Merge(id string, targetId string) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	parsedTargetId, err := uuid.Parse(targetId)
+	if err != nil {
+		return err
+	}
+	if parsedId == parsedTargetId {
+		return ErrMergeIntoSelf
+	}
+	if _, err := s.repo.GetById(parsedTargetId); err != nil {
+		return err
+	}
+	return s.repo.Merge(parsedId, parsedTargetId)
+}
@@ -0,0 +1,158 @@
+package tags
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type fakeRepository struct {
+	Repository
+	tags    map[string]*Tag
+	created []string
+	set     []uuid.UUID
+	updated *Tag
+	merged  bool
+}
+
+func newFakeRepository(existing ...Tag) *fakeRepository {
+	f := &fakeRepository{tags: make(map[string]*Tag)}
+	for _, tag := range existing {
+		f.tags[tag.Slug] = &tag
+	}
+	return f
+}
+
+func (f *fakeRepository) FindOrCreate(tx *sql.Tx, name string, slug string) (*Tag, error) {
+	if tag, ok := f.tags[slug]; ok {
+		return tag, nil
+	}
+	tag := &Tag{ID: uuid.New(), Name: name, Slug: slug}
+	f.tags[slug] = tag
+	f.created = append(f.created, name)
+	return tag, nil
+}
+
+func (f *fakeRepository) GetById(id uuid.UUID) (*Tag, error) {
+	for _, tag := range f.tags {
+		if tag.ID == id {
+			found := *tag
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeRepository) GetBySlug(slug string) (*Tag, error) {
+	if tag, ok := f.tags[slug]; ok {
+		return tag, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeRepository) SetPostTags(tx *sql.Tx, postId uuid.UUID, tagIds []uuid.UUID) error {
+	f.set = tagIds
+	return nil
+}
+
+func (f *fakeRepository) Update(tag *Tag) error {
+	f.updated = tag
+	return nil
+}
+
+func (f *fakeRepository) Merge(sourceId uuid.UUID, targetId uuid.UUID) error {
+	if _, err := f.GetById(sourceId); err != nil {
+		return err
+	}
+	f.merged = true
+	return nil
+}
+
+func TestPostTags(t *testing.T) {
+	existing := Tag{ID: uuid.New(), Name: "Runes", Slug: "runes"}
+	repository := newFakeRepository(existing)
+	names := []string{" Hávamál ", "runes", "HAVAMAL", "", "  ", "Òdin"}
+	step := NewService(repository).PostTags(uuid.New(), names)
+	// Nothing is written until the posts repository runs the step
+	if len(repository.created) != 0 || repository.set != nil {
+		t.Fatal("PostTags() wrote before its step ran")
+	}
+	if err := step(nil); err != nil {
+		t.Fatalf("PostTags() step error = %v", err)
+	}
+	// Names are trimmed, blank ones skipped and those sharing a slug are one tag
+	if want := []string{"Hávamál", "Òdin"}; !reflect.DeepEqual(repository.created, want) {
+		t.Errorf("PostTags() created %v, want %v", repository.created, want)
+	}
+	want := []uuid.UUID{repository.tags["havamal"].ID, existing.ID, repository.tags["odin"].ID}
+	if !reflect.DeepEqual(repository.set, want) {
+		t.Errorf("PostTags() set %v, want %v", repository.set, want)
+	}
+}
+
+func TestRename(t *testing.T) {
+	runes := Tag{ID: uuid.New(), Name: "Runes", Slug: "runes"}
+	odin := Tag{ID: uuid.New(), Name: "Odin", Slug: "odin"}
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		request  RenameRequest
+		wantSlug string
+		wantErr  error
+	}{
+		{"slug from the name", runes.ID, RenameRequest{Name: " Rúnar "}, "runar", nil},
+		{"given slug", runes.ID, RenameRequest{Name: "Rúnar", Slug: "Old Runes"}, "old-runes", nil},
+		{"same slug", runes.ID, RenameRequest{Name: "RUNES"}, "runes", nil},
+		{"slug of another tag", runes.ID, RenameRequest{Name: "Odin"}, "", ErrTagExists},
+		{"nothing to slug", runes.ID, RenameRequest{Name: "!!"}, "", ErrInvalidName},
+		{"missing tag", uuid.New(), RenameRequest{Name: "Loki"}, "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newFakeRepository(runes, odin)
+			tag, err := NewService(repository).Rename(tt.id.String(), &tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rename() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if repository.updated != nil {
+					t.Error("Rename() stored a refused name")
+				}
+				return
+			}
+			if tag.Slug != tt.wantSlug || repository.updated == nil || repository.updated.Slug != tt.wantSlug {
+				t.Errorf("Rename() = %+v, want slug %s", tag, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	runes := Tag{ID: uuid.New(), Name: "Runes", Slug: "runes"}
+	odin := Tag{ID: uuid.New(), Name: "Odin", Slug: "odin"}
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		targetId uuid.UUID
+		wantErr  error
+	}{
+		{"into another tag", runes.ID, odin.ID, nil},
+		{"into itself", runes.ID, runes.ID, ErrMergeIntoSelf},
+		{"into a missing tag", runes.ID, uuid.New(), ErrNotFound},
+		{"from a missing tag", uuid.New(), odin.ID, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newFakeRepository(runes, odin)
+			if err := NewService(repository).Merge(tt.id.String(), tt.targetId.String()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if repository.merged != (tt.wantErr == nil) {
+				t.Errorf("Merge() merged = %v", repository.merged)
+			}
+		})
+	}
+}
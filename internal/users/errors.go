@@ -0,0 +1,9 @@
+package users
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound          = apierror.New(apierror.UserNotFound)
+	ErrMissingAdminClaim = apierror.New(apierror.AuthUnauthorized)
+	ErrNotAdmin          = apierror.New(apierror.AuthForbidden)
+)
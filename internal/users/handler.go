@@ -1,6 +1,8 @@
 package users
 
 import (
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/patch"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -16,15 +18,15 @@ func NewHandler(service Service) Handler {
 
 func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
-	var request UserRequest
+	var request CreateUserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
 		return
 	}
 
-	response, err := h.service.Create(ctx, request)
+	response, err := h.service.Create(ctx, request.user())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully", "data": response})
@@ -41,7 +43,7 @@ func (h *Handler) FindAll(c *gin.Context) {
 	
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Users found successfully", "data": response})
@@ -52,11 +54,7 @@ func (h *Handler) FindByID(c *gin.Context) {
 	id := c.Param("id")
 	response, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User found successfully", "data": response})
@@ -67,12 +65,36 @@ func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request UserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Invalid(c, err)
+		return
+	}
+	response, err := h.service.Update(ctx, id, request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "data": response})
+}
+
+// Patch applies a merge patch or JSON Patch to the user and answers with the
+// updated user. Members the patch leaves out keep their current value; the
+// password only changes when the patch sets one.
+func (h *Handler) Patch(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	current, err := h.service.FindByID(ctx, id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	request := current.request()
+	if err := patch.Apply(c, &request); err != nil {
+		apierror.Invalid(c, err)
 		return
 	}
 	response, err := h.service.Update(ctx, id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "data": response})
@@ -83,7 +105,7 @@ func (h *Handler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	err := h.service.Delete(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
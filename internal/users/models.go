@@ -17,9 +17,27 @@ type User struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// request returns the user as the body of a full update, for patches to apply
+// to. The password is left out, which keeps it.
+func (u *User) request() UserRequest {
+	return UserRequest{Username: u.Username, Email: u.Email, IsActive: u.IsActive}
+}
+
 type UserRequest struct {	
-	Username   string `json:"username"`
-	Email      string `json:"email"`
-	Password   string `json:"password"`	
+	Username   string `json:"username" binding:"required,max=50"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"omitempty,min=8,max=72"`	
 	IsActive   bool   `json:"is_active"`
-}
\ No newline at end of file
+}
+// CreateUserRequest is a UserRequest whose password is required; updates may
+// omit it to keep the current one
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+	IsActive bool   `json:"is_active"`
+}
+
+func (r CreateUserRequest) user() UserRequest {
+	return UserRequest{Username: r.Username, Email: r.Email, Password: r.Password, IsActive: r.IsActive}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 
+	"havamal-api/internal/db"
+
 	"github.com/google/uuid"
 )
 
@@ -24,6 +26,11 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
+// translate returns the domain error of a failed user query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
 func (r *repository) Create(ctx context.Context, user User) (User, error) {
 	query := `INSERT INTO users (id, username, email, password, 
 						is_admin, is_active, created_at, updated_at) 
@@ -31,7 +38,7 @@ func (r *repository) Create(ctx context.Context, user User) (User, error) {
 	_, err := r.db.ExecContext(ctx, query, user.ID, user.Username, user.Email, user.Password, 
 		user.IsAdmin, user.IsActive, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
-		return User{}, err
+		return User{}, translate(err)
 	}
 	return user, nil
 }
@@ -61,7 +68,7 @@ func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (User, error) {
 	row := r.db.QueryRowContext(ctx, query, id)
 	var user User
 	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
-		return User{}, err
+		return User{}, translate(err)
 	}
 	return user, nil
 }
@@ -72,7 +79,7 @@ func (r *repository) FindByEmail(ctx context.Context, email string) (User, error
 	row := r.db.QueryRowContext(ctx, query, email)
 	var user User
 	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
-		return User{}, err
+		return User{}, translate(err)
 	}
 	return user, nil
 }
@@ -81,7 +88,7 @@ func (r *repository) Update(ctx context.Context, user User) (User, error) {
 	query := `UPDATE users SET username = $2, email = $3, password = $4, is_admin = $5, is_active = $6, updated_at = $7 WHERE id = $1 RETURNING id`
 	_, err := r.db.ExecContext(ctx, query, user.ID, user.Username, user.Email, user.Password, user.IsAdmin, user.IsActive, user.UpdatedAt)
 	if err != nil {
-		return User{}, err
+		return User{}, translate(err)
 	}
 	return user, nil
 }
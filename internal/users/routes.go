@@ -1,11 +1,38 @@
 package users
 
-import "github.com/gin-gonic/gin"
+import (
+	"havamal-api/internal/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/users", handler.Create)
 	router.GET("/users", handler.FindAll)
 	router.GET("/users/:id", handler.FindByID)
 	router.PUT("/users/:id", handler.Update)
+	router.PATCH("/users/:id", handler.Patch)
 	router.DELETE("/users/:id", handler.Delete)
-}
\ No newline at end of file
+}
+
+// Operations describes the routes of RegisterRoutes. Answers wrap the users
+// in data, next to a message.
+func Operations() []openapi.Operation {
+	type userResponse struct {
+		Message string `json:"message"`
+		Data    User   `json:"data"`
+	}
+	type usersResponse struct {
+		Message string `json:"message"`
+		Data    []User `json:"data"`
+	}
+	return []openapi.Operation{
+		{Method: "POST", Path: "/users", Tag: "users", Summary: "Create a user", Body: CreateUserRequest{}, Response: userResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/users", Tag: "users", Summary: "List users", Response: usersResponse{}},
+		{Method: "GET", Path: "/users/:id", Tag: "users", Summary: "Get a user", Response: userResponse{}},
+		{Method: "PUT", Path: "/users/:id", Tag: "users", Summary: "Replace a user", Description: "An empty password keeps the current one.", Body: UserRequest{}, Response: userResponse{}},
+		{Method: "PATCH", Path: "/users/:id", Tag: "users", Summary: "Patch a user", Body: UserRequest{}, Response: userResponse{}},
+		{Method: "DELETE", Path: "/users/:id", Tag: "users", Summary: "Delete a user"},
+	}
+}
@@ -2,7 +2,6 @@ package users
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,10 +31,10 @@ func (s *service) Create(ctx context.Context, request UserRequest) (User, error)
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return User{}, errors.New("invalid or missing is_admin in context")
+		return User{}, ErrMissingAdminClaim
 	}
 	if !isAdmin{
-		return User{}, errors.New("user is not admin")
+		return User{}, ErrNotAdmin
 	}
 	
 	// Hash the password
@@ -62,12 +61,12 @@ func (s *service) FindAll(ctx context.Context) ([]User, error) {
 	isAdminVal := ctx.Value("is_admin")
 	isAdmin, ok := isAdminVal.(bool)
 	if !ok {
-		return nil, errors.New("invalid or missing is_admin in context")
+		return nil, ErrMissingAdminClaim
 	}
 	if isAdmin{
 		return s.repo.FindAll(ctx)
 	}
-	return nil, errors.New("user is not admin")
+	return nil, ErrNotAdmin
 }
 
 func (s *service) FindByID(ctx context.Context, id string) (User, error) {
@@ -106,7 +105,6 @@ func (s *service) Update(ctx context.Context, id string, request UserRequest) (U
 	}
 
 	user.IsActive = request.IsActive
-	user.IsAdmin = false
 	user.UpdatedAt = time.Now()
 	return s.repo.Update(ctx, user)
 }
@@ -0,0 +1,5 @@
+package versions
+
+import "havamal-api/internal/apierror"
+
+var ErrNotFound = apierror.New(apierror.VersionNotFound)
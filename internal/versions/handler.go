@@ -1,7 +1,10 @@
 package versions
 
 import (
-	"encoding/json"
+	"errors"
+	"havamal-api/internal/apierror"
+	"havamal-api/internal/etag"
+	"havamal-api/internal/patch"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -17,12 +20,12 @@ func NewHandler(service Service) Handler {
 
 func (h *Handler) Create(c *gin.Context) {
 	var request Request
-	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
 		return
 	}
 	if err := h.service.Create(&request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "Version created successfully"})
@@ -31,7 +34,7 @@ func (h *Handler) Create(c *gin.Context) {
 func (h *Handler) GetAll(c *gin.Context) {
 	versions, err := h.service.GetAll()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, versions)
@@ -41,31 +44,89 @@ func (h *Handler) GetById(c *gin.Context) {
 	id := c.Param("id")
 	version, err := h.service.GetById(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, version)
+	etag.JSON(c, version.Revision, version)
 }
 
 func (h *Handler) Update(c *gin.Context) {
 	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
 	var request Request
-	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
 		return
 	}
-	if err := h.service.Update(id, &request); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Update(id, &request, revision); err != nil {
+		h.writeError(c, id, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Version updated successfully"})
+	h.updated(c, id)
+}
+
+// Patch applies a merge patch or JSON Patch to the version and answers with
+// the updated version. Members the patch leaves out keep their current value.
+func (h *Handler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	current, err := h.service.GetById(id)
+	if err != nil {
+		h.writeError(c, id, err)
+		return
+	}
+	request := current.request()
+	if err := patch.Apply(c, &request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	if err := h.service.Update(id, &request, revision); err != nil {
+		h.writeError(c, id, err)
+		return
+	}
+	h.updated(c, id)
+}
+
+// updated answers a write with the version as stored, with its new ETag
+func (h *Handler) updated(c *gin.Context, id string) {
+	version, err := h.service.GetById(id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	etag.Written(c, version.Revision, version)
 }
 
 func (h *Handler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.service.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	revision, ok := etag.RequireIfMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(id, revision); err != nil {
+		h.writeError(c, id, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Version deleted successfully"})
 }
+
+// writeError answers a failed write, with the current version when it was stale
+func (h *Handler) writeError(c *gin.Context, id string, err error) {
+	switch {
+	case errors.Is(err, etag.ErrPreconditionFailed):
+		version, err := h.service.GetById(id)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		etag.Conflict(c, version.Revision, version)
+	default:
+		apierror.Respond(c, err)
+	}
+}
@@ -7,10 +7,11 @@ import (
 )
 
 type Request struct {
-	Version       string    `json:"version"`
-	PostId        string    `json:"post_id"`
-	VersionNumber int       `json:"version_number"`
-	Content       string    `json:"content"`
+	Version       string    `json:"version" binding:"required,max=50"`
+	PostId        string    `json:"post_id" binding:"required,uuid"`
+	VersionNumber int       `json:"version_number" binding:"min=1"`
+	Content       string    `json:"content" binding:"required"`
+	Format        string    `json:"format" binding:"omitempty,oneof=markdown html plaintext"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
@@ -20,5 +21,13 @@ type Version struct {
 	PostId        uuid.UUID `json:"post_id"`
 	VersionNumber int       `json:"version_number"`
 	Content       string    `json:"content"`
+	Format        string    `json:"format"`
 	CreatedAt     time.Time `json:"created_at"`
+	Revision      int       `json:"revision"`
+}
+
+// request returns the version as the body of a full update, for patches to
+// apply to
+func (v *Version) request() Request {
+	return Request{Version: v.Version, PostId: v.PostId.String(), VersionNumber: v.VersionNumber, Content: v.Content, Format: v.Format}
 }
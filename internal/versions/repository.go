@@ -2,6 +2,8 @@ package versions
 
 import (
 	"database/sql"
+	"havamal-api/internal/db"
+	"havamal-api/internal/etag"
 
 	"github.com/google/uuid"
 )
@@ -11,7 +13,7 @@ type Repository interface {
 	GetAll() ([]Version, error)
 	GetById(id uuid.UUID) (*Version, error)
 	Update(id uuid.UUID, version *Version) error
-	Delete(id uuid.UUID) error
+	Delete(id uuid.UUID, revision int) error
 }
 
 type repository struct {
@@ -22,17 +24,23 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
+// translate returns the domain error of a failed version query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
+// postFormat is the format given for a version, or else the one of its post
+const postFormat = `COALESCE(NULLIF($7, ''), (SELECT format FROM posts WHERE id = $3), 'html')`
+
 func (r *repository) Create(version *Version) error {
-	query := `INSERT INTO versions (id, version, post_id, version_number, content, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := r.db.Exec(query, version.ID, version.Version, version.PostId, version.VersionNumber, version.Content, version.CreatedAt)
-	if err != nil {
-		return err
-	}
-	return nil
+	query := `INSERT INTO versions (id, version, post_id, version_number, content, created_at, format)
+	VALUES ($1, $2, $3, $4, $5, $6, ` + postFormat + `)`
+	_, err := r.db.Exec(query, version.ID, version.Version, version.PostId, version.VersionNumber, version.Content, version.CreatedAt, version.Format)
+	return translate(err)
 }
 
 func (r *repository) GetAll() ([]Version, error) {
-	query := `SELECT id, version, post_id, version_number, content, created_at FROM versions`
+	query := `SELECT id, version, post_id, version_number, content, format, created_at, revision FROM versions`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -41,7 +49,7 @@ func (r *repository) GetAll() ([]Version, error) {
 	var versions []Version
 	for rows.Next() {
 		var version Version
-		if err := rows.Scan(&version.ID, &version.Version, &version.PostId, &version.VersionNumber, &version.Content, &version.CreatedAt); err != nil {
+		if err := rows.Scan(&version.ID, &version.Version, &version.PostId, &version.VersionNumber, &version.Content, &version.Format, &version.CreatedAt, &version.Revision); err != nil {
 			return nil, err
 		}
 		versions = append(versions, version)
@@ -50,29 +58,32 @@ func (r *repository) GetAll() ([]Version, error) {
 }
 
 func (r *repository) GetById(id uuid.UUID) (*Version, error) {
-	query := `SELECT id, version, post_id, version_number, content, created_at FROM versions WHERE id = $1`
+	query := `SELECT id, version, post_id, version_number, content, format, created_at, revision FROM versions WHERE id = $1`
 	row := r.db.QueryRow(query, id)
 	var version Version
-	if err := row.Scan(&version.ID, &version.Version, &version.PostId, &version.VersionNumber, &version.Content, &version.CreatedAt); err != nil {
-		return nil, err
+	if err := row.Scan(&version.ID, &version.Version, &version.PostId, &version.VersionNumber, &version.Content, &version.Format, &version.CreatedAt, &version.Revision); err != nil {
+		return nil, translate(err)
 	}
 	return &version, nil
 }
 
+// Update only applies when version.Revision is still the current revision or
+// etag.Any
 func (r *repository) Update(id uuid.UUID, version *Version) error {
-	query := `UPDATE versions SET version = $2, post_id = $3, version_number = $4, content = $5, created_at = $6 WHERE id = $1`
-	_, err := r.db.Exec(query, id, version.Version, version.PostId, version.VersionNumber, version.Content, version.CreatedAt)
+	query := `UPDATE versions SET version = $2, post_id = $3, version_number = $4, content = $5, format = ` + postFormat + `, revision = revision + 1
+	WHERE id = $1 AND ($6 = 0 OR revision = $6)`
+	result, err := r.db.Exec(query, id, version.Version, version.PostId, version.VersionNumber, version.Content, version.Revision, version.Format)
 	if err != nil {
-		return err
+		return translate(err)
 	}
-	return nil
+	return translate(etag.Check(result, r.db, "versions", id))
 }
 
-func (r *repository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM versions WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+func (r *repository) Delete(id uuid.UUID, revision int) error {
+	query := `DELETE FROM versions WHERE id = $1 AND ($2 = 0 OR revision = $2)`
+	result, err := r.db.Exec(query, id, revision)
 	if err != nil {
-		return err
+		return translate(err)
 	}
-	return nil
+	return translate(etag.Check(result, r.db, "versions", id))
 }
@@ -1,14 +1,38 @@
 package versions
 
-import "github.com/gin-gonic/gin"
+import (
+	"havamal-api/internal/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/versions", handler.Create)
 	router.PUT("/versions/:id", handler.Update)
+	router.PATCH("/versions/:id", handler.Patch)
 	router.DELETE("/versions/:id", handler.Delete)
 }
 
 func RegisterPublicRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.GET("/versions", handler.GetAll)
 	router.GET("/versions/:id", handler.GetById)
-}
\ No newline at end of file
+}
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "POST", Path: "/versions", Tag: "versions", Summary: "Create a version", Body: Request{}, Status: http.StatusCreated},
+		{Method: "PUT", Path: "/versions/:id", Tag: "versions", Summary: "Replace a version", Body: Request{}, Response: Version{}, IfMatch: true},
+		{Method: "PATCH", Path: "/versions/:id", Tag: "versions", Summary: "Patch a version", Body: Request{}, Response: Version{}, IfMatch: true},
+		{Method: "DELETE", Path: "/versions/:id", Tag: "versions", Summary: "Delete a version", IfMatch: true},
+	}
+}
+
+// PublicOperations describes the routes of RegisterPublicRoutes
+func PublicOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: "GET", Path: "/versions", Tag: "versions", Summary: "List versions", Response: []Version{}},
+		{Method: "GET", Path: "/versions/:id", Tag: "versions", Summary: "Get a version", Response: Version{}, Cached: true},
+	}
+}
@@ -10,8 +10,8 @@ type Service interface {
 	Create(request *Request) error
 	GetAll() ([]Version, error)
 	GetById(id string) (*Version, error)
-	Update(id string, request *Request) error
-	Delete(id string) error
+	Update(id string, request *Request, revision int) error
+	Delete(id string, revision int) error
 }
 
 type service struct {
@@ -34,6 +34,7 @@ func (s *service) Create(request *Request) error {
 		PostId:       postId,
 		VersionNumber: request.VersionNumber,
 		Content:      request.Content,
+		Format:       request.Format,
 		CreatedAt:    time.Now(),
 	}
 	return s.repo.Create(&version)
@@ -51,7 +52,7 @@ func (s *service) GetById(id string) (*Version, error) {
 	return s.repo.GetById(parsedId)
 }
 
-func (s *service) Update(id string, request *Request) error {
+func (s *service) Update(id string, request *Request, revision int) error {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return err
@@ -66,15 +67,16 @@ func (s *service) Update(id string, request *Request) error {
 		PostId:       parsedPostId,
 		VersionNumber: request.VersionNumber,
 		Content:      request.Content,
-		CreatedAt:    time.Now(),
+		Format:       request.Format,
+		Revision:     revision,
 	}
 	return s.repo.Update(parsedId, &version)
 }
 
-func (s *service) Delete(id string) error {
+func (s *service) Delete(id string, revision int) error {
 	parsedId, err := uuid.Parse(id)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(parsedId)
+	return s.repo.Delete(parsedId, revision)
 }
@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// errPrivateAddress is the attempt error of a delivery the dispatcher refused
+// to connect for
+var errPrivateAddress = errors.New("address is not public")
+
+// sharedSpace is the carrier-grade NAT range of RFC 6598, private in practice
+var sharedSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// public reports whether ip is a public unicast address. Loopback, private
+// (RFC 1918 and unique local), link-local, shared and unspecified addresses
+// are not, so that a webhook cannot make the API call services only it can
+// reach, such as the database or a cloud metadata endpoint.
+func public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedSpace.Contains(ip)
+}
+
+// checkURL refuses a webhook URL whose host is, or resolves to, an address
+// that is not public. A host that does not resolve yet is accepted: the
+// dispatcher checks the address again on every connection.
+func (s *service) checkURL(raw string) error {
+	if s.config.AllowPrivate {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	host := parsed.Hostname()
+	addresses := make([]netip.Addr, 0, 1)
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addresses = append(addresses, ip)
+	} else if resolved, err := net.DefaultResolver.LookupNetIP(context.Background(), "ip", host); err == nil {
+		addresses = append(addresses, resolved...)
+	}
+	for _, ip := range addresses {
+		if !public(ip) {
+			return fmt.Errorf("%w: %s", ErrPrivateURL, host)
+		}
+	}
+	return nil
+}
+
+// transport connects only to public addresses unless config allows private
+// ones. The check runs on the address being dialled, after resolution, so a
+// host that resolves elsewhere than when it was subscribed, or a redirect, is
+// still refused. Proxies are not used, since they would be dialled instead.
+func transport(config Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.AllowPrivate {
+		return transport
+	}
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip, err := netip.ParseAddr(host); err != nil || !public(ip) {
+				return fmt.Errorf("%w: %s", errPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
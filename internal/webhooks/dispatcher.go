@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// batchSize is how many due deliveries are claimed at a time
+	batchSize = 20
+	// responseLimit is how much of a response body the log keeps
+	responseLimit = 1024
+)
+
+// Dispatcher sends the deliveries of the outbox, retrying failures with
+// exponential backoff until they succeed or run out of attempts
+type Dispatcher struct {
+	repository Repository
+	config     Config
+	client     *http.Client
+}
+
+func NewDispatcher(repository Repository, config Config) *Dispatcher {
+	return &Dispatcher{
+		repository: repository,
+		config:     config,
+		client:     &http.Client{Timeout: config.Timeout, Transport: transport(config)},
+	}
+}
+
+// Run polls the outbox until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch sends due deliveries until none is left
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		// The lease outlasts the request, so nobody else claims the delivery
+		// while it is being sent
+		claimed, err := d.repository.ClaimDue(batchSize, 2*d.config.Timeout)
+		if err != nil {
+			slog.Error("Failed to claim webhook deliveries", slog.Any("error", err))
+			return
+		}
+		for _, delivery := range claimed {
+			d.deliver(ctx, delivery)
+		}
+		if len(claimed) < batchSize {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt at a claimed delivery, logs it and settles what
+// comes next
+func (d *Dispatcher) deliver(ctx context.Context, delivery due) {
+	attempt := d.send(ctx, delivery)
+	if err := d.repository.RecordAttempt(&attempt); err != nil {
+		slog.Error("Failed to log webhook attempt", slog.String("delivery_id", delivery.ID.String()), slog.Any("error", err))
+	}
+	var err error
+	switch {
+	case attempt.StatusCode != nil && *attempt.StatusCode < 300:
+		err = d.repository.MarkDelivered(delivery.ID)
+	case delivery.Attempts >= d.config.MaxAttempts:
+		err = d.repository.MarkFailed(delivery.ID)
+	default:
+		err = d.repository.Reschedule(delivery.ID, time.Now().Add(d.backoff(delivery.Attempts)))
+	}
+	if err != nil {
+		slog.Error("Failed to settle webhook delivery", slog.String("delivery_id", delivery.ID.String()), slog.Any("error", err))
+	}
+}
+
+// backoff is the wait after the attempts made so far failed
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.Backoff
+	for i := 1; i < attempts && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.config.MaxBackoff)
+}
+
+// send posts the payload, signed, and reports how it went
+func (d *Dispatcher) send(ctx context.Context, delivery due) (attempt Attempt) {
+	started := time.Now()
+	attempt = Attempt{
+		ID:          uuid.New(),
+		DeliveryId:  delivery.ID,
+		Number:      delivery.Attempts,
+		AttemptedAt: started,
+	}
+	defer func() {
+		attempt.DurationMs = int(time.Since(started).Milliseconds())
+	}()
+
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := strconv.FormatInt(started.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Havamal-Webhooks/1.0")
+	request.Header.Set("X-Havamal-Event", string(delivery.Event))
+	request.Header.Set("X-Havamal-Event-Id", delivery.EventId.String())
+	request.Header.Set("X-Havamal-Delivery", delivery.ID.String())
+	request.Header.Set("X-Havamal-Timestamp", timestamp)
+	request.Header.Set("X-Havamal-Signature", sign(delivery.secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	attempt.StatusCode = &response.StatusCode
+	excerpt, _ := io.ReadAll(io.LimitReader(response.Body, responseLimit))
+	attempt.Response = string(excerpt)
+	return attempt
+}
+
+// sign returns the signature header of a payload sent at timestamp: the
+// HMAC-SHA256, keyed with the webhook's secret, of the timestamp, a dot and
+// the body. Receivers recompute it and should refuse stale timestamps.
+func sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
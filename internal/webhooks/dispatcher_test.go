@@ -0,0 +1,273 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"havamal-api/internal/events"
+
+	"github.com/google/uuid"
+)
+
+type fakeRepository struct {
+	Repository
+	enqueueErr error
+	enqueued   []events.Event
+	attempts   []Attempt
+	settled    string
+	next       time.Time
+}
+
+func (f *fakeRepository) Enqueue(tx *sql.Tx, event events.Event, payload string) error {
+	f.enqueued = append(f.enqueued, event)
+	return f.enqueueErr
+}
+
+func (f *fakeRepository) RecordAttempt(attempt *Attempt) error {
+	f.attempts = append(f.attempts, *attempt)
+	return nil
+}
+
+func (f *fakeRepository) MarkDelivered(id uuid.UUID) error {
+	f.settled = "delivered"
+	return nil
+}
+
+func (f *fakeRepository) MarkFailed(id uuid.UUID) error {
+	f.settled = "failed"
+	return nil
+}
+
+func (f *fakeRepository) Reschedule(id uuid.UUID, next time.Time) error {
+	f.settled = "rescheduled"
+	f.next = next
+	return nil
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		want      string
+	}{
+		{"known vector", "s3cret", "1700000000", "sha256=2b9dee6c893e4bf012ad34ee7b89d492b9567b4f47740ccbf0f161ba3717dc08"},
+		{"keyed by the secret", "other", "1700000000", "sha256=0c9dcd041b074d1b31727e0c1f821d11366e9db9f94c18bf202eb66cd0bd4d40"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sign(tt.secret, tt.timestamp, body); got != tt.want {
+				t.Errorf("sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if sign("s3cret", "1700000001", body) == tests[0].want {
+		t.Error("sign() ignores the timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, Config{Backoff: 30 * time.Second, MaxBackoff: 10 * time.Minute})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{60, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+		settled  string
+	}{
+		{"success", http.StatusNoContent, 1, "delivered"},
+		{"failure is retried", http.StatusInternalServerError, 1, "rescheduled"},
+		{"client error is retried", http.StatusNotFound, 2, "rescheduled"},
+		{"last attempt fails", http.StatusBadGateway, 3, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			repository := &fakeRepository{}
+			// The test receiver listens on loopback
+			d := NewDispatcher(repository, Config{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour, Timeout: time.Second, AllowPrivate: true})
+			delivery := due{
+				Delivery: Delivery{ID: uuid.New(), EventId: uuid.New(), Event: events.PostPublished, Payload: `{"id":"1"}`, Attempts: tt.attempts},
+				url:      server.URL,
+				secret:   "s3cret",
+			}
+			before := time.Now()
+			d.deliver(context.Background(), delivery)
+
+			if repository.settled != tt.settled {
+				t.Errorf("deliver() settled %q, want %q", repository.settled, tt.settled)
+			}
+			if tt.settled == "rescheduled" && repository.next.Before(before.Add(d.backoff(tt.attempts))) {
+				t.Errorf("deliver() rescheduled at %v, before the backoff", repository.next)
+			}
+			if len(repository.attempts) != 1 || repository.attempts[0].StatusCode == nil || *repository.attempts[0].StatusCode != tt.status {
+				t.Fatalf("deliver() logged %+v", repository.attempts)
+			}
+			if string(body) != delivery.Payload {
+				t.Errorf("the receiver got %s, want %s", body, delivery.Payload)
+			}
+			if want := sign("s3cret", header.Get("X-Havamal-Timestamp"), body); header.Get("X-Havamal-Signature") != want {
+				t.Errorf("X-Havamal-Signature = %s, want %s", header.Get("X-Havamal-Signature"), want)
+			}
+			if header.Get("X-Havamal-Event") != string(events.PostPublished) || header.Get("X-Havamal-Event-Id") != delivery.EventId.String() {
+				t.Errorf("deliver() sent headers %v", header)
+			}
+		})
+	}
+}
+
+// An unreachable receiver is logged without a status code and retried
+func TestDeliverUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	repository := &fakeRepository{}
+	d := NewDispatcher(repository, Config{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour, Timeout: time.Second, AllowPrivate: true})
+	d.deliver(context.Background(), due{Delivery: Delivery{ID: uuid.New(), Attempts: 1}, url: url})
+	if repository.settled != "rescheduled" {
+		t.Errorf("deliver() settled %q, want rescheduled", repository.settled)
+	}
+	if len(repository.attempts) != 1 || repository.attempts[0].StatusCode != nil || repository.attempts[0].Error == "" {
+		t.Errorf("deliver() logged %+v", repository.attempts)
+	}
+}
+
+// A receiver on a private address is never connected to, whatever the URL
+// said when it was subscribed
+func TestDeliverPrivate(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	repository := &fakeRepository{}
+	d := NewDispatcher(repository, Config{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour, Timeout: time.Second})
+	d.deliver(context.Background(), due{Delivery: Delivery{ID: uuid.New(), Attempts: 1}, url: server.URL})
+	if reached {
+		t.Fatal("deliver() connected to a loopback receiver")
+	}
+	if len(repository.attempts) != 1 || !strings.Contains(repository.attempts[0].Error, errPrivateAddress.Error()) {
+		t.Errorf("deliver() logged %+v", repository.attempts)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.5", false},
+		{"172.16.0.1", false},
+		{"192.168.1.10", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := public(netip.MustParseAddr(tt.ip)); got != tt.want {
+				t.Errorf("public(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		wantErr      error
+	}{
+		{"public address", "https://93.184.216.34/hooks", false, nil},
+		{"loopback", "http://127.0.0.1:8080/hooks", false, ErrPrivateURL},
+		{"localhost", "http://localhost/hooks", false, ErrPrivateURL},
+		{"metadata endpoint", "http://169.254.169.254/latest/meta-data", false, ErrPrivateURL},
+		{"private network", "https://[fd00::1]/hooks", false, ErrPrivateURL},
+		{"private, allowed", "http://10.0.0.5/hooks", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{config: Config{AllowPrivate: tt.allowPrivate}}
+			if err := s.checkURL(tt.url); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	failure := errors.New("connection refused")
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{"queued", nil, nil},
+		{"queue failure fails the change", failure, failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{enqueueErr: tt.err}
+			data := map[string]string{"slug": "havamal"}
+			step := NewService(repository, Config{}).Publish(events.PostCreated, data)
+			if len(repository.enqueued) != 0 {
+				t.Fatal("Publish() enqueued before the change was written")
+			}
+			// The step sees the data as the change left it
+			data["slug"] = "havamal-en"
+			err := step(nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Publish() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(repository.enqueued) != 1 || repository.enqueued[0].Type != events.PostCreated || repository.enqueued[0].Data.(map[string]string)["slug"] != "havamal-en" {
+				t.Errorf("Publish() enqueued %+v", repository.enqueued)
+			}
+		})
+	}
+}
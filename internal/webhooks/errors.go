@@ -0,0 +1,10 @@
+package webhooks
+
+import "havamal-api/internal/apierror"
+
+var (
+	ErrNotFound         = apierror.New(apierror.WebhookNotFound)
+	ErrDeliveryNotFound = apierror.New(apierror.WebhookDeliveryNotFound)
+	ErrUnknownEvent     = apierror.New(apierror.WebhookUnknownEvent)
+	ErrPrivateURL       = apierror.New(apierror.WebhookPrivateURL)
+)
@@ -0,0 +1,104 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"havamal-api/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	var request Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	webhook, err := h.service.Create(&request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *Handler) GetAll(c *gin.Context) {
+	webhooks, err := h.service.GetAll()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *Handler) GetById(c *gin.Context) {
+	webhook, err := h.service.GetById(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	var request Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Invalid(c, err)
+		return
+	}
+	webhook, err := h.service.Update(c.Param("id"), &request)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.Param("id")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+func (h *Handler) GetDeliveries(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != string(Pending) && status != string(Delivered) && status != string(Failed) {
+		apierror.Write(c, apierror.InvalidRequest)
+		return
+	}
+	deliveries, err := h.service.GetDeliveries(c.Param("id"), status)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (h *Handler) GetDelivery(c *gin.Context) {
+	delivery, err := h.service.GetDelivery(c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Redeliver queues the delivery again; the new delivery is sent shortly
+func (h *Handler) Redeliver(c *gin.Context) {
+	delivery, err := h.service.Redeliver(c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"time"
+
+	"havamal-api/internal/events"
+
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	URL         string   `json:"url" binding:"required,http_url,max=2000"`
+	Events      []string `json:"events" binding:"required,min=1,dive,required"`
+	Description string   `json:"description" binding:"max=500"`
+	// Secret signs the payloads; one is generated when it is left out
+	Secret string `json:"secret" binding:"omitempty,min=16,max=200"`
+	Active *bool  `json:"active"`
+}
+
+// Webhook is a subscription of a URL to content events. Events may hold "*"
+// to receive every event. The secret is only answered when the webhook is
+// created.
+type Webhook struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Secret      string    `json:"-"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Created is a new webhook with the secret its endpoint verifies signatures
+// with
+type Created struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type Status string
+
+const (
+	Pending   Status = "pending"
+	Delivered Status = "delivered"
+	Failed    Status = "failed"
+)
+
+// Delivery is an event on its way to a webhook, kept in the outbox until it
+// is delivered or has used up its attempts
+type Delivery struct {
+	ID            uuid.UUID   `json:"id"`
+	WebhookId     uuid.UUID   `json:"webhook_id"`
+	EventId       uuid.UUID   `json:"event_id"`
+	Event         events.Type `json:"event"`
+	Payload       string      `json:"payload"`
+	Status        Status      `json:"status"`
+	Attempts      int         `json:"attempts"`
+	NextAttemptAt *time.Time  `json:"next_attempt_at"`
+	RedeliveryOf  *uuid.UUID  `json:"redelivery_of"`
+	CreatedAt     time.Time   `json:"created_at"`
+	DeliveredAt   *time.Time  `json:"delivered_at"`
+	// Log lists the attempts made so far; only answered for a single delivery
+	Log []Attempt `json:"log,omitempty"`
+}
+
+// Attempt is one request made for a delivery. StatusCode is missing when no
+// response came back, and Response holds the start of the body.
+type Attempt struct {
+	ID          uuid.UUID `json:"id"`
+	DeliveryId  uuid.UUID `json:"delivery_id"`
+	Number      int       `json:"number"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	DurationMs  int       `json:"duration_ms"`
+	StatusCode  *int      `json:"status_code"`
+	Response    string    `json:"response"`
+	Error       string    `json:"error"`
+}
+
+// Config is the delivery policy, from config.Config.Webhooks
+type Config struct {
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	Timeout      time.Duration
+	AllowPrivate bool
+}
+
+// due is a delivery claimed by the dispatcher, with where and how to send it
+type due struct {
+	Delivery
+	url    string
+	secret string
+}
@@ -0,0 +1,292 @@
+package webhooks
+
+import (
+	"database/sql"
+	"time"
+
+	"havamal-api/internal/db"
+	"havamal-api/internal/events"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	Create(webhook *Webhook) error
+	GetAll() ([]Webhook, error)
+	GetById(id uuid.UUID) (*Webhook, error)
+	Update(webhook *Webhook) error
+	Delete(id uuid.UUID) error
+
+	Enqueue(tx *sql.Tx, event events.Event, payload string) error
+	GetDeliveries(webhookId uuid.UUID, status Status) ([]Delivery, error)
+	GetDelivery(webhookId uuid.UUID, id uuid.UUID) (*Delivery, error)
+	GetAttempts(deliveryId uuid.UUID) ([]Attempt, error)
+	Redeliver(webhookId uuid.UUID, id uuid.UUID) (*Delivery, error)
+
+	ClaimDue(limit int, lease time.Duration) ([]due, error)
+	RecordAttempt(attempt *Attempt) error
+	MarkDelivered(id uuid.UUID) error
+	Reschedule(id uuid.UUID, next time.Time) error
+	MarkFailed(id uuid.UUID) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// translate returns the domain error of a failed webhook query
+func translate(err error) error {
+	return db.Translate(err, ErrNotFound, nil)
+}
+
+// translateDelivery returns the domain error of a failed delivery query
+func translateDelivery(err error) error {
+	return db.Translate(err, ErrDeliveryNotFound, nil)
+}
+
+const webhookColumns = `id, url, secret, events, description, active, created_at, updated_at`
+
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at, redelivery_of, created_at, delivered_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row scanner) (*Webhook, error) {
+	var webhook Webhook
+	if err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.Events), &webhook.Description, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func scanDelivery(row scanner, extra ...any) (*Delivery, error) {
+	var delivery Delivery
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var redeliveryOf uuid.NullUUID
+	dest := []any{&delivery.ID, &delivery.WebhookId, &delivery.EventId, &delivery.Event, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &nextAttemptAt, &redeliveryOf, &delivery.CreatedAt, &deliveredAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if redeliveryOf.Valid {
+		delivery.RedeliveryOf = &redeliveryOf.UUID
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+// affected turns an update of no rows into sql.ErrNoRows
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *repository) Create(webhook *Webhook) error {
+	query := `INSERT INTO webhooks (` + webhookColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.Exec(query, webhook.ID, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Description, webhook.Active, webhook.CreatedAt, webhook.UpdatedAt)
+	return translate(err)
+}
+
+func (r *repository) GetAll() ([]Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, nil
+}
+
+func (r *repository) GetById(id uuid.UUID) (*Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	webhook, err := scanWebhook(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, translate(err)
+	}
+	return webhook, nil
+}
+
+func (r *repository) Update(webhook *Webhook) error {
+	query := `UPDATE webhooks SET url = $2, secret = $3, events = $4, description = $5, active = $6, updated_at = $7
+	WHERE id = $1`
+	return translate(affected(r.db.Exec(query, webhook.ID, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Description, webhook.Active, webhook.UpdatedAt)))
+}
+
+func (r *repository) Delete(id uuid.UUID) error {
+	return translate(affected(r.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)))
+}
+
+// Enqueue adds a delivery of event to the outbox for every active webhook
+// subscribed to it, due at once, in the transaction of the change
+func (r *repository) Enqueue(tx *sql.Tx, event events.Event, payload string) error {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at)
+	SELECT id, $1::uuid, $2::text, $3::text, NOW()
+	FROM webhooks
+	WHERE active AND ($2::text = ANY(events) OR '*' = ANY(events))`
+	_, err := tx.Exec(query, event.ID, string(event.Type), payload)
+	return err
+}
+
+// GetDeliveries lists the latest deliveries of a webhook, of one status when
+// status is not empty
+func (r *repository) GetDeliveries(webhookId uuid.UUID, status Status) ([]Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+	WHERE webhook_id = $1 AND ($2::text = '' OR status = $2::text)
+	ORDER BY created_at DESC
+	LIMIT 100`
+	rows, err := r.db.Query(query, webhookId, string(status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, nil
+}
+
+func (r *repository) GetDelivery(webhookId uuid.UUID, id uuid.UUID) (*Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2`
+	delivery, err := scanDelivery(r.db.QueryRow(query, id, webhookId))
+	if err != nil {
+		return nil, translateDelivery(err)
+	}
+	return delivery, nil
+}
+
+func (r *repository) GetAttempts(deliveryId uuid.UUID) ([]Attempt, error) {
+	query := `SELECT id, delivery_id, number, attempted_at, duration_ms, status_code, response, error
+	FROM webhook_attempts
+	WHERE delivery_id = $1
+	ORDER BY number`
+	rows, err := r.db.Query(query, deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attempts := make([]Attempt, 0)
+	for rows.Next() {
+		var attempt Attempt
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&attempt.ID, &attempt.DeliveryId, &attempt.Number, &attempt.AttemptedAt, &attempt.DurationMs, &statusCode, &attempt.Response, &attempt.Error); err != nil {
+			return nil, err
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			attempt.StatusCode = &code
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// Redeliver queues a copy of a delivery, due at once, whatever became of the
+// original
+func (r *repository) Redeliver(webhookId uuid.UUID, id uuid.UUID) (*Delivery, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at, redelivery_of)
+	SELECT webhook_id, event_id, event, payload, NOW(), id
+	FROM webhook_deliveries
+	WHERE id = $1 AND webhook_id = $2
+	RETURNING ` + deliveryColumns
+	delivery, err := scanDelivery(r.db.QueryRow(query, id, webhookId))
+	if err != nil {
+		return nil, translateDelivery(err)
+	}
+	return delivery, nil
+}
+
+// ClaimDue takes up to limit pending deliveries that are due, for active
+// webhooks, and counts the attempt about to be made. The next attempt is
+// pushed back by lease, so a dispatcher that dies mid-request leaves the
+// delivery to be retried, and concurrent dispatchers skip what is claimed.
+func (r *repository) ClaimDue(limit int, lease time.Duration) ([]due, error) {
+	query := `UPDATE webhook_deliveries d
+	SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT pending.id FROM webhook_deliveries pending
+			INNER JOIN webhooks active ON active.id = pending.webhook_id
+		WHERE pending.status = 'pending' AND pending.next_attempt_at <= NOW() AND active.active
+		ORDER BY pending.next_attempt_at
+		LIMIT $1
+		FOR UPDATE OF pending SKIP LOCKED
+	)
+	RETURNING d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+		d.redelivery_of, d.created_at, d.delivered_at, w.url, w.secret`
+	rows, err := r.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var claimed []due
+	for rows.Next() {
+		var d due
+		delivery, err := scanDelivery(rows, &d.url, &d.secret)
+		if err != nil {
+			return nil, err
+		}
+		d.Delivery = *delivery
+		claimed = append(claimed, d)
+	}
+	return claimed, nil
+}
+
+func (r *repository) RecordAttempt(attempt *Attempt) error {
+	query := `INSERT INTO webhook_attempts (id, delivery_id, number, attempted_at, duration_ms, status_code, response, error)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	var statusCode any
+	if attempt.StatusCode != nil {
+		statusCode = *attempt.StatusCode
+	}
+	_, err := r.db.Exec(query, attempt.ID, attempt.DeliveryId, attempt.Number, attempt.AttemptedAt, attempt.DurationMs, statusCode, attempt.Response, attempt.Error)
+	return err
+}
+
+func (r *repository) MarkDelivered(id uuid.UUID) error {
+	query := `UPDATE webhook_deliveries SET status = 'delivered', delivered_at = NOW(), next_attempt_at = NULL WHERE id = $1`
+	return translateDelivery(affected(r.db.Exec(query, id)))
+}
+
+func (r *repository) Reschedule(id uuid.UUID, next time.Time) error {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = $2 WHERE id = $1`
+	return translateDelivery(affected(r.db.Exec(query, id, next)))
+}
+
+func (r *repository) MarkFailed(id uuid.UUID) error {
+	query := `UPDATE webhook_deliveries SET status = 'failed', next_attempt_at = NULL WHERE id = $1`
+	return translateDelivery(affected(r.db.Exec(query, id)))
+}
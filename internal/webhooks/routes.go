@@ -0,0 +1,49 @@
+package webhooks
+
+import (
+	"net/http"
+	"strings"
+
+	"havamal-api/internal/events"
+	"havamal-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/webhooks", handler.Create)
+	router.GET("/webhooks", handler.GetAll)
+	router.GET("/webhooks/:id", handler.GetById)
+	router.PUT("/webhooks/:id", handler.Update)
+	router.DELETE("/webhooks/:id", handler.Delete)
+	router.GET("/webhooks/:id/deliveries", handler.GetDeliveries)
+	router.GET("/webhooks/:id/deliveries/:delivery_id", handler.GetDelivery)
+	router.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", handler.Redeliver)
+}
+
+// Operations describes the routes of RegisterRoutes
+func Operations() []openapi.Operation {
+	names := make([]string, len(events.Types))
+	for i, t := range events.Types {
+		names[i] = string(t)
+	}
+	subscribe := "events lists what the webhook receives, or * for everything: " + strings.Join(names, ", ") + "."
+	return []openapi.Operation{
+		{Method: "POST", Path: "/webhooks", Tag: "webhooks", Summary: "Subscribe a URL to content events",
+			Description: subscribe + " The secret that signs the payloads is only answered here.",
+			Body:        Request{}, Response: Created{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/webhooks", Tag: "webhooks", Summary: "List webhooks", Response: []Webhook{}},
+		{Method: "GET", Path: "/webhooks/:id", Tag: "webhooks", Summary: "Get a webhook", Response: Webhook{}},
+		{Method: "PUT", Path: "/webhooks/:id", Tag: "webhooks", Summary: "Replace a webhook",
+			Description: subscribe + " The secret and the active flag are kept when left out.",
+			Body:        Request{}, Response: Webhook{}},
+		{Method: "DELETE", Path: "/webhooks/:id", Tag: "webhooks", Summary: "Delete a webhook and its deliveries"},
+		{Method: "GET", Path: "/webhooks/:id/deliveries", Tag: "webhooks", Summary: "List a webhook's latest deliveries",
+			Query:    []openapi.Param{openapi.Enum("status", "Only the deliveries in this state", string(Pending), string(Delivered), string(Failed))},
+			Response: []Delivery{}},
+		{Method: "GET", Path: "/webhooks/:id/deliveries/:delivery_id", Tag: "webhooks", Summary: "Get a delivery with its attempts", Response: Delivery{}},
+		{Method: "POST", Path: "/webhooks/:id/deliveries/:delivery_id/redeliver", Tag: "webhooks", Summary: "Send a delivery again",
+			Description: "Queues a new delivery of the same event, which keeps its event id.",
+			Response:    Delivery{}, Status: http.StatusAccepted},
+	}
+}
@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"havamal-api/internal/db"
+	"havamal-api/internal/events"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	// Publish records an event in the outbox of every webhook subscribed to
+	// it, in the transaction of the change; the dispatcher delivers it
+	events.Publisher
+	Create(request *Request) (*Created, error)
+	GetAll() ([]Webhook, error)
+	GetById(id string) (*Webhook, error)
+	Update(id string, request *Request) (*Webhook, error)
+	Delete(id string) error
+	GetDeliveries(id string, status string) ([]Delivery, error)
+	GetDelivery(id string, deliveryId string) (*Delivery, error)
+	Redeliver(id string, deliveryId string) (*Delivery, error)
+}
+
+type service struct {
+	repository Repository
+	config     Config
+}
+
+func NewService(repository Repository, config Config) Service {
+	return &service{repository: repository, config: config}
+}
+
+// Publish returns the write that queues a delivery of the event for every
+// webhook subscribed to it. Data is read when the step runs, after the change
+// it describes.
+func (s *service) Publish(eventType events.Type, data any) db.Step {
+	return func(tx *sql.Tx) error {
+		event := events.New(eventType, data)
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := s.repository.Enqueue(tx, event, string(payload)); err != nil {
+			return fmt.Errorf("failed to record %s deliveries of event %s: %w", eventType, event.ID, err)
+		}
+		return nil
+	}
+}
+
+func (s *service) Create(request *Request) (*Created, error) {
+	if err := checkEvents(request.Events); err != nil {
+		return nil, err
+	}
+	if err := s.checkURL(request.URL); err != nil {
+		return nil, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	webhook := Webhook{
+		ID:          uuid.New(),
+		URL:         request.URL,
+		Events:      request.Events,
+		Description: request.Description,
+		Secret:      secret,
+		Active:      request.Active == nil || *request.Active,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repository.Create(&webhook); err != nil {
+		return nil, err
+	}
+	return &Created{Webhook: webhook, Secret: secret}, nil
+}
+
+// checkEvents refuses subscriptions to events that do not exist
+func checkEvents(names []string) error {
+	for _, name := range names {
+		if name != "*" && !events.Known(name) {
+			return fmt.Errorf("%w: %s", ErrUnknownEvent, name)
+		}
+	}
+	return nil
+}
+
+// newSecret returns 256 random bits, hex encoded
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *service) GetAll() ([]Webhook, error) {
+	return s.repository.GetAll()
+}
+
+func (s *service) GetById(id string) (*Webhook, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetById(parsedId)
+}
+
+// Update replaces a webhook. The secret is kept unless the request sets a new
+// one, and so is the active flag.
+func (s *service) Update(id string, request *Request) (*Webhook, error) {
+	webhook, err := s.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEvents(request.Events); err != nil {
+		return nil, err
+	}
+	if err := s.checkURL(request.URL); err != nil {
+		return nil, err
+	}
+	webhook.URL = request.URL
+	webhook.Events = request.Events
+	webhook.Description = request.Description
+	if request.Secret != "" {
+		webhook.Secret = request.Secret
+	}
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+	webhook.UpdatedAt = time.Now()
+	if err := s.repository.Update(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *service) Delete(id string) error {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	return s.repository.Delete(parsedId)
+}
+
+func (s *service) GetDeliveries(id string, status string) ([]Delivery, error) {
+	webhook, err := s.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetDeliveries(webhook.ID, Status(status))
+}
+
+// GetDelivery returns a delivery of a webhook with its log
+func (s *service) GetDelivery(id string, deliveryId string) (*Delivery, error) {
+	webhookId, parsedDeliveryId, err := parseIds(id, deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := s.repository.GetDelivery(webhookId, parsedDeliveryId)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Log, err = s.repository.GetAttempts(delivery.ID); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *service) Redeliver(id string, deliveryId string) (*Delivery, error) {
+	webhookId, parsedDeliveryId, err := parseIds(id, deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.Redeliver(webhookId, parsedDeliveryId)
+}
+
+func parseIds(id string, deliveryId string) (uuid.UUID, uuid.UUID, error) {
+	webhookId, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	parsedDeliveryId, err := uuid.Parse(deliveryId)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return webhookId, parsedDeliveryId, nil
+}
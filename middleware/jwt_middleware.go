@@ -3,6 +3,8 @@ package middleware
 
 import (
 	"havamal-api/config"
+	"havamal-api/internal/apierror"
+	"net/http"
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
@@ -57,10 +59,11 @@ func SetupJWT(cfg config.Config) (*jwt.GinJWTMiddleware, error) {
 			return false
 		},
 		Unauthorized: func(c *gin.Context, code int, message string) {
-			c.JSON(code, gin.H{
-				"code":    code,
-				"message": message,
-			})
+			if code == http.StatusForbidden {
+				apierror.Write(c, apierror.AuthForbidden)
+				return
+			}
+			apierror.Write(c, apierror.AuthUnauthorized)
 		},
 		TokenLookup:   "header: Authorization, query: token, cookie: jwt",
 		TokenHeadName: "Bearer",
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"fmt"
+	"havamal-api/internal/apierror"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemMiddleware answers the last error a handler attached to the request
+// as application/problem+json, unless the handler already wrote a response,
+// and turns panics into internal errors
+func ProblemMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				_ = c.Error(fmt.Errorf("panic: %v\n%s", recovered, debug.Stack()))
+				c.Abort()
+				if !c.Writer.Written() {
+					apierror.Render(c, c.Errors.Last().Err, nil)
+				}
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		last := c.Errors.Last()
+		fields, _ := last.Meta.(gin.H)
+		apierror.Render(c, last.Err, fields)
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const apiVersionKey = "api_version"
+
+var apiVersionCounter metric.Int64Counter
+
+func init() {
+	meter := otel.Meter("turniq-api")
+
+	var err error
+	apiVersionCounter, err = meter.Int64Counter(
+		"http.server.api_version.request.count",
+		metric.WithDescription("Requests per API version, telling the unversioned aliases apart"),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// VersionMiddleware tags requests with the API version serving them and
+// counts them per version and route. Alias marks the unversioned paths, so
+// their remaining traffic shows when they can be removed.
+func VersionMiddleware(version string, alias bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Header("API-Version", version)
+
+		c.Next()
+
+		attrs := []attribute.KeyValue{
+			attribute.String("api.version", version),
+			attribute.Bool("api.alias", alias),
+			attribute.String("http.route", c.FullPath()),
+			attribute.Int("http.status_code", c.Writer.Status()),
+		}
+		apiVersionCounter.Add(c.Request.Context(), 1, metric.WithAttributes(attrs...))
+	}
+}
+
+// GetAPIVersion returns the API version serving the request, empty outside the
+// versioned groups
+func GetAPIVersion(c *gin.Context) string {
+	return c.GetString(apiVersionKey)
+}
+
+// Deprecation describes a deprecated set of routes: when they were
+// deprecated, when they stop being served and where their successors live.
+// Successor is prepended to the request path to name the successor route.
+type Deprecation struct {
+	Since     time.Time
+	Sunset    time.Time
+	Successor string
+	// Policy is a page documenting the deprecation
+	Policy string
+}
+
+// DeprecationMiddleware announces deprecated routes with the Deprecation
+// (RFC 9745), Sunset (RFC 8594) and Link headers
+func DeprecationMiddleware(deprecation Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "@"+strconv.FormatInt(deprecation.Since.Unix(), 10))
+		if !deprecation.Sunset.IsZero() {
+			c.Header("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+		}
+		links := make([]string, 0, 2)
+		if deprecation.Successor != "" {
+			successor := deprecation.Successor + c.Request.URL.Path
+			if c.Request.URL.RawQuery != "" {
+				successor += "?" + c.Request.URL.RawQuery
+			}
+			links = append(links, "<"+successor+`>; rel="successor-version"`)
+		}
+		if deprecation.Policy != "" {
+			links = append(links, "<"+deprecation.Policy+`>; rel="deprecation"; type="text/html"`)
+		}
+		if len(links) > 0 {
+			c.Writer.Header().Add("Link", strings.Join(links, ", "))
+		}
+		c.Next()
+	}
+}
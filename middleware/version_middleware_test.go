@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serve(path string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers = append(handlers, func(c *gin.Context) {
+		c.String(http.StatusOK, GetAPIVersion(c))
+	})
+	router.GET("/blog/posts", handlers...)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestVersionMiddleware(t *testing.T) {
+	recorder := serve("/blog/posts", VersionMiddleware("v1", false))
+	if recorder.Header().Get("API-Version") != "v1" || recorder.Body.String() != "v1" {
+		t.Errorf("version header %q, handler saw %q", recorder.Header().Get("API-Version"), recorder.Body.String())
+	}
+	if recorder.Header().Get("Deprecation") != "" {
+		t.Error("a current route is announced as deprecated")
+	}
+}
+
+func TestDeprecationMiddleware(t *testing.T) {
+	since := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	tests := []struct {
+		name        string
+		path        string
+		deprecation Deprecation
+		sunset      string
+		link        string
+	}{
+		{"everything", "/blog/posts?lang=en", Deprecation{Since: since, Sunset: sunset, Successor: "/v1", Policy: "/docs"},
+			"Thu, 01 Apr 2027 10:00:00 GMT",
+			`</v1/blog/posts?lang=en>; rel="successor-version", </docs>; rel="deprecation"; type="text/html"`},
+		{"no query", "/blog/posts", Deprecation{Since: since, Successor: "/v1"},
+			"", `</v1/blog/posts>; rel="successor-version"`},
+		{"no sunset nor links", "/blog/posts", Deprecation{Since: since}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := serve(tt.path, DeprecationMiddleware(tt.deprecation)).Header()
+			if got := header.Get("Deprecation"); got != "@1790812800" {
+				t.Errorf("Deprecation = %q, want @1790812800", got)
+			}
+			if got := header.Get("Sunset"); got != tt.sunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.sunset)
+			}
+			if got := header.Get("Link"); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+}
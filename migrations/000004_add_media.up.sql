@@ -0,0 +1,20 @@
+-- Uploaded media files
+CREATE TABLE IF NOT EXISTS media (
+    id UUID PRIMARY KEY,
+    filename TEXT NOT NULL UNIQUE,
+    original_name TEXT,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Media referenced from post content
+CREATE TABLE IF NOT EXISTS post_media (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, media_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_media_media_id ON post_media(media_id);
@@ -0,0 +1,5 @@
+ALTER TABLE media DROP COLUMN IF EXISTS duration_seconds;
+ALTER TABLE media DROP COLUMN IF EXISTS page_count;
+ALTER TABLE media DROP COLUMN IF EXISTS height;
+ALTER TABLE media DROP COLUMN IF EXISTS width;
+ALTER TABLE media DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,8 @@
+-- Media kinds and extracted metadata
+ALTER TABLE media
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'image' CHECK (kind IN ('image', 'document', 'audio', 'video'));
+
+ALTER TABLE media ADD COLUMN width INTEGER;
+ALTER TABLE media ADD COLUMN height INTEGER;
+ALTER TABLE media ADD COLUMN page_count INTEGER;
+ALTER TABLE media ADD COLUMN duration_seconds DOUBLE PRECISION;
@@ -0,0 +1,2 @@
+-- Private media is only served through signed URLs
+ALTER TABLE media ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE;
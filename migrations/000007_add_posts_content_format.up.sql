@@ -0,0 +1,5 @@
+-- Content format and cached rendered HTML
+ALTER TABLE posts
+ADD COLUMN format TEXT NOT NULL DEFAULT 'html' CHECK (format IN ('markdown', 'html', 'plaintext'));
+
+ALTER TABLE posts ADD COLUMN content_html TEXT;
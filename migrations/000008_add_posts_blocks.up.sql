@@ -0,0 +1,2 @@
+-- Structured block content; content is derived from it when present
+ALTER TABLE posts ADD COLUMN blocks JSONB;
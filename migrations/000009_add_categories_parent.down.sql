@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_categories_parent_id;
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_not_own_parent;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,8 @@
+-- Category hierarchy
+ALTER TABLE categories
+ADD COLUMN parent_id UUID REFERENCES categories(id) ON DELETE SET NULL;
+
+ALTER TABLE categories
+ADD CONSTRAINT categories_not_own_parent CHECK (parent_id IS NULL OR parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
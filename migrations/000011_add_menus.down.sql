@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_navigation_menu_id;
+ALTER TABLE navigation DROP COLUMN IF EXISTS menu_id;
+DROP TABLE IF EXISTS menus;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS menus (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    location TEXT NOT NULL CHECK (location IN ('header', 'footer', 'sidebar')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Items without a menu keep belonging to the global navigation
+ALTER TABLE navigation
+ADD COLUMN menu_id UUID REFERENCES menus(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_navigation_menu_id ON navigation(menu_id);
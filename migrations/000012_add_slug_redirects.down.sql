@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_slug_redirects_entity;
+DROP TABLE IF EXISTS slug_redirects;
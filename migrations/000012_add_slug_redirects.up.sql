@@ -0,0 +1,10 @@
+-- Previous slugs of posts and categories, kept so old links can redirect
+CREATE TABLE IF NOT EXISTS slug_redirects (
+    entity_type TEXT NOT NULL CHECK (entity_type IN ('post', 'category')),
+    old_slug TEXT NOT NULL,
+    entity_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (entity_type, old_slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_slug_redirects_entity ON slug_redirects(entity_type, entity_id);
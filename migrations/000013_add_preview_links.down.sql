@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_preview_links_post_id;
+DROP TABLE IF EXISTS preview_links;
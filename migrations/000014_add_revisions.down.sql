@@ -0,0 +1,4 @@
+ALTER TABLE versions DROP COLUMN IF EXISTS revision;
+ALTER TABLE navigation DROP COLUMN IF EXISTS revision;
+ALTER TABLE categories DROP COLUMN IF EXISTS revision;
+ALTER TABLE posts DROP COLUMN IF EXISTS revision;
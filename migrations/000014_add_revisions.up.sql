@@ -0,0 +1,5 @@
+-- Revision counters for optimistic concurrency, exposed as ETags
+ALTER TABLE posts ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE navigation ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE versions ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS post_autosaves;
+DROP TABLE IF EXISTS post_locks;
@@ -0,0 +1,21 @@
+-- Soft edit locks: advisory, renewed by heartbeats and ignored once expired
+CREATE TABLE IF NOT EXISTS post_locks (
+    post_id UUID PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- One working copy per post and editor, kept apart from the live post
+CREATE TABLE IF NOT EXISTS post_autosaves (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL DEFAULT '',
+    summary TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    format TEXT NOT NULL DEFAULT 'html',
+    blocks JSONB,
+    base_revision INTEGER NOT NULL,
+    saved_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, user_id)
+);
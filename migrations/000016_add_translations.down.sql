@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS navigation_translations;
+DROP TABLE IF EXISTS category_translations;
+DROP INDEX IF EXISTS idx_posts_translation_locale;
+ALTER TABLE posts DROP COLUMN IF EXISTS translation_group;
+ALTER TABLE posts DROP COLUMN IF EXISTS locale;
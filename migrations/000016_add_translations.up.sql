@@ -0,0 +1,22 @@
+-- Each post is written in one locale; posts sharing a translation group are
+-- translations of each other, at most one per locale
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'ca';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS translation_group UUID;
+UPDATE posts SET translation_group = id WHERE translation_group IS NULL;
+ALTER TABLE posts ALTER COLUMN translation_group SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_translation_locale ON posts(translation_group, locale);
+
+CREATE TABLE IF NOT EXISTS category_translations (
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (category_id, locale)
+);
+
+CREATE TABLE IF NOT EXISTS navigation_translations (
+    navigation_id UUID NOT NULL REFERENCES navigation(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    label TEXT NOT NULL,
+    PRIMARY KEY (navigation_id, locale)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS graphql_persisted_queries;
@@ -0,0 +1,7 @@
+-- GraphQL queries registered by their SHA-256 hash, so clients can send the
+-- hash instead of the query text
+CREATE TABLE IF NOT EXISTS graphql_persisted_queries (
+    hash TEXT PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+-- The removed redirects led nowhere and are not restored
+SELECT 1;
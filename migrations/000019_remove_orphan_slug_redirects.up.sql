@@ -0,0 +1,4 @@
+-- Redirects of deleted posts and categories kept their old slugs taken
+DELETE FROM slug_redirects sr
+WHERE (sr.entity_type = 'post' AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = sr.entity_id))
+   OR (sr.entity_type = 'category' AND NOT EXISTS (SELECT 1 FROM categories c WHERE c.id = sr.entity_id));
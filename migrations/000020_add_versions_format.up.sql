@@ -0,0 +1,6 @@
+-- Content format a version was written in, so it renders the same after the
+-- post changes format. Existing versions take the current format of their post
+ALTER TABLE versions
+ADD COLUMN format TEXT NOT NULL DEFAULT 'html' CHECK (format IN ('markdown', 'html', 'plaintext'));
+
+UPDATE versions v SET format = p.format FROM posts p WHERE p.id = v.post_id;
@@ -1,13 +1,23 @@
 package server
 
 import (
+	"context"
 	"havamal-api/config"
+	"havamal-api/internal/apierror"
 	"havamal-api/internal/auth"
 	"havamal-api/internal/categories"
+	"havamal-api/internal/graph"
 	"havamal-api/internal/images"
+	"havamal-api/internal/media"
 	"havamal-api/internal/navigation"
+	"havamal-api/internal/openapi"
 	"havamal-api/internal/posts"
+	"havamal-api/internal/previews"
+	"havamal-api/internal/resolver"
+	"havamal-api/internal/sitemap"
+	"havamal-api/internal/tags"
 	"havamal-api/internal/versions"
+	"havamal-api/internal/webhooks"
 
 	"havamal-api/internal/users"
 
@@ -23,6 +33,9 @@ type Server struct {
 	router *gin.Engine
 	config config.Config
 	db *sql.DB
+	spec *openapi.Document
+	mounts []mount
+	dispatcher *webhooks.Dispatcher
 }
 
 func NewServer(config config.Config, db *sql.DB) *Server {
@@ -36,6 +49,7 @@ func NewServer(config config.Config, db *sql.DB) *Server {
 func(s *Server)Setup()error{
 	s.router.Use(middleware.SetupCORS())
 	s.router.Use(middleware.ObservabilityMiddleware())
+	s.router.Use(middleware.ProblemMiddleware())
 	
 	authMiddleware, err := middleware.SetupJWT(s.config)
 	if err != nil{
@@ -49,15 +63,34 @@ func(s *Server)Setup()error{
 	categoryRepo := categories.NewRepository(s.db)
 	versionRepo := versions.NewRepository(s.db)
 	navigationRepo := navigation.NewRepository(s.db)
+	mediaRepo := media.NewRepository(s.db)
+	tagRepo := tags.NewRepository(s.db)
+	previewRepo := previews.NewRepository(s.db)
+	graphRepo := graph.NewRepository(s.db)
+	webhookRepo := webhooks.NewRepository(s.db)
 
 
 	//Services
+	// Content services publish their events to the webhooks outbox
+	webhookConfig := webhooks.Config(s.config.Webhooks)
+	webhookService := webhooks.NewService(webhookRepo, webhookConfig)
 	userService := users.NewService(userRepo)
 	authService := auth.NewAuthService(userService, authMiddleware)
-	postService := posts.NewService(postRepo, userService)
-	categoryService := categories.NewService(categoryRepo)
+	mediaSigner := media.NewSigner(s.config.Media.SigningSecret)
+	mediaService := media.NewService(mediaRepo, s.config.Media.Path, mediaSigner, s.config.Media.SignedURLTTL, webhookService)
+	tagService := tags.NewService(tagRepo)
+	postService := posts.NewService(postRepo, userService, mediaService, tagService, webhookService)
+	categoryService := categories.NewService(categoryRepo, webhookService)
 	versionService := versions.NewService(versionRepo)
-	navigationService := navigation.NewService(navigationRepo)
+	previewService := previews.NewService(previewRepo, postService, versionService, mediaService)
+	navigationService := navigation.NewService(navigationRepo, webhookService)
+	resolverService := resolver.NewService(postService, categoryService, navigationService)
+	sitemapService := sitemap.NewService(postService, categoryService, s.config.App.SiteURL)
+	graphService, err := graph.NewService(graphRepo, graph.Config(s.config.GraphQL), postService, categoryService, tagService, navigationService)
+	if err != nil {
+		return err
+	}
+	s.dispatcher = webhooks.NewDispatcher(webhookRepo, webhookConfig)
 
 	//Handlers
 	userHandler := users.NewHandler(userService)
@@ -65,46 +98,139 @@ func(s *Server)Setup()error{
 	postHandler := posts.NewHandler(postService)
 	categoryHandler := categories.NewHandler(categoryService)
 	versionHandler := versions.NewHandler(versionService)
+	previewHandler := previews.NewHandler(previewService)
 	navigationHandler := navigation.NewHandler(navigationService)
-	imageHandler := images.NewHandler()
+	resolverHandler := resolver.NewHandler(resolverService)
+	sitemapHandler := sitemap.NewHandler(sitemapService)
+	mediaHandler := media.NewHandler(mediaService)
+	tagHandler := tags.NewHandler(tagService)
+	imageHandler := images.NewHandler(mediaService)
+	graphHandler := graph.NewHandler(graphService)
+	webhookHandler := webhooks.NewHandler(webhookService)
 
 	s.router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "OK"})
 	})
 
+	// Problem types named by error responses
+	s.router.GET("/problems/:code", apierror.Describe)
+	s.router.NoRoute(func(c *gin.Context) {
+		apierror.Write(c, apierror.NotFound)
+	})
+
 	// Prometheus metrics endpoint
 	s.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	// Serve static files from images directory
-	s.router.Static("/images", "../images")
-
-	// Public routes
-	public := s.router.Group("/auth")
-	auth.RegisterRoutes(public, authHandler, authMiddleware)	
+	// Serve files from the media directory; private ones need a signed URL
+	media.RegisterFileRoutes(s.router.Group("/images"), &mediaHandler)
+
+	// Read-only GraphQL over the public blog
+	graph.RegisterEndpointRoutes(s.router.Group("/graphql"), &graphHandler)
+
+	// v1 is served under /v1 and, as deprecated aliases, at the root
+	v1 := Version{
+		Name: "v1",
+		Resources: []Resource{
+			{Name: "auth", Routes: []Routes{
+				{Auth, func(r *gin.RouterGroup) { auth.RegisterRoutes(r, authHandler, authMiddleware) }, auth.Operations()},
+			}},
+			{Name: "users", Routes: []Routes{
+				{API, func(r *gin.RouterGroup) { users.RegisterRoutes(r, &userHandler) }, users.Operations()},
+			}},
+			{Name: "posts", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { posts.RegisterPublicRoutes(r, &postHandler) }, posts.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { posts.RegisterRoutes(r, &postHandler) }, posts.Operations()},
+			}},
+			{Name: "categories", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { categories.RegisterPublicRoutes(r, &categoryHandler) }, categories.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { categories.RegisterRoutes(r, &categoryHandler) }, categories.Operations()},
+			}},
+			{Name: "versions", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { versions.RegisterPublicRoutes(r, &versionHandler) }, versions.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { versions.RegisterRoutes(r, &versionHandler) }, versions.Operations()},
+			}},
+			{Name: "previews", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { previews.RegisterPublicRoutes(r, &previewHandler) }, previews.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { previews.RegisterRoutes(r, &previewHandler) }, previews.Operations()},
+			}},
+			{Name: "navigation", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { navigation.RegisterPublicRoutes(r, &navigationHandler) }, navigation.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { navigation.RegisterRoutes(r, &navigationHandler) }, navigation.Operations()},
+			}},
+			{Name: "resolver", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { resolver.RegisterPublicRoutes(r, &resolverHandler) }, resolver.PublicOperations()},
+			}},
+			{Name: "sitemap", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { sitemap.RegisterPublicRoutes(r, &sitemapHandler) }, sitemap.PublicOperations()},
+			}},
+			{Name: "images", Routes: []Routes{
+				{API, func(r *gin.RouterGroup) { images.RegisterRoutes(r, &imageHandler) }, images.Operations()},
+			}},
+			{Name: "media", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { media.RegisterPublicRoutes(r, &mediaHandler) }, media.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { media.RegisterRoutes(r, &mediaHandler) }, media.Operations()},
+			}},
+			{Name: "tags", Routes: []Routes{
+				{Blog, func(r *gin.RouterGroup) { tags.RegisterPublicRoutes(r, &tagHandler) }, tags.PublicOperations()},
+				{API, func(r *gin.RouterGroup) { tags.RegisterRoutes(r, &tagHandler) }, tags.Operations()},
+			}},
+			{Name: "graphql", Routes: []Routes{
+				{API, func(r *gin.RouterGroup) { graph.RegisterRoutes(r, &graphHandler) }, graph.Operations()},
+			}},
+			{Name: "webhooks", Routes: []Routes{
+				{API, func(r *gin.RouterGroup) { webhooks.RegisterRoutes(r, &webhookHandler) }, webhooks.Operations()},
+			}},
+		},
+	}
 
-	//Blog routes
-	blog := s.router.Group("/blog")
-	posts.RegisterPublicRoutes(blog, &postHandler)	
-	categories.RegisterPublicRoutes(blog, &categoryHandler)
-	versions.RegisterPublicRoutes(blog, &versionHandler)	
-	navigation.RegisterPublicRoutes(blog, &navigationHandler)
-	
+	protect := []gin.HandlerFunc{
+		authMiddleware.MiddlewareFunc(),
+		middleware.ContextMiddleware(), // Inject context values
+	}
+	s.mount("/v1", v1, false, protect, s.versionHandlers(v1, false)...)
+	s.mount("", v1, true, protect, s.versionHandlers(v1, true)...)
+	// A /v2 starts from v1 and replaces the resources whose DTOs change:
+	//	v2 := v1.Replace("v2", Resource{Name: "posts", Routes: ...})
+	//	s.mount("/v2", v2, false, protect, s.versionHandlers(v2, false)...)
 
-	//protected routes
-	protected := s.router.Group("/api")
-	protected.Use(authMiddleware.MiddlewareFunc())
-	protected.Use(middleware.ContextMiddleware()) // Inject context values	
-	users.RegisterRoutes(protected, &userHandler) // internally has /users prefix		
-	posts.RegisterRoutes(protected, &postHandler)		
-	categories.RegisterRoutes(protected, &categoryHandler)		
-	versions.RegisterRoutes(protected, &versionHandler)		
-	navigation.RegisterRoutes(protected, &navigationHandler)
-	images.RegisterRoutes(protected, &imageHandler)
+	// API description and its docs page
+	s.spec = s.describe()
+	s.router.GET("/openapi.json", s.spec.Serve)
+	s.router.GET("/docs", openapi.Docs)
 
 	return nil
 	
 }
 
 func(s *Server)Run()error{
+	// Webhook deliveries go out in the background for as long as the server
+	// runs; what is left when it stops stays in the outbox for the next start
+	go s.dispatcher.Run(context.Background())
 	return s.router.Run(":" + s.config.App.Port)
-}
\ No newline at end of file
+}
+
+// describe builds the OpenAPI document from the operations of each package,
+// mounted where Setup registers them
+func (s *Server) describe() *openapi.Document {
+	spec := openapi.New("Havamal API", "1.0.0", "Blog and CMS backend. Errors are answered as problem details; see /problems/{code}.")
+	spec.Add("", false,
+		openapi.Operation{Method: "GET", Path: "/health", Tag: "service", Summary: "Check the service is up"},
+		openapi.Operation{Method: "GET", Path: "/problems/:code", Tag: "service", Summary: "Describe a problem type",
+			Response: struct {
+				Type   string `json:"type"`
+				Code   string `json:"code"`
+				Status int    `json:"status"`
+				Title  string `json:"title"`
+			}{}},
+		openapi.Operation{Method: "GET", Path: "/metrics", Tag: "service", Summary: "Prometheus metrics",
+			Response: &openapi.Schema{Type: "string"}, Media: "text/plain"},
+		openapi.Operation{Method: "GET", Path: "/openapi.json", Tag: "service", Summary: "This document",
+			Response: &openapi.Schema{Type: "object"}},
+		openapi.Operation{Method: "GET", Path: "/docs", Tag: "service", Summary: "Browse this document",
+			Response: &openapi.Schema{Type: "string"}, Media: "text/html"},
+	)
+	spec.Add("/images", false, media.FileOperations()...)
+	spec.Add("/graphql", false, graph.EndpointOperations()...)
+	s.describeVersions(spec)
+	return spec
+}
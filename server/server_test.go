@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+
+	"havamal-api/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setup(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	s := NewServer(cfg, nil)
+	if err := s.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	return s
+}
+
+// Every registered route must be in the OpenAPI document
+func TestSpecCoversRoutes(t *testing.T) {
+	s := setup(t)
+	for _, route := range s.router.Routes() {
+		if !s.spec.Has(route.Method, route.Path) {
+			t.Errorf("%s %s is not in the OpenAPI document", route.Method, route.Path)
+		}
+	}
+}
+
+// Every operation in the OpenAPI document must be a registered route
+func TestSpecHasNoStaleOperations(t *testing.T) {
+	s := setup(t)
+	registered := make(map[[2]string]bool)
+	for _, route := range s.router.Routes() {
+		registered[[2]string{route.Method, route.Path}] = true
+	}
+	for _, operation := range s.spec.Operations() {
+		if !registered[operation] {
+			t.Errorf("%s %s is in the OpenAPI document but not registered", operation[0], operation[1])
+		}
+	}
+}
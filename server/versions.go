@@ -0,0 +1,116 @@
+package server
+
+import (
+	"havamal-api/internal/openapi"
+	"havamal-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Group is one of the groups every API version has
+type Group string
+
+const (
+	Auth Group = "/auth"
+	Blog Group = "/blog"
+	API  Group = "/api"
+)
+
+// Routes registers part of a resource on one group and describes it
+type Routes struct {
+	Group      Group
+	Register   func(router *gin.RouterGroup)
+	Operations []openapi.Operation
+}
+
+// Resource is everything one package serves in an API version
+type Resource struct {
+	Name   string
+	Routes []Routes
+}
+
+// Version is a release of the API, mounted under /<Name>
+type Version struct {
+	Name      string
+	Resources []Resource
+}
+
+// Replace returns the next version of v: it serves the same resources except
+// those given, which replace the ones of the same name or are added. This is
+// how a /v2 changes the DTOs of some resources and keeps the rest.
+func (v Version) Replace(name string, resources ...Resource) Version {
+	next := Version{Name: name}
+	replaced := make(map[string]Resource)
+	for _, resource := range resources {
+		replaced[resource.Name] = resource
+	}
+	for _, resource := range v.Resources {
+		if replacement, ok := replaced[resource.Name]; ok {
+			resource = replacement
+			delete(replaced, resource.Name)
+		}
+		next.Resources = append(next.Resources, resource)
+	}
+	for _, resource := range resources {
+		if _, ok := replaced[resource.Name]; ok {
+			next.Resources = append(next.Resources, resource)
+		}
+	}
+	return next
+}
+
+// mount is a version registered under a prefix: /<name>, or the root for the
+// deprecated aliases of v1
+type mount struct {
+	prefix     string
+	version    Version
+	deprecated bool
+}
+
+// mount registers a version under prefix. The API group needs a token and
+// gets the user's context.
+func (s *Server) mount(prefix string, version Version, deprecated bool, protect []gin.HandlerFunc, handlers ...gin.HandlerFunc) {
+	root := s.router.Group(prefix, handlers...)
+	groups := map[Group]*gin.RouterGroup{
+		Auth: root.Group(string(Auth)),
+		Blog: root.Group(string(Blog)),
+		API:  root.Group(string(API), protect...),
+	}
+	for _, resource := range version.Resources {
+		for _, routes := range resource.Routes {
+			routes.Register(groups[routes.Group])
+		}
+	}
+	s.mounts = append(s.mounts, mount{prefix: prefix, version: version, deprecated: deprecated})
+}
+
+// describeVersions adds the operations of every mounted version to spec
+func (s *Server) describeVersions(spec *openapi.Document) {
+	for _, mount := range s.mounts {
+		for _, resource := range mount.version.Resources {
+			for _, routes := range resource.Routes {
+				operations := make([]openapi.Operation, len(routes.Operations))
+				for i, operation := range routes.Operations {
+					operation.Deprecated = mount.deprecated
+					operations[i] = operation
+				}
+				spec.Add(mount.prefix+string(routes.Group), routes.Group == API, operations...)
+			}
+		}
+	}
+}
+
+// versionHandlers returns the middleware of a version mounted at its own
+// prefix, or of the deprecated aliases
+func (s *Server) versionHandlers(version Version, alias bool) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{middleware.VersionMiddleware(version.Name, alias)}
+	if alias {
+		handlers = append(handlers, middleware.DeprecationMiddleware(middleware.Deprecation{
+			Since:     s.config.API.AliasDeprecated,
+			Sunset:    s.config.API.AliasSunset,
+			Successor: "/" + version.Name,
+			Policy:    "/docs",
+		}))
+	}
+	return handlers
+}
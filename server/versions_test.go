@@ -0,0 +1,51 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	v1 := Version{Name: "v1", Resources: []Resource{{Name: "auth"}, {Name: "posts"}, {Name: "tags"}}}
+	posts := Resource{Name: "posts", Routes: []Routes{{Group: API}}}
+	v2 := v1.Replace("v2", posts, Resource{Name: "comments"})
+
+	names := make([]string, len(v2.Resources))
+	for i, resource := range v2.Resources {
+		names[i] = resource.Name
+	}
+	// Replacements keep their place and new resources come last
+	if v2.Name != "v2" || !reflect.DeepEqual(names, []string{"auth", "posts", "tags", "comments"}) {
+		t.Fatalf("Replace() = %s %v", v2.Name, names)
+	}
+	if len(v2.Resources[1].Routes) != 1 {
+		t.Error("Replace() kept the v1 posts")
+	}
+	if len(v1.Resources) != 3 || len(v1.Resources[1].Routes) != 0 {
+		t.Error("Replace() changed v1")
+	}
+}
+
+// Every v1 route is also served unversioned, and nothing else is
+func TestAliases(t *testing.T) {
+	s := setup(t)
+	versioned := make(map[string]bool)
+	aliases := make(map[string]bool)
+	for _, route := range s.router.Routes() {
+		switch {
+		case strings.HasPrefix(route.Path, "/v1/"):
+			versioned[route.Method+" "+strings.TrimPrefix(route.Path, "/v1")] = true
+		case strings.HasPrefix(route.Path, string(Auth)), strings.HasPrefix(route.Path, string(Blog)), strings.HasPrefix(route.Path, string(API)):
+			aliases[route.Method+" "+route.Path] = true
+		}
+	}
+	if len(versioned) == 0 || !reflect.DeepEqual(versioned, aliases) {
+		t.Errorf("%d routes under /v1, %d aliases", len(versioned), len(aliases))
+		for route := range versioned {
+			if !aliases[route] {
+				t.Errorf("%s has no alias", route)
+			}
+		}
+	}
+}